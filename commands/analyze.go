@@ -0,0 +1,506 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v3"
+)
+
+func esbuildAnalyze() *cli.Command {
+	return &cli.Command{
+		Name:  "analyze",
+		Usage: "analyze esbuild metafile: size breakdown, duplicated packages and import chains",
+		Flags: []cli.Flag{
+			&cli.StringSliceFlag{
+				Name:  "why",
+				Usage: "print the import chain that explains why a module or package is included",
+			},
+			&cli.IntFlag{
+				Name:  "top",
+				Usage: "number of packages listed per output",
+				Value: 10,
+			},
+			&cli.StringFlag{
+				Name:  "html",
+				Usage: "write a self-contained html treemap report",
+			},
+			&cli.StringFlag{
+				Name:  "compare",
+				Usage: "previous metafile to compare sizes with",
+			},
+			&cli.StringFlag{
+				Name:  "json",
+				Usage: "write size report (with diff when --compare is given) as json, - for stdout",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArgs{
+				Name:      "metafile",
+				UsageText: "metafile written by build --metafile",
+				Min:       1,
+				Max:       1,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			meta, err := readMetafile(cmd.StringArgs("metafile")[0])
+			if err != nil {
+				return err
+			}
+			a := analyzeMetafile(meta)
+			w := cmd.Root().Writer
+			jsonOut := cmd.String("json")
+			if jsonOut != "-" {
+				a.print(w, cmd.Int("top"))
+				for _, target := range cmd.StringSlice("why") {
+					a.printWhy(w, target)
+				}
+			}
+			if file := cmd.String("html"); file != "" {
+				if err := os.WriteFile(file, []byte(a.treemap()), 0644); err != nil {
+					return fmt.Errorf("failed to write html report: %v", err)
+				}
+				if jsonOut != "-" {
+					fmt.Fprintf(w, "\nTreemap written to %s\n", file)
+				}
+			}
+			if jsonOut != "" {
+				report := a.report(nil)
+				if previous := cmd.String("compare"); previous != "" {
+					old, err := readMetafile(previous)
+					if err != nil {
+						return err
+					}
+					report = a.report(analyzeMetafile(old))
+				}
+				data, _ := json.MarshalIndent(report, "", "  ")
+				if jsonOut == "-" {
+					_, err = fmt.Fprintln(w, string(data))
+					return err
+				}
+				if err := os.WriteFile(jsonOut, data, 0644); err != nil {
+					return fmt.Errorf("failed to write json report: %v", err)
+				}
+			} else if previous := cmd.String("compare"); previous != "" {
+				old, err := readMetafile(previous)
+				if err != nil {
+					return err
+				}
+				a.report(analyzeMetafile(old)).print(w)
+			}
+			return nil
+		},
+	}
+}
+
+// projectPackage 不属于任何 npm 包的输入归入此分组
+const projectPackage = "(project)"
+
+// outputAnalysis 单个输出文件的体积构成
+type outputAnalysis struct {
+	path     string
+	bytes    int
+	packages map[string]int
+	files    map[string]int
+}
+
+// metafileAnalysis metafile 的分析结果
+type metafileAnalysis struct {
+	meta     *metafile
+	outputs  []*outputAnalysis
+	packages map[string]int
+	versions map[string]map[string]bool // 包名 -> 版本（或安装位置）
+}
+
+func analyzeMetafile(meta *metafile) *metafileAnalysis {
+	a := &metafileAnalysis{
+		meta:     meta,
+		packages: make(map[string]int),
+		versions: make(map[string]map[string]bool),
+	}
+	for path, out := range meta.Outputs {
+		if strings.HasSuffix(path, ".map") {
+			continue
+		}
+		o := &outputAnalysis{path: path, bytes: out.Bytes, packages: make(map[string]int), files: make(map[string]int)}
+		for input, in := range out.Inputs {
+			name, version := inputPackage(input)
+			o.packages[name] += in.BytesInOutput
+			o.files[input] += in.BytesInOutput
+			a.packages[name] += in.BytesInOutput
+			if name != projectPackage {
+				if a.versions[name] == nil {
+					a.versions[name] = make(map[string]bool)
+				}
+				a.versions[name][version] = true
+			}
+		}
+		a.outputs = append(a.outputs, o)
+	}
+	sort.Slice(a.outputs, func(i, j int) bool { return a.outputs[i].path < a.outputs[j].path })
+	return a
+}
+
+// inputPackage 从输入路径推断所属 npm 包和版本，无法读取版本时使用安装位置
+func inputPackage(input string) (name, version string) {
+	if strings.HasPrefix(input, npmNamespace+":") {
+		key, _ := splitArchivePath(strings.TrimPrefix(input, npmNamespace+":"))
+		if at := strings.LastIndexByte(key, '@'); at > 0 {
+			return key[:at], key[at+1:]
+		}
+		return key, ""
+	}
+	if i := strings.Index(input, ":"); i > 0 && !filepath.IsAbs(input) {
+		input = input[i+1:]
+	}
+	const nm = "node_modules/"
+	i := strings.LastIndex(input, nm)
+	if i < 0 {
+		return projectPackage, ""
+	}
+	rest := input[i+len(nm):]
+	parts := strings.SplitN(rest, "/", 3)
+	name = parts[0]
+	if strings.HasPrefix(name, "@") && len(parts) > 1 {
+		name += "/" + parts[1]
+	}
+	location := input[:i+len(nm)] + name
+	var manifest npmManifest
+	if data, err := os.ReadFile(filepath.Join(filepath.FromSlash(location), "package.json")); err == nil && json.Unmarshal(data, &manifest) == nil && manifest.Version != "" {
+		return name, manifest.Version
+	}
+	return name, location
+}
+
+// duplicates 返回以多个版本打包的包
+func (a *metafileAnalysis) duplicates() map[string][]string {
+	dup := make(map[string][]string)
+	for name, versions := range a.versions {
+		if len(versions) < 2 {
+			continue
+		}
+		for v := range versions {
+			dup[name] = append(dup[name], v)
+		}
+		sort.Strings(dup[name])
+	}
+	return dup
+}
+
+func (a *metafileAnalysis) print(w io.Writer, top int) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	total := 0
+	for _, o := range a.outputs {
+		total += o.bytes
+		fmt.Fprintf(tw, "%s\t%s\n", o.path, formatBytes(o.bytes))
+		for i, p := range sortedSizes(o.packages) {
+			if i >= top {
+				fmt.Fprintf(tw, "  ... %d more packages\t\n", len(o.packages)-top)
+				break
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", p.name, formatBytes(p.size), percent(p.size, o.bytes))
+		}
+	}
+	fmt.Fprintf(tw, "total\t%s\n", formatBytes(total))
+	_ = tw.Flush()
+
+	fmt.Fprintln(w, "\nPackages:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, p := range sortedSizes(a.packages) {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", p.name, formatBytes(p.size), percent(p.size, total))
+	}
+	_ = tw.Flush()
+
+	dup := a.duplicates()
+	if len(dup) > 0 {
+		fmt.Fprintln(w, "\nDuplicated packages:")
+		names := make([]string, 0, len(dup))
+		for name := range dup {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "  %s: %s\n", name, strings.Join(dup[name], ", "))
+		}
+	}
+}
+
+// printWhy 输出从入口到目标模块的最短导入链
+func (a *metafileAnalysis) printWhy(w io.Writer, target string) {
+	var targets []string
+	for input := range a.meta.Inputs {
+		name, _ := inputPackage(input)
+		if input == target || name == target || strings.HasSuffix(input, "/"+target) {
+			targets = append(targets, input)
+		}
+	}
+	fmt.Fprintf(w, "\nWhy %s:\n", target)
+	if len(targets) == 0 {
+		fmt.Fprintln(w, "  not included in any output")
+		return
+	}
+	sort.Strings(targets)
+	for _, t := range targets {
+		chain := a.importChain(t)
+		if chain == nil {
+			fmt.Fprintf(w, "  %s: no import chain from an entry point\n", t)
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", strings.Join(chain, "\n    -> "))
+	}
+}
+
+// importChain 从所有入口广度优先搜索到目标输入的导入链
+func (a *metafileAnalysis) importChain(target string) []string {
+	parent := make(map[string]string)
+	var queue []string
+	for _, out := range a.meta.Outputs {
+		if out.EntryPoint != "" {
+			if _, ok := parent[out.EntryPoint]; !ok {
+				parent[out.EntryPoint] = ""
+				queue = append(queue, out.EntryPoint)
+			}
+		}
+	}
+	sort.Strings(queue)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == target {
+			var chain []string
+			for n := current; n != ""; n = parent[n] {
+				chain = append([]string{n}, chain...)
+			}
+			return chain
+		}
+		for _, imp := range a.meta.Inputs[current].Imports {
+			if _, seen := parent[imp.Path]; !seen && !imp.External {
+				parent[imp.Path] = current
+				queue = append(queue, imp.Path)
+			}
+		}
+	}
+	return nil
+}
+
+// sizeDelta 体积变化
+type sizeDelta struct {
+	Name   string `json:"name,omitempty"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Delta  int    `json:"delta"`
+}
+
+// sizeReport 可供体积预算检查使用的机器可读报告
+type sizeReport struct {
+	Total      sizeDelta           `json:"total"`
+	Outputs    []sizeDelta         `json:"outputs"`
+	Packages   []sizeDelta         `json:"packages"`
+	Duplicates map[string][]string `json:"duplicates,omitempty"`
+}
+
+var outputHashPattern = regexp.MustCompile(`-[A-Z2-7]{8}(\.[^/]+)$`)
+
+// outputKey 去掉输出文件名中的内容哈希，使不同构建之间的输出可以对应
+func outputKey(path string) string {
+	return outputHashPattern.ReplaceAllString(path, "$1")
+}
+
+// report 生成体积报告，previous 不为空时计算差异
+func (a *metafileAnalysis) report(previous *metafileAnalysis) *sizeReport {
+	r := &sizeReport{Duplicates: a.duplicates()}
+	before, after := make(map[string]int), make(map[string]int)
+	for _, o := range a.outputs {
+		after[outputKey(o.path)] += o.bytes
+		r.Total.After += o.bytes
+	}
+	if previous != nil {
+		for _, o := range previous.outputs {
+			before[outputKey(o.path)] += o.bytes
+			r.Total.Before += o.bytes
+		}
+	}
+	r.Total.Delta = r.Total.After - r.Total.Before
+	r.Outputs = diffSizes(before, after)
+	var oldPackages map[string]int
+	if previous != nil {
+		oldPackages = previous.packages
+	}
+	r.Packages = diffSizes(oldPackages, a.packages)
+	return r
+}
+
+func diffSizes(before, after map[string]int) []sizeDelta {
+	var deltas []sizeDelta
+	for name, size := range after {
+		deltas = append(deltas, sizeDelta{Name: name, Before: before[name], After: size, Delta: size - before[name]})
+	}
+	for name, size := range before {
+		if _, ok := after[name]; !ok {
+			deltas = append(deltas, sizeDelta{Name: name, Before: size, Delta: -size})
+		}
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].Delta != deltas[j].Delta {
+			return deltas[i].Delta > deltas[j].Delta
+		}
+		return deltas[i].Name < deltas[j].Name
+	})
+	return deltas
+}
+
+func (r *sizeReport) print(w io.Writer) {
+	fmt.Fprintln(w, "\nCompared with previous build:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, d := range r.Outputs {
+		if d.Delta != 0 {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", d.Name, formatBytes(d.Before), formatBytes(d.After), formatDelta(d.Delta))
+		}
+	}
+	fmt.Fprintf(tw, "  total\t%s\t%s\t%s\n", formatBytes(r.Total.Before), formatBytes(r.Total.After), formatDelta(r.Total.Delta))
+	_ = tw.Flush()
+}
+
+type namedSize struct {
+	name string
+	size int
+}
+
+func sortedSizes(sizes map[string]int) []namedSize {
+	list := make([]namedSize, 0, len(sizes))
+	for name, size := range sizes {
+		list = append(list, namedSize{name, size})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].size != list[j].size {
+			return list[i].size > list[j].size
+		}
+		return list[i].name < list[j].name
+	})
+	return list
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20 || n <= -1<<20:
+		return fmt.Sprintf("%.2f MiB", float64(n)/(1<<20))
+	case n >= 1<<10 || n <= -1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func formatDelta(n int) string {
+	if n > 0 {
+		return "+" + formatBytes(n)
+	}
+	return formatBytes(n)
+}
+
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+}
+
+// treemapNode treemap 报告中的节点
+type treemapNode struct {
+	Name     string         `json:"name"`
+	Size     int            `json:"size"`
+	Children []*treemapNode `json:"children,omitempty"`
+}
+
+// treemap 生成自包含的 html treemap 报告：输出 -> 包 -> 文件
+func (a *metafileAnalysis) treemap() string {
+	root := &treemapNode{Name: "outputs"}
+	for _, o := range a.outputs {
+		node := &treemapNode{Name: o.path, Size: o.bytes}
+		groups := make(map[string]*treemapNode)
+		for file, size := range o.files {
+			name, _ := inputPackage(file)
+			g := groups[name]
+			if g == nil {
+				g = &treemapNode{Name: name}
+				groups[name] = g
+				node.Children = append(node.Children, g)
+			}
+			g.Size += size
+			g.Children = append(g.Children, &treemapNode{Name: file, Size: size})
+		}
+		root.Size += o.bytes
+		root.Children = append(root.Children, node)
+	}
+	data, _ := json.Marshal(root)
+	return strings.Replace(treemapTemplate, "__DATA__", string(data), 1)
+}
+
+const treemapTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Bundle treemap</title>
+<style>
+body{margin:0;font:12px sans-serif;background:#222;color:#eee}
+#bar{padding:6px 10px;background:#111}
+#bar a{color:#8cf;cursor:pointer}
+#map{position:absolute;top:30px;left:0;right:0;bottom:0}
+.n{position:absolute;box-sizing:border-box;border:1px solid #222;overflow:hidden;cursor:pointer;padding:2px 4px;white-space:nowrap;text-overflow:ellipsis}
+</style>
+</head>
+<body>
+<div id="bar"></div>
+<div id="map"></div>
+<script>
+const data = __DATA__;
+const map = document.getElementById("map"), bar = document.getElementById("bar");
+function fmt(n){return n>=1048576?(n/1048576).toFixed(2)+" MiB":n>=1024?(n/1024).toFixed(1)+" KiB":n+" B"}
+function color(name){let h=0;for(const c of name)h=(h*31+c.charCodeAt(0))%360;return "hsl("+h+",45%,35%)"}
+// squarify 按 squarified 算法布局节点
+function squarify(items,x,y,w,h,out){
+  items=items.filter(i=>i.size>0);
+  const total=items.reduce((s,i)=>s+i.size,0);if(!total)return;
+  let row=[],rest=items.slice(),scale=w*h/total;
+  const worst=(r,len)=>{const s=r.reduce((a,i)=>a+i.size*scale,0),mx=Math.max(...r.map(i=>i.size*scale)),mn=Math.min(...r.map(i=>i.size*scale));return Math.max(len*len*mx/(s*s),s*s/(len*len*mn))};
+  while(rest.length){
+    const len=Math.min(w,h),next=rest[0];
+    if(!row.length||worst(row.concat(next),len)<=worst(row,len)){row.push(rest.shift());continue}
+    [x,y,w,h]=layout(row,x,y,w,h,scale,out);row=[];
+  }
+  if(row.length)layout(row,x,y,w,h,scale,out);
+}
+function layout(row,x,y,w,h,scale,out){
+  const s=row.reduce((a,i)=>a+i.size*scale,0);
+  if(w>=h){const rw=s/h;let cy=y;for(const i of row){const ih=i.size*scale/rw;out.push([i,x,cy,rw,ih]);cy+=ih}return [x+rw,y,w-rw,h]}
+  const rh=s/w;let cx=x;for(const i of row){const iw=i.size*scale/rh;out.push([i,cx,y,iw,rh]);cx+=iw}return [x,y+rh,w,h-rh];
+}
+function show(node,path){
+  map.innerHTML="";bar.innerHTML="";
+  path.forEach((p,i)=>{const a=document.createElement("a");a.textContent=p.name;a.onclick=()=>show(p,path.slice(0,i+1));bar.append(a," / ")});
+  bar.append(fmt(node.size));
+  const out=[],kids=(node.children||[]).slice().sort((a,b)=>b.size-a.size);
+  squarify(kids,0,0,map.clientWidth,map.clientHeight,out);
+  for(const [n,x,y,w,h] of out){
+    const d=document.createElement("div");d.className="n";
+    Object.assign(d.style,{left:x+"px",top:y+"px",width:w+"px",height:h+"px",background:color(n.name)});
+    d.title=n.name+" "+fmt(n.size)+" ("+(n.size*100/node.size).toFixed(1)+"%)";
+    d.textContent=n.name+" "+fmt(n.size);
+    if(n.children)d.onclick=()=>show(n,path.concat(n));
+    map.append(d);
+  }
+}
+show(data,[data]);
+window.onresize=()=>show(data,[data]);
+</script>
+</body>
+</html>
+`
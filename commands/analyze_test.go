@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMetafile = `{
+  "inputs": {
+    "src/main.js": {"bytes": 100, "imports": [{"path": "src/util.js", "kind": "import-statement"}, {"path": "node_modules/lodash/index.js", "kind": "import-statement"}]},
+    "src/util.js": {"bytes": 50, "imports": [{"path": "node_modules/a/node_modules/lodash/index.js", "kind": "import-statement"}]},
+    "node_modules/lodash/index.js": {"bytes": 1000, "imports": []},
+    "node_modules/a/node_modules/lodash/index.js": {"bytes": 900, "imports": []}
+  },
+  "outputs": {
+    "dist/main-ABCDEFGH.js": {
+      "bytes": 2000,
+      "entryPoint": "src/main.js",
+      "imports": [],
+      "exports": [],
+      "inputs": {
+        "src/main.js": {"bytesInOutput": 80},
+        "src/util.js": {"bytesInOutput": 40},
+        "node_modules/lodash/index.js": {"bytesInOutput": 1000},
+        "node_modules/a/node_modules/lodash/index.js": {"bytesInOutput": 880}
+      }
+    },
+    "dist/main-ABCDEFGH.js.map": {"bytes": 5000, "inputs": {}, "imports": [], "exports": []}
+  }
+}`
+
+func TestAnalyzeMetafile(t *testing.T) {
+	meta, err := parseMetafile(testMetafile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := analyzeMetafile(meta)
+	if len(a.outputs) != 1 || a.packages["lodash"] != 1880 || a.packages[projectPackage] != 120 {
+		t.Fatalf("unexpected breakdown: %v", a.packages)
+	}
+	if dup := a.duplicates(); len(dup["lodash"]) != 2 {
+		t.Errorf("lodash should be reported as duplicated: %v", dup)
+	}
+	chain := a.importChain("node_modules/a/node_modules/lodash/index.js")
+	if strings.Join(chain, " > ") != "src/main.js > src/util.js > node_modules/a/node_modules/lodash/index.js" {
+		t.Errorf("unexpected chain: %v", chain)
+	}
+
+	old, _ := parseMetafile(strings.ReplaceAll(strings.ReplaceAll(testMetafile, `"bytes": 2000`, `"bytes": 1500`), "ABCDEFGH", "ZYXWVUTS"))
+	report := a.report(analyzeMetafile(old))
+	if report.Total.Delta != 500 || len(report.Outputs) != 1 || report.Outputs[0].Name != "dist/main.js" {
+		t.Errorf("unexpected diff: %+v", report)
+	}
+}
+
+func TestAnalyzeCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "meta.json")
+	if err := os.WriteFile(file, []byte(testMetafile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := Commands()
+	var out bytes.Buffer
+	cmd.Writer = &out
+	err := cmd.Run(context.Background(), []string{"units", "build", "analyze", "--json", "-", "--html", filepath.Join(dir, "treemap.html"), file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report sizeReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("invalid json report: %v\n%s", err, out.String())
+	}
+	if report.Total.After != 2000 {
+		t.Errorf("unexpected total: %+v", report.Total)
+	}
+	html, err := os.ReadFile(filepath.Join(dir, "treemap.html"))
+	if err != nil || !strings.Contains(string(html), `"name":"lodash"`) {
+		t.Errorf("treemap missing package data: %v", err)
+	}
+}
@@ -0,0 +1,637 @@
+package commands
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// caniuseData 精简的 caniuse 浏览器数据快照，仅保留 esbuild 支持的引擎：版本、发布日期与全球使用率，
+// 由 internal/caniusegen 从固定版本的 caniuse-lite 生成，更新时修改版本与完整性后执行 go generate。
+// 当前嵌入的快照早于生成器，没有 source 字段，数据（例如 Firefox ESR 128、140）也与下面固定的版本不一致，
+// 加载时会提示重新生成；需要在可以访问 npm 仓库的环境中执行 go generate 并提交生成的 caniuse.json
+//
+//go:generate go run ./internal/caniusegen -version 1.0.30001718 -integrity sha512-AflseV1ahcSunK53NfEs9gFWgOEmzr0f+kaMFA4xiLZlr9Hzt7HxcSpIFcnNCUkz6R6dWKa54rUz3HUmI3nVcw== -esr 115,128 -node 20,22,24 -dead ie -o caniuse.json
+//go:embed caniuse.json
+var caniuseData []byte
+
+// caniuseVersion 浏览器版本，JSON 中为 [版本, 发布日期, 使用率] 数组，发布日期未知时为空
+type caniuseVersion struct {
+	version  string
+	released time.Time
+	usage    float64
+}
+
+func (v *caniuseVersion) UnmarshalJSON(data []byte) error {
+	var (
+		raw      [3]json.RawMessage
+		released string
+		err      error
+	)
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if err = json.Unmarshal(raw[0], &v.version); err != nil {
+		return err
+	}
+	if err = json.Unmarshal(raw[1], &released); err != nil {
+		return err
+	}
+	if released != "" {
+		if v.released, err = time.Parse(time.DateOnly, released); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw[2], &v.usage)
+}
+
+type caniuseAgent struct {
+	Type     string           `json:"type"`
+	Versions []caniuseVersion `json:"versions"` // 按发布顺序排列
+}
+
+type caniuseSnapshot struct {
+	Source         string                   `json:"source"` // 生成快照的 caniuse-lite 版本
+	Updated        string                   `json:"updated"`
+	FirefoxEsr     []string                 `json:"firefoxEsr"`
+	MaintainedNode []string                 `json:"maintainedNode"`
+	Dead           []string                 `json:"dead"`
+	Agents         map[string]*caniuseAgent `json:"agents"`
+}
+
+var loadCaniuse = sync.OnceValues(func() (*caniuseSnapshot, error) {
+	s := new(caniuseSnapshot)
+	if err := json.Unmarshal(caniuseData, s); err != nil {
+		return nil, fmt.Errorf("invalid caniuse snapshot: %v", err)
+	}
+	if s.Source == "" {
+		log.Printf("caniuse snapshot (updated %s) does not record its caniuse-lite version, regenerate it with go generate", s.Updated)
+	}
+	return s, nil
+})
+
+// browserAliases browserslist 中浏览器名称的别名
+var browserAliases = map[string]string{
+	"fx":             "firefox",
+	"ff":             "firefox",
+	"ios":            "ios_saf",
+	"explorer":       "ie",
+	"chromeandroid":  "and_chr",
+	"firefoxandroid": "and_ff",
+}
+
+// browserEngines caniuse 浏览器到 esbuild 引擎的映射
+var browserEngines = map[string]api.EngineName{
+	"chrome":  api.EngineChrome,
+	"and_chr": api.EngineChrome,
+	"edge":    api.EngineEdge,
+	"firefox": api.EngineFirefox,
+	"and_ff":  api.EngineFirefox,
+	"safari":  api.EngineSafari,
+	"ios_saf": api.EngineIOS,
+	"opera":   api.EngineOpera,
+	"ie":      api.EngineIE,
+	"node":    api.EngineNode,
+}
+
+// esbuildEngines 目标字符串中可直接使用的引擎名称
+var esbuildEngines = map[string]api.EngineName{
+	"chrome":  api.EngineChrome,
+	"deno":    api.EngineDeno,
+	"edge":    api.EngineEdge,
+	"firefox": api.EngineFirefox,
+	"hermes":  api.EngineHermes,
+	"ie":      api.EngineIE,
+	"ios":     api.EngineIOS,
+	"node":    api.EngineNode,
+	"opera":   api.EngineOpera,
+	"rhino":   api.EngineRhino,
+	"safari":  api.EngineSafari,
+}
+
+// browserVersion browserslist 查询结果中的一项
+type browserVersion struct {
+	browser string
+	version string
+}
+
+var (
+	esTargetPattern       = regexp.MustCompile(`^es(\d+|next)$`)
+	enginePattern         = regexp.MustCompile(`^([a-z]+)(\d+(?:\.\d+)*)$`)
+	usageQueryPattern     = regexp.MustCompile(`^([<>]=?)\s*(\d+(?:\.\d+)?)%$`)
+	lastVersionsPattern   = regexp.MustCompile(`^last\s+(\d+)\s+(?:(\w+)\s+)?(major\s+)?versions?$`)
+	lastYearsPattern      = regexp.MustCompile(`^last\s+(\d+(?:\.\d+)?)\s+years?$`)
+	sincePattern          = regexp.MustCompile(`^since\s+(\d{4})(?:-(\d{2}))?(?:-(\d{2}))?$`)
+	compareVersionPattern = regexp.MustCompile(`^(\w+)\s*([<>]=?)\s*(\d+(?:\.\d+)*)$`)
+	rangeVersionPattern   = regexp.MustCompile(`^(\w+)\s+(\d+(?:\.\d+)*)\s*-\s*(\d+(?:\.\d+)*)$`)
+	exactVersionPattern   = regexp.MustCompile(`^(\w+)\s+(\d+(?:\.\d+)*)$`)
+	orPattern             = regexp.MustCompile(`(?i)\s+or\s+`)
+	andPattern            = regexp.MustCompile(`(?i)\s+and\s+`)
+)
+
+// parseTargets 解析目标，支持逗号分隔的 esnext、es2020、chrome90、node18 等 esbuild 目标，
+// 其余部分作为 browserslist 查询；target 为空时读取 dir 中的 browserslist 配置，没有配置时为 es2015
+func parseTargets(target, dir string) (api.Target, []api.Engine, error) {
+	if strings.TrimSpace(target) == "" {
+		query, err := findBrowserslist(dir)
+		if err != nil {
+			return api.DefaultTarget, nil, err
+		}
+		if query == "" {
+			return api.ES2015, nil, nil
+		}
+		target = query
+	}
+	var (
+		es      = api.DefaultTarget
+		engines []api.Engine
+		query   []string
+	)
+	for _, part := range strings.Split(target, ",") {
+		p := strings.ToLower(strings.TrimSpace(part))
+		if p == "" {
+			continue
+		}
+		if m := esTargetPattern.FindStringSubmatch(p); m != nil {
+			t, err := parseESTarget(m[1])
+			if err != nil {
+				return api.DefaultTarget, nil, err
+			}
+			es = t
+			continue
+		}
+		if engine, ok := parseEngine(p); ok {
+			engines = append(engines, engine)
+			continue
+		}
+		query = append(query, strings.TrimSpace(part))
+	}
+	if len(query) > 0 {
+		resolved, err := resolveBrowserslist(strings.Join(query, ", "))
+		if err != nil {
+			return api.DefaultTarget, nil, err
+		}
+		engines = append(engines, resolved...)
+	}
+	return es, engines, nil
+}
+
+// parseESTarget 解析 es 版本，高于 esbuild 已知的最新版本时视为 esnext
+func parseESTarget(version string) (api.Target, error) {
+	switch version {
+	case "next":
+		return api.ESNext, nil
+	case "5":
+		return api.ES5, nil
+	case "6":
+		return api.ES2015, nil
+	}
+	year, _ := strconv.Atoi(version)
+	switch {
+	case year < 2015:
+		return api.DefaultTarget, fmt.Errorf("unsupported target es%s", version)
+	case year > 2024:
+		return api.ESNext, nil
+	default:
+		return api.ES2015 + api.Target(year-2015), nil
+	}
+}
+
+// parseEngine 解析 chrome90、safari14.1 形式的引擎目标
+func parseEngine(target string) (api.Engine, bool) {
+	m := enginePattern.FindStringSubmatch(strings.ToLower(target))
+	if m == nil {
+		return api.Engine{}, false
+	}
+	name, ok := esbuildEngines[m[1]]
+	if !ok {
+		return api.Engine{}, false
+	}
+	return api.Engine{Name: name, Version: m[2]}, true
+}
+
+// parseEngineTargets 解析配置中的 engines，每项的键为引擎名称，browser 或 browserslist 键为查询语句
+func parseEngineTargets(items []map[string]string) ([]api.Engine, error) {
+	var engines []api.Engine
+	for _, item := range items {
+		for name, version := range item {
+			switch name = strings.ToLower(name); name {
+			case "browser", "browsers", "browserslist":
+				resolved, err := resolveBrowserslist(version)
+				if err != nil {
+					return nil, err
+				}
+				engines = append(engines, resolved...)
+			default:
+				engine, ok := parseEngine(name + strings.TrimPrefix(strings.TrimSpace(version), "v"))
+				if !ok {
+					return nil, fmt.Errorf("invalid engine %s: %s", name, version)
+				}
+				engines = append(engines, engine)
+			}
+		}
+	}
+	return engines, nil
+}
+
+// resolveBrowserslist 按 browserslist 语法解析查询，返回每个 esbuild 引擎的最低版本
+func resolveBrowserslist(query string) ([]api.Engine, error) {
+	versions, err := queryBrowserslist(query)
+	if err != nil {
+		return nil, err
+	}
+	lowest := make(map[api.EngineName]string)
+	for _, v := range versions {
+		engine, ok := browserEngines[v.browser]
+		if !ok {
+			continue
+		}
+		version := strings.SplitN(v.version, "-", 2)[0]
+		if current, ok := lowest[engine]; !ok || compareVersions(version, current) < 0 {
+			lowest[engine] = version
+		}
+	}
+	engines := make([]api.Engine, 0, len(lowest))
+	for name, version := range lowest {
+		engines = append(engines, api.Engine{Name: name, Version: version})
+	}
+	sort.Slice(engines, func(i, j int) bool { return engines[i].Name < engines[j].Name })
+	return engines, nil
+}
+
+// queryBrowserslist 执行查询：逗号与 or 取并集，and 取交集，not 开头的子句从已有结果中排除
+func queryBrowserslist(query string) ([]browserVersion, error) {
+	var (
+		result []browserVersion
+		seen   = make(map[browserVersion]bool)
+	)
+	for _, clause := range strings.Split(orPattern.ReplaceAllString(query, ","), ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+		exclude := false
+		if lower := strings.ToLower(clause); strings.HasPrefix(lower, "not ") {
+			exclude, clause = true, strings.TrimSpace(clause[4:])
+		}
+		var matched map[browserVersion]bool
+		for i, term := range andPattern.Split(clause, -1) {
+			versions, err := browserslistTerm(strings.ToLower(strings.TrimSpace(term)))
+			if err != nil {
+				return nil, err
+			}
+			current := make(map[browserVersion]bool, len(versions))
+			for _, v := range versions {
+				if i == 0 || matched[v] {
+					current[v] = true
+				}
+			}
+			matched = current
+		}
+		if exclude {
+			kept := result[:0]
+			for _, v := range result {
+				if matched[v] {
+					delete(seen, v)
+				} else {
+					kept = append(kept, v)
+				}
+			}
+			result = kept
+			continue
+		}
+		// 保持查询顺序，便于调试输出
+		for _, v := range sortedBrowserVersions(matched) {
+			if !seen[v] {
+				seen[v] = true
+				result = append(result, v)
+			}
+		}
+	}
+	return result, nil
+}
+
+// browserslistTerm 解析单个查询条件
+func browserslistTerm(term string) ([]browserVersion, error) {
+	data, err := loadCaniuse()
+	if err != nil {
+		return nil, err
+	}
+	// filter 遍历所有浏览器版本，筛选满足条件的项
+	filter := func(fn func(browser string, agent *caniuseAgent, i int) bool) []browserVersion {
+		var out []browserVersion
+		for browser, agent := range data.Agents {
+			for i, v := range agent.Versions {
+				if fn(browser, agent, i) {
+					out = append(out, browserVersion{browser, v.version})
+				}
+			}
+		}
+		return out
+	}
+	switch term {
+	case "defaults":
+		return queryBrowserslist("> 0.5%, last 2 versions, Firefox ESR, not dead")
+	case "dead":
+		var out []browserVersion
+		for _, browser := range data.Dead {
+			for _, v := range data.Agents[browser].Versions {
+				out = append(out, browserVersion{browser, v.version})
+			}
+		}
+		return out, nil
+	case "firefox esr", "ff esr", "fx esr":
+		var out []browserVersion
+		for _, version := range data.FirefoxEsr {
+			out = append(out, browserVersion{"firefox", version})
+		}
+		return out, nil
+	case "maintained node versions":
+		var out []browserVersion
+		for _, version := range data.MaintainedNode {
+			out = append(out, browserVersion{"node", version})
+		}
+		return out, nil
+	case "unreleased versions":
+		return nil, nil
+	}
+	if m := usageQueryPattern.FindStringSubmatch(term); m != nil {
+		limit, _ := strconv.ParseFloat(m[2], 64)
+		return filter(func(_ string, agent *caniuseAgent, i int) bool {
+			return compareFloat(agent.Versions[i].usage, m[1], limit)
+		}), nil
+	}
+	if m := lastVersionsPattern.FindStringSubmatch(term); m != nil {
+		count, _ := strconv.Atoi(m[1])
+		var browser string
+		if m[2] != "" {
+			if browser = normalizeBrowser(m[2]); data.Agents[browser] == nil {
+				return unknownBrowser(m[2])
+			}
+		}
+		return filter(func(name string, agent *caniuseAgent, i int) bool {
+			if browser != "" && name != browser {
+				return false
+			}
+			if m[3] == "" {
+				return i >= len(agent.Versions)-count
+			}
+			// 最近 count 个主版本内的所有版本
+			latest := majorVersion(agent.Versions[len(agent.Versions)-1].version)
+			return majorVersion(agent.Versions[i].version) > latest-count
+		}), nil
+	}
+	if m := lastYearsPattern.FindStringSubmatch(term); m != nil {
+		years, _ := strconv.ParseFloat(m[1], 64)
+		since := time.Now().Add(-time.Duration(years * 365.25 * 24 * float64(time.Hour)))
+		return filter(func(_ string, agent *caniuseAgent, i int) bool {
+			return !agent.Versions[i].released.Before(since)
+		}), nil
+	}
+	if m := sincePattern.FindStringSubmatch(term); m != nil {
+		month, day := 1, 1
+		if m[2] != "" {
+			month, _ = strconv.Atoi(m[2])
+		}
+		if m[3] != "" {
+			day, _ = strconv.Atoi(m[3])
+		}
+		year, _ := strconv.Atoi(m[1])
+		since := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		return filter(func(_ string, agent *caniuseAgent, i int) bool {
+			return !agent.Versions[i].released.Before(since)
+		}), nil
+	}
+	if m := compareVersionPattern.FindStringSubmatch(term); m != nil {
+		browser := normalizeBrowser(m[1])
+		if browser == "node" {
+			if m[2] == ">" || m[2] == ">=" {
+				// node 不在快照中，只需要下限
+				return []browserVersion{{"node", m[3]}}, nil
+			}
+			return nil, fmt.Errorf("unsupported browserslist query %q", term)
+		}
+		if data.Agents[browser] == nil {
+			return unknownBrowser(m[1])
+		}
+		return filter(func(name string, agent *caniuseAgent, i int) bool {
+			return name == browser && compareFloat(float64(compareVersions(agent.Versions[i].version, m[3])), m[2], 0)
+		}), nil
+	}
+	if m := rangeVersionPattern.FindStringSubmatch(term); m != nil {
+		browser := normalizeBrowser(m[1])
+		if data.Agents[browser] == nil {
+			return unknownBrowser(m[1])
+		}
+		return filter(func(name string, agent *caniuseAgent, i int) bool {
+			v := agent.Versions[i].version
+			return name == browser && compareVersions(v, m[2]) >= 0 && compareVersions(v, m[3]) <= 0
+		}), nil
+	}
+	if m := exactVersionPattern.FindStringSubmatch(term); m != nil {
+		browser := normalizeBrowser(m[1])
+		if browser == "node" {
+			return []browserVersion{{"node", m[2]}}, nil
+		}
+		if data.Agents[browser] == nil {
+			return unknownBrowser(m[1])
+		}
+		return filter(func(name string, agent *caniuseAgent, i int) bool {
+			return name == browser && versionInRange(agent.Versions[i].version, m[2])
+		}), nil
+	}
+	return nil, fmt.Errorf("unknown browserslist query %q", term)
+}
+
+// unknownBrowser 快照中不存在的浏览器 esbuild 也无法作为目标，忽略并提示
+func unknownBrowser(name string) ([]browserVersion, error) {
+	log.Printf("Warning: browserslist browser %s is not supported by esbuild, ignored", name)
+	return nil, nil
+}
+
+func normalizeBrowser(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := browserAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// sortedBrowserVersions 按浏览器名称与版本排序
+func sortedBrowserVersions(set map[browserVersion]bool) []browserVersion {
+	out := make([]browserVersion, 0, len(set))
+	for v := range set {
+		out = append(out, v)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].browser != out[j].browser {
+			return out[i].browser < out[j].browser
+		}
+		return compareVersions(out[i].version, out[j].version) < 0
+	})
+	return out
+}
+
+// compareFloat 按比较运算符比较 a 与 b
+func compareFloat(a float64, op string, b float64) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	}
+	return false
+}
+
+// compareVersions 比较点分版本号，区间版本（如 15.2-15.3）取起始版本
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.SplitN(a, "-", 2)[0], ".")
+	bs := strings.Split(strings.SplitN(b, "-", 2)[0], ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionInRange 判断 caniuse 版本（可能为 15.2-15.3 区间）是否包含 version
+func versionInRange(caniuse, version string) bool {
+	lo, hi, ok := strings.Cut(caniuse, "-")
+	if !ok {
+		hi = lo
+	}
+	return compareVersions(version, lo) >= 0 && compareVersions(version, hi) <= 0
+}
+
+func majorVersion(version string) int {
+	major, _ := strconv.Atoi(strings.SplitN(strings.SplitN(version, "-", 2)[0], ".", 2)[0])
+	return major
+}
+
+// findBrowserslist 从 dir 开始向上查找 .browserslistrc 或 package.json 中的 browserslist 配置，
+// 环境由 BROWSERSLIST_ENV 或 NODE_ENV 指定，默认 production
+func findBrowserslist(dir string) (string, error) {
+	if query := os.Getenv("BROWSERSLIST"); query != "" {
+		return query, nil
+	}
+	env := os.Getenv("BROWSERSLIST_ENV")
+	if env == "" {
+		env = os.Getenv("NODE_ENV")
+	}
+	if env == "" {
+		env = "production"
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, ".browserslistrc")); err == nil {
+			return parseBrowserslistrc(string(data), env), nil
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+			var pkg struct {
+				Browserslist json.RawMessage `json:"browserslist"`
+			}
+			if err := json.Unmarshal(data, &pkg); err != nil {
+				return "", fmt.Errorf("invalid %s: %v", filepath.Join(dir, "package.json"), err)
+			}
+			if len(pkg.Browserslist) > 0 {
+				return browserslistField(pkg.Browserslist, env)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// browserslistField 解析 package.json 的 browserslist 字段，可以是字符串、数组或按环境区分的对象
+func browserslistField(raw json.RawMessage, env string) (string, error) {
+	var query string
+	if err := json.Unmarshal(raw, &query); err == nil {
+		return query, nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return strings.Join(list, ", "), nil
+	}
+	var envs map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &envs); err != nil {
+		return "", fmt.Errorf("invalid browserslist field: %s", raw)
+	}
+	for _, key := range []string{env, "defaults"} {
+		if value, ok := envs[key]; ok {
+			return browserslistField(value, env)
+		}
+	}
+	return "", nil
+}
+
+// parseBrowserslistrc 解析 .browserslistrc，[env] 段落仅在对应环境生效
+func parseBrowserslistrc(content, env string) string {
+	var (
+		common, selected []string
+		section          string
+		found            bool
+	)
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = ""
+			for _, name := range strings.Fields(line[1 : len(line)-1]) {
+				if name == env {
+					section, found = name, true
+				}
+			}
+			if section == "" {
+				section = "-"
+			}
+			continue
+		}
+		switch section {
+		case "":
+			common = append(common, line)
+		case env:
+			selected = append(selected, line)
+		}
+	}
+	if found {
+		return strings.Join(selected, ", ")
+	}
+	return strings.Join(common, ", ")
+}
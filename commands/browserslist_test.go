@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestParseTargets(t *testing.T) {
+	target, engines, err := parseTargets("es2020,chrome90,safari14.1,node18", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != api.ES2020 {
+		t.Errorf("target = %v, want es2020", target)
+	}
+	want := []api.Engine{{Name: api.EngineChrome, Version: "90"}, {Name: api.EngineSafari, Version: "14.1"}, {Name: api.EngineNode, Version: "18"}}
+	if len(engines) != len(want) {
+		t.Fatalf("engines = %v, want %v", engines, want)
+	}
+	for i := range want {
+		if engines[i] != want[i] {
+			t.Errorf("engines[%d] = %v, want %v", i, engines[i], want[i])
+		}
+	}
+	for text, want := range map[string]api.Target{"esnext": api.ESNext, "es2025": api.ESNext, "es6": api.ES2015, "ES2024": api.ES2024} {
+		if target, _, err := parseTargets(text, "."); err != nil || target != want {
+			t.Errorf("parseTargets(%q) = %v, %v, want %v", text, target, err, want)
+		}
+	}
+	if _, _, err := parseTargets("not-a-target", "."); err == nil {
+		t.Errorf("expected error for unknown target")
+	}
+}
+
+func TestResolveBrowserslist(t *testing.T) {
+	engines, err := resolveBrowserslist("chrome >= 100, firefox 115, ios 15.4, not chrome < 105, ie 11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[api.EngineName]string)
+	for _, e := range engines {
+		got[e.Name] = e.Version
+	}
+	want := map[api.EngineName]string{api.EngineChrome: "105", api.EngineFirefox: "115", api.EngineIOS: "15.4", api.EngineIE: "11"}
+	if len(got) != len(want) {
+		t.Errorf("engines = %v, want %v", got, want)
+	}
+	for name, version := range want {
+		if got[name] != version {
+			t.Errorf("engine %v = %q, want %q", name, got[name], version)
+		}
+	}
+
+	engines, err = resolveBrowserslist("defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range engines {
+		if e.Name == api.EngineIE {
+			t.Errorf("defaults should exclude dead browsers, got %v", engines)
+		}
+	}
+	if len(engines) == 0 {
+		t.Errorf("defaults resolved to no engines")
+	}
+
+	engines, err = resolveBrowserslist("last 1 chrome version and > 1%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(engines) != 1 || engines[0].Name != api.EngineChrome {
+		t.Errorf("unexpected intersection %v", engines)
+	}
+}
+
+func TestPackageBrowserslist(t *testing.T) {
+	dir := t.TempDir()
+	pkg := `{"name":"app","browserslist":{"production":["chrome >= 110","safari >= 16"],"development":["last 1 chrome version"]}}`
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(pkg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "src")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("BROWSERSLIST_ENV", "production")
+	target, engines, err := parseTargets("", sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != api.DefaultTarget || len(engines) != 2 {
+		t.Fatalf("parseTargets from package.json = %v %v", target, engines)
+	}
+	if engines[0] != (api.Engine{Name: api.EngineChrome, Version: "110"}) || engines[1] != (api.Engine{Name: api.EngineSafari, Version: "16.0"}) {
+		t.Errorf("unexpected engines %v", engines)
+	}
+	if got := parseBrowserslistrc("# comment\n> 1%\n[production staging]\nchrome 120\n[development]\nlast 1 version\n", "staging"); got != "chrome 120" {
+		t.Errorf("parseBrowserslistrc = %q", got)
+	}
+}
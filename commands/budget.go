@@ -0,0 +1,209 @@
+package commands
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andybalholm/brotli"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// byteSize 字节数，配置中可以写数字或带单位的字符串，例如 "150kb"、"1.5MiB"
+type byteSize int
+
+func (s *byteSize) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*s = byteSize(n)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid size %s", data)
+	}
+	n, err := parseByteSize(text)
+	if err != nil {
+		return err
+	}
+	*s = byteSize(n)
+	return nil
+}
+
+// parseByteSize 解析带单位的大小，k/m 及 kb/kib 均按 1024 计算
+func parseByteSize(text string) (int, error) {
+	s := strings.ToLower(strings.TrimSpace(text))
+	unit := 1
+	for _, u := range []struct {
+		suffix string
+		size   int
+	}{{"mib", 1 << 20}, {"mb", 1 << 20}, {"m", 1 << 20}, {"kib", 1 << 10}, {"kb", 1 << 10}, {"k", 1 << 10}, {"b", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return int(f * float64(unit)), nil
+}
+
+// SizeBudget 输出文件体积预算，为 0 的项不检查
+type SizeBudget struct {
+	Path   string   `json:"path"`   // 输出文件匹配模式（相对于输出目录），可以忽略文件名中的哈希，例如 main.js、*.css
+	Raw    byteSize `json:"raw"`    // 原始字节数上限
+	Gzip   byteSize `json:"gzip"`   // gzip 压缩后字节数上限
+	Brotli byteSize `json:"brotli"` // brotli 压缩后字节数上限
+}
+
+// matches 判断输出文件是否适用预算
+func (b *SizeBudget) matches(rel string) bool {
+	pattern := b.Path
+	if pattern == "" {
+		pattern = "*"
+	}
+	for _, name := range []string{rel, outputKey(rel)} {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compressedSizes 输出文件的各种压缩体积
+type compressedSizes struct {
+	file   string
+	raw    int
+	gzip   []byte
+	brotli []byte
+}
+
+func compressGzip(data []byte) []byte {
+	var buf bytes.Buffer
+	w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+func compressBrotli(data []byte) []byte {
+	var buf bytes.Buffer
+	w := brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	_, _ = w.Write(data)
+	_ = w.Close()
+	return buf.Bytes()
+}
+
+// sizeBudgetPlugin 构建结束后检查体积预算，并按需写入 .gz 与 .br 预压缩文件
+func sizeBudgetPlugin(budgets []SizeBudget, compress []string) api.Plugin {
+	var writeGzip, writeBrotli bool
+	for _, c := range compress {
+		switch strings.ToLower(c) {
+		case "gzip", "gz":
+			writeGzip = true
+		case "brotli", "br":
+			writeBrotli = true
+		}
+	}
+	return api.Plugin{
+		Name: "size-budget",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				if len(result.Errors) > 0 {
+					return api.OnEndResult{}, nil
+				}
+				base := options.Outdir
+				if base == "" {
+					base = filepath.Dir(options.Outfile)
+				}
+				if abs, err := filepath.Abs(base); err == nil {
+					base = abs
+				}
+				var sizes []*compressedSizes
+				for _, f := range result.OutputFiles {
+					if strings.HasSuffix(f.Path, ".map") {
+						continue
+					}
+					rel, err := filepath.Rel(base, f.Path)
+					if err != nil {
+						rel = f.Path
+					}
+					s := &compressedSizes{file: filepath.ToSlash(rel), raw: len(f.Contents)}
+					needGzip, needBrotli := writeGzip, writeBrotli
+					for i := range budgets {
+						if budgets[i].matches(s.file) {
+							needGzip = needGzip || budgets[i].Gzip > 0
+							needBrotli = needBrotli || budgets[i].Brotli > 0
+						}
+					}
+					if needGzip {
+						s.gzip = compressGzip(f.Contents)
+					}
+					if needBrotli {
+						s.brotli = compressBrotli(f.Contents)
+					}
+					if writeGzip && options.Write {
+						if err := os.WriteFile(f.Path+".gz", s.gzip, 0644); err != nil {
+							return api.OnEndResult{}, fmt.Errorf("failed to write %s.gz: %v", f.Path, err)
+						}
+					}
+					if writeBrotli && options.Write {
+						if err := os.WriteFile(f.Path+".br", s.brotli, 0644); err != nil {
+							return api.OnEndResult{}, fmt.Errorf("failed to write %s.br: %v", f.Path, err)
+						}
+					}
+					sizes = append(sizes, s)
+				}
+				if report := checkBudgets(budgets, sizes); report != "" {
+					return api.OnEndResult{Errors: []api.Message{{PluginName: "size-budget", Text: "size budget exceeded\n" + report}}}, nil
+				}
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
+
+// checkBudgets 检查预算，存在超出时返回可读的报告
+func checkBudgets(budgets []SizeBudget, sizes []*compressedSizes) string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	exceeded := false
+	check := func(file, kind string, actual int, limit byteSize) {
+		if limit <= 0 || actual <= int(limit) {
+			return
+		}
+		exceeded = true
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t> %s\t+%s\n", file, kind, formatBytes(actual), formatBytes(int(limit)), formatBytes(actual-int(limit)))
+	}
+	for _, s := range sizes {
+		for i := range budgets {
+			b := &budgets[i]
+			if !b.matches(s.file) {
+				continue
+			}
+			check(s.file, "raw", s.raw, b.Raw)
+			check(s.file, "gzip", len(s.gzip), b.Gzip)
+			check(s.file, "brotli", len(s.brotli), b.Brotli)
+		}
+	}
+	_ = tw.Flush()
+	if !exceeded {
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int{"150": 150, "2kb": 2048, "1.5 MiB": 1572864, "10K": 10240, "7b": 7}
+	for text, want := range cases {
+		if got, err := parseByteSize(text); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d", text, got, err, want)
+		}
+	}
+}
+
+func TestSizeBudgetPlugin(t *testing.T) {
+	dir := t.TempDir()
+	entry := filepath.Join(dir, "main.js")
+	if err := os.WriteFile(entry, []byte(`console.log("`+strings.Repeat("budget ", 200)+`");`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	build := func(budgets []SizeBudget) api.BuildResult {
+		return api.Build(api.BuildOptions{
+			EntryPoints:   []string{entry},
+			AbsWorkingDir: dir,
+			Outdir:        filepath.Join(dir, "dist"),
+			EntryNames:    "[name]-[hash]",
+			Write:         true,
+			Plugins:       []api.Plugin{sizeBudgetPlugin(budgets, []string{"gzip", "br"})},
+		})
+	}
+	result := build([]SizeBudget{{Path: "main.js", Raw: 4096, Gzip: 200}})
+	if len(result.Errors) > 0 {
+		t.Fatalf("unexpected error: %v", result.Errors[0].Text)
+	}
+	out := result.OutputFiles[0].Path
+	for _, ext := range []string{".gz", ".br"} {
+		if _, err := os.Stat(out + ext); err != nil {
+			t.Errorf("missing precompressed output: %v", err)
+		}
+	}
+	result = build([]SizeBudget{{Path: "*.js", Raw: 100, Brotli: 1 << 20}})
+	if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Text, "raw") || strings.Contains(result.Errors[0].Text, "brotli") {
+		t.Fatalf("expected raw budget failure, got %v", result.Errors)
+	}
+}
@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// BuildCacheConfig 跨进程保留的构建缓存：插件 OnLoad 的输出、npm 归档内容与包元数据
+type BuildCacheConfig struct {
+	Dir      string `json:"dir"`      // 缓存目录，默认为用户缓存目录下的 units/build
+	Disabled bool   `json:"disabled"` // 不读取也不写入缓存，npm 元数据重新获取
+	Stats    bool   `json:"stats"`    // 每次构建结束后输出缓存统计
+}
+
+// cacheKinds 缓存的内容类型，统计按此顺序输出
+var cacheKinds = []string{"load", "npm-archive", "packument"}
+
+// cacheCounter 单类缓存的命中统计
+type cacheCounter struct {
+	hits, misses, writes atomic.Int64
+}
+
+// buildCache 持久化构建缓存，nil 或禁用时所有操作都不生效
+type buildCache struct {
+	dir         string
+	fingerprint string // 影响插件输出的构建选项与 units 可执行文件
+	disabled    bool
+	counters    map[string]*cacheCounter
+}
+
+func newBuildCache(config *BuildCacheConfig, fingerprint string) *buildCache {
+	c := &buildCache{fingerprint: fingerprint, counters: make(map[string]*cacheCounter)}
+	for _, kind := range cacheKinds {
+		c.counters[kind] = &cacheCounter{}
+	}
+	if config != nil {
+		c.dir = config.Dir
+		c.disabled = config.Disabled
+	}
+	if c.dir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			c.dir = filepath.Join(dir, "units", "build")
+		} else {
+			c.dir = filepath.Join(os.TempDir(), "units-build")
+		}
+	}
+	return c
+}
+
+func (c *buildCache) enabled() bool {
+	return c != nil && !c.disabled
+}
+
+// cacheFingerprint 计算构建选项的指纹，只与输出无关的选项（监听、钩子、预算等）不参与计算
+func cacheFingerprint(config EsbuildConfig) string {
+	config.Watch, config.WatchDelay, config.WatchMode = false, 0, ""
+	config.OnSuccess, config.OnFailure, config.HookDelay = nil, nil, 0
+	config.Budgets, config.Compress, config.Manifest = nil, nil, ""
+	config.Cache = nil
+	h := sha256.New()
+	data, _ := json.Marshal(config)
+	h.Write(data)
+	// 可执行文件变化时插件的实现可能已经改变
+	if exe, err := os.Executable(); err == nil {
+		if info, err := os.Stat(exe); err == nil {
+			fmt.Fprintf(h, "\x00%s\x00%d\x00%d", exe, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *buildCache) file(kind, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, kind, name[:2], name)
+}
+
+// get 读取缓存项并解码到 v，返回是否命中
+func (c *buildCache) get(kind, key string, v interface{}) bool {
+	if !c.enabled() {
+		return false
+	}
+	data, err := os.ReadFile(c.file(kind, key))
+	if err == nil && gob.NewDecoder(bytes.NewReader(data)).Decode(v) == nil {
+		return true
+	}
+	return false
+}
+
+// put 写入缓存项，失败时只记录日志
+func (c *buildCache) put(kind, key string, v interface{}) {
+	if !c.enabled() {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		log.Printf("cache: encode %s: %v", kind, err)
+		return
+	}
+	file := c.file(kind, key)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		log.Printf("cache: %v", err)
+		return
+	}
+	if err := writeFileAtomic(file, buf.Bytes(), 0644); err != nil {
+		log.Printf("cache: %v", err)
+		return
+	}
+	c.counters[kind].writes.Add(1)
+}
+
+// count 记录一次查找
+func (c *buildCache) count(kind string, hit bool) {
+	if !c.enabled() {
+		return
+	}
+	if hit {
+		c.counters[kind].hits.Add(1)
+	} else {
+		c.counters[kind].misses.Add(1)
+	}
+}
+
+// loadEntry 缓存的 OnLoad 结果，依赖的文件与目录内容不变时有效
+type loadEntry struct {
+	Deps       map[string]string // 文件或目录 -> 内容哈希
+	Contents   *string
+	ResolveDir string
+	Loader     api.Loader
+	PluginName string
+	WatchFiles []string
+	WatchDirs  []string
+}
+
+// valid 检查依赖是否发生变化
+func (e *loadEntry) valid() bool {
+	for dep, hash := range e.Deps {
+		if contentHash(dep) != hash {
+			return false
+		}
+	}
+	return true
+}
+
+// contentHash 文件内容的哈希，目录为其下所有路径的哈希（递归，只比较文件名），不存在时为空
+func contentHash(name string) string {
+	info, err := os.Stat(name)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	if info.IsDir() {
+		err := filepath.WalkDir(name, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && p != name && (d.Name() == "node_modules" || d.Name() == ".git") {
+				return filepath.SkipDir
+			}
+			fmt.Fprintf(h, "%s\x00%v\x00", p, d.IsDir())
+			return nil
+		})
+		if err != nil {
+			return ""
+		}
+	} else {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return ""
+		}
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// wrap 为插件的 OnLoad 回调加上缓存，按构建选项指纹、插件名与模块路径查找，
+// 模块文件及回调声明的 WatchFiles、WatchDirs 内容不变时直接返回缓存的结果
+func (c *buildCache) wrap(p api.Plugin) api.Plugin {
+	if !c.enabled() || p.Setup == nil {
+		return p
+	}
+	setup := p.Setup
+	p.Setup = func(build api.PluginBuild) {
+		onLoad := build.OnLoad
+		build.OnLoad = func(options api.OnLoadOptions, callback func(api.OnLoadArgs) (api.OnLoadResult, error)) {
+			onLoad(options, c.cachedLoad(p.Name+"\x00"+options.Filter+"\x00"+options.Namespace, callback))
+		}
+		setup(build)
+	}
+	return p
+}
+
+func (c *buildCache) cachedLoad(scope string, callback func(api.OnLoadArgs) (api.OnLoadResult, error)) func(api.OnLoadArgs) (api.OnLoadResult, error) {
+	return func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+		// 来自 OnResolve 的 PluginData 无法作为键
+		if args.PluginData != nil {
+			return callback(args)
+		}
+		with := make([]string, 0, len(args.With))
+		for k, v := range args.With {
+			with = append(with, k+"="+v)
+		}
+		sort.Strings(with)
+		key := strings.Join([]string{c.fingerprint, scope, args.Namespace, args.Path, args.Suffix, strings.Join(with, "&")}, "\x00")
+		var entry loadEntry
+		if c.get("load", key, &entry) && entry.valid() {
+			c.count("load", true)
+			return api.OnLoadResult{
+				PluginName: entry.PluginName,
+				Contents:   entry.Contents,
+				ResolveDir: entry.ResolveDir,
+				Loader:     entry.Loader,
+				WatchFiles: entry.WatchFiles,
+				WatchDirs:  entry.WatchDirs,
+			}, nil
+		}
+		c.count("load", false)
+		// 在回调读取之前计算模块文件的哈希，避免缓存读取之后才发生的修改
+		deps := make(map[string]string)
+		if info, err := os.Stat(args.Path); err == nil && info.Mode().IsRegular() {
+			deps[args.Path] = contentHash(args.Path)
+		}
+		result, err := callback(args)
+		if err != nil || len(result.Errors) > 0 || len(result.Warnings) > 0 || result.PluginData != nil {
+			return result, err
+		}
+		for _, dep := range append(append([]string(nil), result.WatchFiles...), result.WatchDirs...) {
+			if _, ok := deps[dep]; !ok {
+				deps[dep] = contentHash(dep)
+			}
+		}
+		c.put("load", key, loadEntry{
+			Deps:       deps,
+			Contents:   result.Contents,
+			ResolveDir: result.ResolveDir,
+			Loader:     result.Loader,
+			PluginName: result.PluginName,
+			WatchFiles: result.WatchFiles,
+			WatchDirs:  result.WatchDirs,
+		})
+		return result, nil
+	}
+}
+
+// statsPlugin 每次构建结束后输出缓存统计并清零
+func (c *buildCache) statsPlugin() api.Plugin {
+	return api.Plugin{
+		Name: "cache-stats",
+		Setup: func(build api.PluginBuild) {
+			build.OnStart(func() (api.OnStartResult, error) {
+				for _, counter := range c.counters {
+					counter.hits.Store(0)
+					counter.misses.Store(0)
+					counter.writes.Store(0)
+				}
+				return api.OnStartResult{}, nil
+			})
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				log.Print(c.report())
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
+
+// report 缓存统计：各类缓存的命中、未命中与写入次数以及磁盘占用
+func (c *buildCache) report() string {
+	if c.disabled {
+		return "Cache disabled"
+	}
+	var parts []string
+	for _, kind := range cacheKinds {
+		counter := c.counters[kind]
+		hits, misses := counter.hits.Load(), counter.misses.Load()
+		if hits+misses == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %d/%d hits (%.0f%%), %d writes", kind, hits, hits+misses, float64(hits)*100/float64(hits+misses), counter.writes.Load()))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no lookups")
+	}
+	entries, size := 0, int64(0)
+	_ = filepath.WalkDir(c.dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				entries++
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return fmt.Sprintf("Cache: %s; %d entries, %s in %s", strings.Join(parts, "; "), entries, formatBytes(int(size)), c.dir)
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestBuildCache(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/main.js":    "import text from './note.txt?raw';\nconst pages = import.meta.glob('./pages/*.js', { eager: true, import: 'default' });\nconsole.log(text, pages, __VERSION__);\n",
+		"src/note.txt":   "hello raw",
+		"src/pages/a.js": "export default 'page a'",
+	}
+	write := func(name, content string) {
+		writeTree(t, dir, map[string]string{name: content})
+	}
+	writeTree(t, dir, files)
+	var configs []PluginConfig
+	err := json.Unmarshal([]byte(`[
+		{"name": "raw"},
+		{"name": "replace", "options": {"rules": [{"filter": "main\\.js$", "search": "__VERSION__", "replace": "\"1.2.3\""}]}},
+		{"name": "glob-import"}
+	]`), &configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &BuildCacheConfig{Dir: filepath.Join(dir, ".cache")}
+	// 每次构建使用新的缓存实例，模拟重新启动
+	build := func(fingerprint string) (string, *buildCache) {
+		cache := newBuildCache(config, fingerprint)
+		plugins, err := configPlugins(configs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range plugins {
+			plugins[i] = cache.wrap(plugins[i])
+		}
+		result := api.Build(api.BuildOptions{
+			AbsWorkingDir: dir,
+			EntryPoints:   []string{"src/main.js"},
+			Outdir:        "dist",
+			Bundle:        true,
+			Format:        api.FormatESModule,
+			Plugins:       plugins,
+		})
+		if len(result.Errors) > 0 {
+			t.Fatal(result.Errors)
+		}
+		return string(result.OutputFiles[0].Contents), cache
+	}
+	loads := func(cache *buildCache) (int64, int64) {
+		return cache.counters["load"].hits.Load(), cache.counters["load"].misses.Load()
+	}
+
+	first, cache := build("a")
+	if hits, misses := loads(cache); hits != 0 || misses == 0 {
+		t.Errorf("first build: %d hits, %d misses", hits, misses)
+	}
+	second, cache := build("a")
+	if hits, misses := loads(cache); hits == 0 || misses != 0 {
+		t.Errorf("second build: %d hits, %d misses", hits, misses)
+	}
+	if first != second {
+		t.Errorf("cached output differs:\n%s\n%s", first, second)
+	}
+	if report := cache.report(); !strings.Contains(report, "load 3/3 hits") {
+		t.Errorf("unexpected report %q", report)
+	}
+
+	// 修改的文件与 glob 新增的文件使对应的缓存项失效
+	write("src/note.txt", "changed raw")
+	write("src/pages/b.js", "export default 'page b'")
+	out, cache := build("a")
+	if hits, misses := loads(cache); hits != 1 || misses != 3 {
+		t.Errorf("after change: %d hits, %d misses", hits, misses)
+	}
+	for _, want := range []string{"changed raw", "page b", `"1.2.3"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	// 构建选项改变时不复用
+	if _, cache = build("b"); func() bool { hits, _ := loads(cache); return hits != 0 }() {
+		t.Error("expected no hits with a different fingerprint")
+	}
+	config.Disabled = true
+	if _, cache = build("a"); cache.report() != "Cache disabled" {
+		t.Errorf("unexpected report %q", cache.report())
+	}
+}
@@ -0,0 +1,634 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// CSSConfig 样式相关的构建选项
+type CSSConfig struct {
+	Modules   *CSSModulesConfig `json:"modules"`
+	Lower     []string          `json:"lower"` // 无论目标如何都降级的特性：nesting、layer
+	Utilities *UtilityCSSConfig `json:"utilities"`
+}
+
+// CSSModulesConfig CSS 模块：按 local-css 加载并生成类名的类型声明
+type CSSModulesConfig struct {
+	Pattern string `json:"pattern"` // 作为 CSS 模块加载的文件（正则表达式），默认 \.module\.css$
+	Types   bool   `json:"types"`   // 在样式文件旁生成 .module.css.d.ts
+}
+
+// UtilityCSSConfig 扫描内容文件中的类名，按规则生成工具类样式，通过 Module 导入
+//
+//	{"content": ["src/**/*.{html,tsx}"], "rules": [{"pattern": "^m-(\\d+)$", "css": "margin: calc($1 * 0.25rem)"}]}
+type UtilityCSSConfig struct {
+	Module   string            `json:"module"`   // 导入生成样式的模块名，默认 virtual:utilities.css
+	Content  []string          `json:"content"`  // 需要扫描的文件 glob，相对工作目录
+	Rules    []UtilityRule     `json:"rules"`    // 按顺序匹配，先匹配的规则生效
+	Variants map[string]string `json:"variants"` // 前缀变体，例如 {"hover": "&:hover", "md": "@media (min-width: 768px)"}
+}
+
+// UtilityRule 工具类规则，CSS 中可以使用 $1、${name} 引用捕获组，$value 引用 values 中的值
+type UtilityRule struct {
+	Pattern string            `json:"pattern"`
+	CSS     string            `json:"css"`
+	Values  map[string]string `json:"values"` // 第一个捕获组的取值表，未列出的值不匹配
+
+	re *regexp.Regexp
+}
+
+const (
+	defaultCSSModulePattern = `\.module\.css$`
+	defaultUtilityModule    = "virtual:utilities.css"
+	utilityNamespace        = "utilities"
+)
+
+// layerSupport 支持 @layer（CSS 级联层）的最低版本
+var layerSupport = map[api.EngineName]string{
+	api.EngineChrome:  "99",
+	api.EngineEdge:    "99",
+	api.EngineFirefox: "97",
+	api.EngineSafari:  "15.4",
+	api.EngineIOS:     "15.4",
+	api.EngineOpera:   "85",
+}
+
+// applyCSSLowering 将强制降级的特性写入 supported，嵌套由 esbuild 按目标降级，返回是否需要展开 @layer
+func applyCSSLowering(options *api.BuildOptions, lower []string) (bool, error) {
+	layers := false
+	for _, feature := range lower {
+		switch feature {
+		case "nesting":
+			if options.Supported == nil {
+				options.Supported = make(map[string]bool)
+			}
+			options.Supported["nesting"] = false
+		case "layer":
+			layers = true
+		default:
+			return false, fmt.Errorf("unknown css feature %q to lower, expected nesting or layer", feature)
+		}
+	}
+	for _, engine := range options.Engines {
+		if min, ok := layerSupport[engine.Name]; ok && compareVersions(engine.Version, min) < 0 {
+			layers = true
+		}
+	}
+	return layers, nil
+}
+
+// cssPlugin CSS 模块类型声明、@layer 展开与工具类样式生成
+func cssPlugin(config *CSSConfig, lowerLayers bool) (api.Plugin, error) {
+	var modules *regexp.Regexp
+	if config.Modules != nil {
+		pattern := config.Modules.Pattern
+		if pattern == "" {
+			pattern = defaultCSSModulePattern
+		}
+		var err error
+		if modules, err = regexp.Compile(pattern); err != nil {
+			return api.Plugin{}, fmt.Errorf("invalid css modules pattern: %v", err)
+		}
+	}
+	utilities := config.Utilities
+	if utilities != nil {
+		if utilities.Module == "" {
+			utilities.Module = defaultUtilityModule
+		}
+		for i := range utilities.Rules {
+			r := &utilities.Rules[i]
+			var err error
+			if r.re, err = regexp.Compile(r.Pattern); err != nil {
+				return api.Plugin{}, fmt.Errorf("invalid utility pattern %q: %v", r.Pattern, err)
+			}
+		}
+	}
+	return api.Plugin{
+		Name: "css",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			workDir := buildWorkDir(options)
+			customModules := modules != nil && modules.String() != defaultCSSModulePattern
+			if lowerLayers || customModules {
+				build.OnLoad(api.OnLoadOptions{Filter: `\.css$`, Namespace: "file"}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					module := customModules && modules.MatchString(filepath.ToSlash(args.Path))
+					if !lowerLayers && !module {
+						return api.OnLoadResult{}, nil
+					}
+					data, err := os.ReadFile(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+					contents := string(data)
+					if lowerLayers {
+						contents = flattenLayers(contents)
+					}
+					loader := api.LoaderDefault
+					if module {
+						loader = api.LoaderLocalCSS
+					}
+					return api.OnLoadResult{Contents: &contents, Loader: loader}, nil
+				})
+			}
+			if utilities != nil {
+				build.OnResolve(api.OnResolveOptions{Filter: "^" + regexp.QuoteMeta(utilities.Module) + "$"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+					return api.OnResolveResult{Path: utilities.Module, Namespace: utilityNamespace}, nil
+				})
+				build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: utilityNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+					css, files, err := utilities.generate(workDir)
+					if err != nil {
+						return api.OnLoadResult{}, err
+					}
+					if lowerLayers {
+						css = flattenLayers(css)
+					}
+					// 监听内容目录，新增的文件同样触发重新生成
+					var dirs []string
+					for _, pattern := range utilities.Content {
+						if !filepath.IsAbs(pattern) {
+							pattern = filepath.Join(workDir, pattern)
+						}
+						dirs = append(dirs, globBase(pattern))
+					}
+					return api.OnLoadResult{Contents: &css, Loader: api.LoaderCSS, ResolveDir: workDir, WatchFiles: files, WatchDirs: dirs}, nil
+				})
+			}
+			if modules != nil && config.Modules.Types {
+				build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+					if len(result.Errors) > 0 || result.Metafile == "" {
+						return api.OnEndResult{}, nil
+					}
+					meta, err := parseMetafile(result.Metafile)
+					if err != nil {
+						return api.OnEndResult{}, err
+					}
+					for input := range meta.Inputs {
+						if strings.Contains(input, ":") || !modules.MatchString(input) {
+							continue
+						}
+						file := filepath.Join(workDir, filepath.FromSlash(input))
+						if err := writeCSSModuleTypes(file); err != nil {
+							return api.OnEndResult{}, err
+						}
+					}
+					return api.OnEndResult{}, nil
+				})
+			}
+		},
+	}, nil
+}
+
+// writeCSSModuleTypes 生成 CSS 模块的类型声明，内容不变时不写入，避免监听模式下重复构建
+func writeCSSModuleTypes(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var sb strings.Builder
+	sb.WriteString("declare const styles: {\n")
+	names := cssModuleNames(string(data))
+	for _, name := range names {
+		fmt.Fprintf(&sb, "  readonly %q: string;\n", name)
+	}
+	sb.WriteString("};\nexport default styles;\n")
+	for _, name := range names {
+		if isJSIdentifier(name) {
+			fmt.Fprintf(&sb, "export declare const %s: string;\n", name)
+		}
+	}
+	types := file + ".d.ts"
+	if old, err := os.ReadFile(types); err == nil && string(old) == sb.String() {
+		return nil
+	}
+	return writeFileAtomic(types, []byte(sb.String()), 0644)
+}
+
+// isJSIdentifier 判断名称能否作为具名导出
+func isJSIdentifier(name string) bool {
+	if name == "" || tsOperatorKeywords[name] || tsRegexpKeywords[name] || name == "default" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || i > 0 && isDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// cssBlocks 遍历样式中的块与语句，跳过注释与字符串；prelude 为 { 之前的选择器或 at 规则，
+// 回调的 depth 为 prelude 所在的层级
+func cssBlocks(css string, prelude func(text string, depth int)) {
+	depth := 0
+	start := 0
+	for i := 0; i < len(css); i++ {
+		switch c := css[i]; c {
+		case '/':
+			if strings.HasPrefix(css[i:], "/*") {
+				end := strings.Index(css[i+2:], "*/")
+				if end < 0 {
+					return
+				}
+				i += end + 3
+			}
+		case '"', '\'':
+			for i++; i < len(css) && css[i] != c; i++ {
+				if css[i] == '\\' {
+					i++
+				}
+			}
+		case '\\':
+			i++
+		case '{':
+			prelude(css[start:i], depth)
+			depth++
+			start = i + 1
+		case '}':
+			depth--
+			start = i + 1
+		case ';':
+			start = i + 1
+		}
+	}
+}
+
+var (
+	cssCommentPattern  = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	cssGlobalPattern   = regexp.MustCompile(`:global\([^)]*\)`)
+	cssClassPattern    = regexp.MustCompile(`\.((?:[A-Za-z_]|-[A-Za-z_-]|\\.)(?:[\w-]|\\.)*)`)
+	cssKeyframesPrefix = regexp.MustCompile(`^@(?:-\w+-)?keyframes\s+`)
+)
+
+// cssModuleNames 提取 CSS 模块导出的本地名称：选择器中的类名与 @keyframes 名称，:global 中的除外
+func cssModuleNames(css string) []string {
+	set := make(map[string]bool)
+	cssBlocks(css, func(text string, _ int) {
+		text = strings.TrimSpace(cssCommentPattern.ReplaceAllString(text, ""))
+		if strings.HasPrefix(text, "@") {
+			if loc := cssKeyframesPrefix.FindStringIndex(text); loc != nil {
+				if name := strings.TrimSpace(text[loc[1]:]); isCSSIdent(name) {
+					set[name] = true
+				}
+			}
+			return
+		}
+		text = cssGlobalPattern.ReplaceAllString(stripCSSAttributes(text), "")
+		if i := strings.Index(text, ":global"); i >= 0 {
+			text = text[:i]
+		}
+		for _, m := range cssClassPattern.FindAllStringSubmatch(text, -1) {
+			set[cssUnescape(m[1])] = true
+		}
+	})
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stripCSSAttributes 去掉选择器中的属性选择器与字符串，其中的 .x 不是类名
+func stripCSSAttributes(selector string) string {
+	var sb strings.Builder
+	depth := 0
+	for i := 0; i < len(selector); i++ {
+		switch c := selector[i]; c {
+		case '\\':
+			if depth == 0 {
+				sb.WriteByte(c)
+				if i+1 < len(selector) {
+					sb.WriteByte(selector[i+1])
+				}
+			}
+			i++
+		case '"', '\'':
+			for i++; i < len(selector) && selector[i] != c; i++ {
+				if selector[i] == '\\' {
+					i++
+				}
+			}
+		case '[':
+			depth++
+		case ']':
+			if depth > 0 {
+				depth--
+			}
+		default:
+			if depth == 0 {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func isCSSIdent(name string) bool {
+	return name != "" && cssClassPattern.MatchString("."+name) && cssClassPattern.FindString("."+name) == "."+name
+}
+
+// cssUnescape 去掉类名中的转义，例如 sm\:p-2 -> sm:p-2
+func cssUnescape(name string) string {
+	if !strings.Contains(name, "\\") {
+		return name
+	}
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) {
+			i++
+		}
+		sb.WriteByte(name[i])
+	}
+	return sb.String()
+}
+
+// cssEscape 转义类名以用于选择器
+func cssEscape(name string) string {
+	var sb strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '-' || c >= 0x80:
+			sb.WriteByte(c)
+		case isDigit(c):
+			if i == 0 {
+				fmt.Fprintf(&sb, "\\3%c ", c)
+			} else {
+				sb.WriteByte(c)
+			}
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// cssConditionPrefix 条件分组规则，其中的 @layer 展开后仍包裹在原来的条件中
+var cssConditionPrefix = regexp.MustCompile(`^@(?:media|supports|container)\b`)
+
+// flattenLayers 为不支持级联层的目标展开 @layer：按层声明的顺序输出各层内容，
+// 未分层的样式放在最后以保持其更高的优先级，@charset 与 @import 保持在最前面；
+// @media 等条件规则中的层同样展开，内容在对应层的位置以原来的条件包裹
+func flattenLayers(css string) string {
+	var (
+		head, rest []string
+		order      []string
+		layers     = make(map[string][]string)
+		anonymous  int
+	)
+	addLayer := func(name string) {
+		if _, ok := layers[name]; !ok {
+			layers[name] = nil
+			order = append(order, name)
+		}
+	}
+	// wrap 以外层在前的条件包裹规则
+	wrap := func(text string, conditions []string) string {
+		for i := len(conditions) - 1; i >= 0; i-- {
+			text = conditions[i] + " {\n" + text + "\n}"
+		}
+		return text
+	}
+	var walk func(css, parent string, conditions []string)
+	walk = func(css, parent string, conditions []string) {
+		for _, item := range cssTopLevel(css) {
+			text := strings.TrimSpace(item.prelude)
+			switch {
+			case item.block && cssConditionPrefix.MatchString(text) && hasLayer(item.body):
+				walk(item.body, parent, append(conditions[:len(conditions):len(conditions)], text))
+			case strings.HasPrefix(text, "@layer") && !item.block:
+				for _, name := range strings.Split(strings.TrimSpace(strings.TrimPrefix(text, "@layer")), ",") {
+					addLayer(joinLayer(parent, strings.TrimSpace(name)))
+				}
+			case strings.HasPrefix(text, "@layer") && item.block:
+				name := strings.TrimSpace(strings.TrimPrefix(text, "@layer"))
+				if name == "" {
+					anonymous++
+					name = fmt.Sprintf("<anonymous-%d>", anonymous)
+				}
+				name = joinLayer(parent, name)
+				addLayer(name)
+				walk(item.body, name, conditions)
+			case parent != "":
+				layers[parent] = append(layers[parent], wrap(item.text, conditions))
+			case !item.block && (strings.HasPrefix(text, "@import") || strings.HasPrefix(text, "@charset")) && len(rest) == 0 && len(conditions) == 0:
+				head = append(head, item.text)
+			default:
+				rest = append(rest, wrap(item.text, conditions))
+			}
+		}
+	}
+	walk(css, "", nil)
+	if len(order) == 0 {
+		return css
+	}
+	var out []string
+	out = append(out, head...)
+	for _, name := range order {
+		out = append(out, layers[name]...)
+	}
+	out = append(out, rest...)
+	return strings.Join(out, "\n") + "\n"
+}
+
+// hasLayer 判断样式中是否有 @layer，包括条件规则之中的
+func hasLayer(css string) bool {
+	for _, item := range cssTopLevel(css) {
+		text := strings.TrimSpace(item.prelude)
+		if strings.HasPrefix(text, "@layer") || item.block && cssConditionPrefix.MatchString(text) && hasLayer(item.body) {
+			return true
+		}
+	}
+	return false
+}
+
+func joinLayer(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// cssItem 顶层的规则或语句
+type cssItem struct {
+	text    string // 完整文本
+	prelude string // { 或 ; 之前的部分
+	body    string // 块的内容
+	block   bool
+}
+
+// cssTopLevel 拆分样式中的顶层规则与语句
+func cssTopLevel(css string) []cssItem {
+	var items []cssItem
+	depth, start, open := 0, 0, 0
+	for i := 0; i < len(css); i++ {
+		switch c := css[i]; c {
+		case '/':
+			if strings.HasPrefix(css[i:], "/*") {
+				end := strings.Index(css[i+2:], "*/")
+				if end < 0 {
+					i = len(css)
+					break
+				}
+				if depth == 0 && strings.TrimSpace(css[start:i]) == "" {
+					start = i + end + 4
+				}
+				i += end + 3
+			}
+		case '"', '\'':
+			for i++; i < len(css) && css[i] != c; i++ {
+				if css[i] == '\\' {
+					i++
+				}
+			}
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				items = append(items, cssItem{
+					text:    strings.TrimSpace(css[start : i+1]),
+					prelude: css[start:open],
+					body:    css[open+1 : i],
+					block:   true,
+				})
+				start = i + 1
+			}
+		case ';':
+			if depth == 0 {
+				items = append(items, cssItem{text: strings.TrimSpace(css[start : i+1]), prelude: css[start:i]})
+				start = i + 1
+			}
+		}
+	}
+	if text := strings.TrimSpace(css[start:]); text != "" {
+		items = append(items, cssItem{text: text, prelude: text})
+	}
+	return items
+}
+
+// utilityCandidate 匹配内容文件中可能的类名
+var utilityCandidate = regexp.MustCompile(`[A-Za-z0-9_\-:/.\[\]%#!@]+`)
+
+// generate 扫描内容文件并生成工具类样式，返回样式与扫描的文件
+func (u *UtilityCSSConfig) generate(workDir string) (string, []string, error) {
+	var files []string
+	for _, pattern := range u.Content {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(workDir, pattern)
+		}
+		matched, err := expandGlob(pattern)
+		if err != nil {
+			return "", nil, fmt.Errorf("scan %s: %v", pattern, err)
+		}
+		files = append(files, matched...)
+	}
+	sort.Strings(files)
+	candidates := make(map[string]bool)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, c := range utilityCandidate.FindAllString(string(data), -1) {
+			candidates[strings.TrimRight(c, ".:")] = true
+		}
+	}
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// 每条规则的输出按类名排序，规则之间保持配置顺序；带媒体查询的变体放在最后
+	rules := make([][]string, len(u.Rules))
+	var media []string
+	for _, name := range names {
+		variants, utility := u.splitVariants(name)
+		if utility == "" {
+			continue
+		}
+		for i := range u.Rules {
+			css, ok := u.Rules[i].expand(utility)
+			if !ok {
+				continue
+			}
+			selector := "." + cssEscape(name)
+			var wrappers []string
+			for _, v := range variants {
+				if strings.HasPrefix(v, "@") {
+					wrappers = append(wrappers, v)
+				} else {
+					selector = strings.ReplaceAll(v, "&", selector)
+				}
+			}
+			rule := selector + " { " + css + " }"
+			if len(wrappers) == 0 {
+				rules[i] = append(rules[i], rule)
+			} else {
+				for j := len(wrappers) - 1; j >= 0; j-- {
+					rule = wrappers[j] + " { " + rule + " }"
+				}
+				media = append(media, rule)
+			}
+			break
+		}
+	}
+	var sb strings.Builder
+	for _, r := range rules {
+		for _, rule := range r {
+			sb.WriteString(rule + "\n")
+		}
+	}
+	for _, rule := range media {
+		sb.WriteString(rule + "\n")
+	}
+	return sb.String(), files, nil
+}
+
+// splitVariants 拆分 md:hover:p-4 中的变体前缀，存在未知变体时返回空
+func (u *UtilityCSSConfig) splitVariants(name string) ([]string, string) {
+	parts := strings.Split(name, ":")
+	var variants []string
+	for _, p := range parts[:len(parts)-1] {
+		v, ok := u.Variants[p]
+		if !ok {
+			return nil, ""
+		}
+		variants = append(variants, v)
+	}
+	return variants, parts[len(parts)-1]
+}
+
+// expand 按规则生成声明
+func (r *UtilityRule) expand(utility string) (string, bool) {
+	m := r.re.FindStringSubmatchIndex(utility)
+	if m == nil {
+		return "", false
+	}
+	template := r.CSS
+	if r.Values != nil {
+		if len(m) < 4 || m[2] < 0 {
+			return "", false
+		}
+		value, ok := r.Values[utility[m[2]:m[3]]]
+		if !ok {
+			return "", false
+		}
+		template = strings.ReplaceAll(template, "$value", strings.ReplaceAll(value, "$", "$$"))
+	}
+	css := string(r.re.ExpandString(nil, template, utility, m))
+	return strings.TrimSuffix(strings.TrimSpace(css), ";") + ";", true
+}
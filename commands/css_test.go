@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestFlattenLayers(t *testing.T) {
+	src := `@charset "utf-8";
+@import "reset.css";
+@layer base, components;
+.loose { color: red }
+@layer components {
+  .btn { color: blue }
+  @layer inner { .x { a: b } }
+}
+@layer base { html { margin: 0 } }
+`
+	want := `@charset "utf-8";
+@import "reset.css";
+html { margin: 0 }
+.btn { color: blue }
+.x { a: b }
+.loose { color: red }
+`
+	if got := flattenLayers(src); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if plain := ".a { b: c }"; flattenLayers(plain) != plain {
+		t.Error("css without layers should be unchanged")
+	}
+
+	// 条件规则中的层
+	src = `@layer base, theme;
+@media (prefers-color-scheme: dark) {
+  @layer theme { body { color: white } }
+  .dark { a: b }
+}
+@supports (display: grid) { @layer base { .grid { display: grid } } }
+@media print { .p { a: b } }
+`
+	want = `@supports (display: grid) {
+.grid { display: grid }
+}
+@media (prefers-color-scheme: dark) {
+body { color: white }
+}
+@media (prefers-color-scheme: dark) {
+.dark { a: b }
+}
+@media print { .p { a: b } }
+`
+	if got := flattenLayers(src); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCSSModuleNames(t *testing.T) {
+	src := `/* .comment {} */
+.card, .card-title:hover > .icon { color: red }
+:global(.external) .inner { }
+.sm\:p-2 { content: ".quoted" }
+.z[data-x='.no'], a[href$=".pdf"].file { }
+@media (min-width: 1px) { .wide { } }
+@keyframes fade { from { opacity: 0 } }
+:global .ignored { }
+`
+	got := strings.Join(cssModuleNames(src), ",")
+	if want := "card,card-title,fade,file,icon,inner,sm:p-2,wide,z"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestCSSPlugin(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/index.js":          "import styles from './button.module.css';\nimport 'virtual:utilities.css';\nimport './base.css';\nconsole.log(styles.primary);\n",
+		"src/button.module.css": ".primary { color: red }\n.is-active { color: blue }\n",
+		"src/base.css":          "@layer base { body { margin: 0 } }\n.app { color: green }\n",
+		"src/page.html":         `<div class="m-4 hover:m-2 md:text-lg bg-red unknown"></div>`,
+	}
+	writeTree(t, dir, files)
+	config := &CSSConfig{
+		Modules: &CSSModulesConfig{Types: true},
+		Utilities: &UtilityCSSConfig{
+			Content: []string{"src/**/*.html"},
+			Rules: []UtilityRule{
+				{Pattern: `^m-(\d+)$`, CSS: "margin: calc($1 * 0.25rem)"},
+				{Pattern: `^text-(\w+)$`, CSS: "font-size: $value", Values: map[string]string{"lg": "1.125rem"}},
+				{Pattern: `^bg-(red|blue)$`, CSS: "background: $1"},
+			},
+			Variants: map[string]string{"hover": "&:hover", "md": "@media (min-width: 768px)"},
+		},
+	}
+	options := api.BuildOptions{
+		AbsWorkingDir: dir,
+		EntryPoints:   []string{"src/index.js"},
+		Outdir:        "dist",
+		Bundle:        true,
+		Metafile:      true,
+		Write:         true,
+		Engines:       []api.Engine{{Name: api.EngineChrome, Version: "90"}},
+	}
+	layers, err := applyCSSLowering(&options, []string{"nesting"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !layers || options.Supported["nesting"] {
+		t.Fatalf("expected layer and nesting lowering for chrome90, got %v %v", layers, options.Supported)
+	}
+	plugin, err := cssPlugin(config, layers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.Plugins = []api.Plugin{plugin}
+	if result := api.Build(options); len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+
+	types, err := os.ReadFile(filepath.Join(dir, "src", "button.module.css.d.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTypes := `declare const styles: {
+  readonly "is-active": string;
+  readonly "primary": string;
+};
+export default styles;
+export declare const primary: string;
+`
+	if string(types) != wantTypes {
+		t.Errorf("types:\n%s\nwant:\n%s", types, wantTypes)
+	}
+
+	css, err := os.ReadFile(filepath.Join(dir, "dist", "index.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(css)
+	for _, want := range []string{".m-4 {", ".hover\\:m-2:hover {", "@media (min-width: 768px)", ".md\\:text-lg {", "font-size: 1.125rem", "background: red"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "@layer") || strings.Contains(out, "unknown") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	if strings.Index(out, "margin: 0") > strings.Index(out, "color: green") {
+		t.Errorf("layered rules should precede unlayered ones:\n%s", out)
+	}
+}
@@ -0,0 +1,1493 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// 与 TypeScript --isolatedDeclarations 一致的错误信息
+const (
+	dtsErrFunction  = "Function must have an explicit return type annotation with --isolatedDeclarations"
+	dtsErrMethod    = "Method must have an explicit return type annotation with --isolatedDeclarations"
+	dtsErrParameter = "Parameter must have an explicit type annotation with --isolatedDeclarations"
+	dtsErrVariable  = "Variable must have an explicit type annotation with --isolatedDeclarations"
+	dtsErrProperty  = "Property must have an explicit type annotation with --isolatedDeclarations"
+	dtsErrAccessor  = "At least one accessor must have an explicit type annotation with --isolatedDeclarations"
+	dtsErrDefault   = "Default exports can't be inferred with --isolatedDeclarations"
+	dtsErrBinding   = "Binding elements can't be exported directly with --isolatedDeclarations"
+	dtsErrExtends   = "Extends clause can't contain an expression with --isolatedDeclarations"
+)
+
+var (
+	// tsOperatorKeywords 其后必须跟随操作数的关键字，不能结束一个表达式
+	tsOperatorKeywords = map[string]bool{
+		"typeof": true, "keyof": true, "new": true, "void": true, "delete": true, "await": true, "yield": true,
+		"extends": true, "implements": true, "in": true, "of": true, "instanceof": true, "as": true, "satisfies": true,
+		"is": true, "infer": true, "readonly": true, "unique": true, "asserts": true, "case": true, "throw": true,
+		"return": true, "export": true, "default": true, "declare": true, "abstract": true, "async": true,
+		"import": true, "const": true, "let": true, "var": true, "function": true, "class": true,
+		"interface": true, "type": true, "enum": true, "namespace": true, "module": true, "from": true,
+	}
+	// tsBinaryKeywords 可以连接两个操作数的关键字
+	tsBinaryKeywords = map[string]bool{
+		"as": true, "satisfies": true, "instanceof": true, "in": true, "extends": true, "is": true,
+	}
+	tsMemberModifiers = map[string]bool{
+		"public": true, "private": true, "protected": true, "static": true, "readonly": true, "abstract": true,
+		"override": true, "declare": true, "accessor": true, "async": true,
+	}
+	tsParamModifiers = map[string]bool{
+		"public": true, "private": true, "protected": true, "readonly": true, "override": true,
+	}
+)
+
+// dtsStatement 声明文件中的一条语句
+type dtsStatement struct {
+	text     string
+	doc      string
+	names    []string // 声明的名称，用于裁剪未被引用的非导出声明
+	exported bool
+	ambient  bool // declare global 与 declare module "x" 增强，总是保留
+	imports  *dtsImport
+	errs     []*tsError // 语句被导出或被引用时报告
+}
+
+// dtsImport import 语句，输出时只保留被声明引用的绑定
+type dtsImport struct {
+	typeOnly    bool
+	defaultName string
+	namespace   string
+	specifiers  []dtsSpecifier
+	from        string
+}
+
+type dtsSpecifier struct {
+	text  string
+	local string
+}
+
+func (imp *dtsImport) render(used map[string]bool) string {
+	var parts []string
+	if imp.defaultName != "" && used[imp.defaultName] {
+		parts = append(parts, imp.defaultName)
+	}
+	if imp.namespace != "" && used[imp.namespace] {
+		parts = append(parts, "* as "+imp.namespace)
+	}
+	var specs []string
+	for _, s := range imp.specifiers {
+		if used[s.local] {
+			specs = append(specs, s.text)
+		}
+	}
+	if len(specs) > 0 {
+		parts = append(parts, "{ "+strings.Join(specs, ", ")+" }")
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	keyword := "import "
+	if imp.typeOnly {
+		keyword = "import type "
+	}
+	return keyword + strings.Join(parts, ", ") + " from " + imp.from + ";"
+}
+
+// dtsParam 函数参数
+type dtsParam struct {
+	text      string
+	modifiers []string // 构造函数参数属性的修饰符
+	name      string
+	typ       string
+	optional  bool
+}
+
+// dtsSignature 函数签名
+type dtsSignature struct {
+	typeParams string
+	params     []dtsParam
+	ret        string
+}
+
+func (s *dtsSignature) paramList() string {
+	texts := make([]string, len(s.params))
+	for i, p := range s.params {
+		texts[i] = p.text
+	}
+	return "(" + strings.Join(texts, ", ") + ")"
+}
+
+// declaration 用于函数声明或方法
+func (s *dtsSignature) declaration() string {
+	if s.ret == "" {
+		return s.typeParams + s.paramList()
+	}
+	return s.typeParams + s.paramList() + ": " + s.ret
+}
+
+// functionType 用于变量的函数类型
+func (s *dtsSignature) functionType() string {
+	return s.typeParams + s.paramList() + " => " + s.ret
+}
+
+// dtsEmitter 从 TypeScript 源码生成声明，只依赖源码中显式的类型标注（isolatedDeclarations），不做类型检查
+type dtsEmitter struct {
+	src       string
+	toks      []tsToken
+	ambient   bool            // 位于 namespace 内部，声明不需要 declare
+	overloads map[string]bool // 已出现重载签名的函数，其实现不输出
+}
+
+// emitDeclarations 生成源码对应的 .d.ts 内容，导出缺少显式类型时返回错误
+func emitDeclarations(src string, jsx bool) (string, []*tsError) {
+	toks, err := scanTS(src, jsx)
+	if err != nil {
+		return "", []*tsError{err.(*tsError)}
+	}
+	e := &dtsEmitter{src: src, toks: toks, overloads: make(map[string]bool)}
+	dts, errs := e.render(e.statements(0, len(toks)-1), true)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].pos < errs[j].pos })
+	return dts, errs
+}
+
+func (e *dtsEmitter) tok(i int) tsToken {
+	if i < 0 || i >= len(e.toks) {
+		return e.toks[len(e.toks)-1]
+	}
+	return e.toks[i]
+}
+
+// is 判断第 i 个记号是否为给定的标识符或符号
+func (e *dtsEmitter) is(i int, texts ...string) bool {
+	t := e.tok(i)
+	if t.kind != tsIdent && t.kind != tsPunct {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *dtsEmitter) ident(i int) bool {
+	return e.tok(i).kind == tsIdent
+}
+
+// raw 返回记号 [a, b) 对应的原始源码
+func (e *dtsEmitter) raw(a, b int) string {
+	if a >= b {
+		return ""
+	}
+	return e.src[e.toks[a].pos:e.toks[b-1].end]
+}
+
+// join 拼接记号 [a, b)，原有的空白与注释合并为一个空格
+func (e *dtsEmitter) join(a, b int) string {
+	var sb strings.Builder
+	for k := a; k < b; k++ {
+		if k > a && e.toks[k].pos > e.toks[k-1].end {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(e.toks[k].text)
+	}
+	return sb.String()
+}
+
+func (e *dtsEmitter) errorAt(i int, text string) *tsError {
+	return &tsError{pos: e.tok(i).pos, text: text}
+}
+
+// skip 返回从第 i 个记号开始的括号组之后的位置，组以 ( [ { < 开始
+func (e *dtsEmitter) skip(i int) int {
+	angle := e.is(i, "<")
+	depth := 0
+	for ; e.toks[i].kind != tsEOF; i++ {
+		t := e.toks[i]
+		if t.kind != tsPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case "<":
+			if angle {
+				depth++
+			}
+		case ">":
+			if angle {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return i + 1
+		}
+	}
+	return i
+}
+
+// until 返回 [i, end) 中第一个位于同一层级且满足 stop 的记号位置，
+// 遇到未配对的右括号时返回其位置；types 为 true 时尖括号也计入层级
+func (e *dtsEmitter) until(i, end int, types bool, stop func(int) bool) int {
+	depth := 0
+	for ; i < end; i++ {
+		if depth == 0 && stop(i) {
+			return i
+		}
+		t := e.toks[i]
+		if t.kind != tsPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth < 0 {
+				return i
+			}
+		case "<":
+			if types {
+				depth++
+			}
+		case ">":
+			if types && depth > 0 {
+				depth--
+			}
+		}
+	}
+	return end
+}
+
+// operandEnd 判断第 i 个记号能否结束一个表达式或类型
+func (e *dtsEmitter) operandEnd(i int) bool {
+	t := e.tok(i)
+	switch t.kind {
+	case tsIdent:
+		if t.text == "void" {
+			return e.voidType(i)
+		}
+		return !tsOperatorKeywords[t.text]
+	case tsNumber, tsString, tsTemplate, tsRegexp:
+		return true
+	case tsPunct:
+		return t.text == ")" || t.text == "]" || t.text == "}" || t.text == ">"
+	}
+	return false
+}
+
+// voidType 判断第 i 个记号 void 是否为类型：位于类型标注或类型运算之后，且同一行中没有跟随操作数，
+// 否则为表达式中的 void 运算符（例如 x ? y : void 0）
+func (e *dtsEmitter) voidType(i int) bool {
+	if !e.is(i-1, ":", "=>", "|", "&", "<", ",") {
+		return false
+	}
+	next := e.tok(i + 1)
+	if next.nl {
+		return true
+	}
+	switch next.kind {
+	case tsIdent, tsNumber, tsString, tsTemplate, tsRegexp:
+		return false
+	case tsPunct:
+		return !e.is(i+1, "(", "[", "!", "-", "+", "~")
+	}
+	return true
+}
+
+// startsStatement 判断换行后的第 k 个记号是否开始新的语句（自动插入分号）
+func (e *dtsEmitter) startsStatement(k int) bool {
+	t := e.toks[k]
+	if !t.nl || !e.operandEnd(k-1) {
+		return false
+	}
+	switch t.kind {
+	case tsIdent:
+		return !tsBinaryKeywords[t.text]
+	case tsNumber, tsString:
+		return true
+	case tsPunct:
+		return t.text == "@" || t.text == "[" || t.text == "*"
+	}
+	return false
+}
+
+// statementEnd 返回从 i 开始的语句之后的位置
+func (e *dtsEmitter) statementEnd(i, end int, types bool) int {
+	j := e.until(i, end, types, func(k int) bool {
+		return k > i && (e.is(k, ";") || e.startsStatement(k))
+	})
+	if j < end && e.is(j, ";") {
+		return j + 1
+	}
+	return j
+}
+
+// statements 生成 [i, end) 中每条语句的声明
+func (e *dtsEmitter) statements(i, end int) []*dtsStatement {
+	var stmts []*dtsStatement
+	for i < end {
+		if e.is(i, ";") {
+			i++
+			continue
+		}
+		doc := e.toks[i].doc
+		next, stmt := e.statement(i, end)
+		if stmt != nil {
+			stmt.doc = doc
+			stmts = append(stmts, stmt)
+		}
+		if next <= i {
+			next = i + 1
+		}
+		i = next
+	}
+	return stmts
+}
+
+// terminate 为语句补充分号
+func terminate(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.HasSuffix(text, ";") || strings.HasSuffix(text, "}") {
+		return text
+	}
+	return text + ";"
+}
+
+func (e *dtsEmitter) declare() string {
+	if e.ambient {
+		return ""
+	}
+	return "declare "
+}
+
+func (e *dtsEmitter) statement(start, end int) (int, *dtsStatement) {
+	i := start
+	exported, isDefault := false, false
+	if e.is(i, "export") {
+		exported = true
+		i++
+		switch {
+		case e.is(i, "*", "{", "=", "import", "as") || e.is(i, "type") && e.is(i+1, "{", "*"):
+			// export * from、export { a }、export = a、export import A = B、export as namespace A
+			j := e.statementEnd(i, end, false)
+			return j, &dtsStatement{text: terminate(e.raw(start, j)), exported: true}
+		case e.is(i, "default"):
+			isDefault = true
+			i++
+		}
+	}
+	prefix := ""
+	switch {
+	case isDefault:
+		prefix = "export default "
+	case exported:
+		prefix = "export "
+	}
+	if e.is(i, "declare") && e.ident(i+1) {
+		j := e.statementEnd(i, end, true)
+		if e.is(i+1, "global") || e.is(i+1, "module") && e.tok(i+2).kind == tsString {
+			return j, &dtsStatement{text: terminate(prefix + e.raw(i, j)), exported: exported, ambient: true}
+		}
+		return j, &dtsStatement{text: terminate(prefix + e.raw(i, j)), names: e.declaredNames(i + 1), exported: exported}
+	}
+	abstract := false
+	if e.is(i, "abstract") && e.is(i+1, "class") {
+		abstract = true
+		i++
+	}
+	if e.is(i, "async") && e.is(i+1, "function") && !e.tok(i+1).nl {
+		i++
+	}
+	switch {
+	case e.is(i, "function"):
+		return e.function(i, end, prefix, exported, isDefault)
+	case e.is(i, "class"):
+		return e.class(i, end, prefix, exported, isDefault, abstract)
+	case e.is(i, "interface") && e.ident(i+1):
+		j := e.skip(e.until(i, end, true, func(k int) bool { return e.is(k, "{") }))
+		return j, &dtsStatement{text: prefix + e.raw(i, j), names: []string{e.tok(i + 1).text}, exported: exported}
+	case e.is(i, "type") && e.ident(i+1) && !isDefault:
+		j := e.statementEnd(i, end, true)
+		return j, &dtsStatement{text: terminate(prefix + e.raw(i, j)), names: []string{e.tok(i + 1).text}, exported: exported}
+	case e.is(i, "enum") || e.is(i, "const") && e.is(i+1, "enum"):
+		j := e.skip(e.until(i, end, true, func(k int) bool { return e.is(k, "{") }))
+		return j, &dtsStatement{text: prefix + e.declare() + e.raw(i, j), names: e.declaredNames(i), exported: exported}
+	case e.is(i, "namespace", "module") && e.ident(i+1) && !isDefault:
+		return e.namespace(i, end, prefix, exported)
+	case e.is(i, "const", "let", "var") && !isDefault && (e.ident(i+1) || e.is(i+1, "{", "[")):
+		return e.variables(i, end, prefix, exported)
+	case e.is(i, "import") && !exported && !e.is(i+1, "(", "."):
+		return e.importDeclaration(i, end)
+	case isDefault:
+		return e.defaultExport(i, end)
+	}
+	j := e.statementEnd(i, end, false)
+	if exported {
+		return j, &dtsStatement{exported: true, errs: []*tsError{e.errorAt(i, "unsupported export")}}
+	}
+	return j, nil
+}
+
+// declaredNames 返回 declare 之后声明的名称
+func (e *dtsEmitter) declaredNames(i int) []string {
+	for e.is(i, "const", "abstract", "async", "function", "class", "interface", "type", "enum", "namespace", "module", "let", "var") && e.ident(i+1) {
+		i++
+	}
+	if e.ident(i) {
+		return []string{e.tok(i).text}
+	}
+	return nil
+}
+
+// function 函数声明，重载签名之后的实现不输出
+func (e *dtsEmitter) function(i, end int, prefix string, exported, isDefault bool) (int, *dtsStatement) {
+	at := i
+	i++
+	if e.is(i, "*") {
+		i++
+	}
+	name := ""
+	if e.ident(i) {
+		name = e.tok(i).text
+		at = i
+		i++
+	}
+	sig, j, body, errs := e.signature(i, end, at, dtsErrFunction)
+	if sig == nil {
+		return j, &dtsStatement{exported: exported, errs: errs}
+	}
+	if body && name != "" && e.overloads[name] {
+		delete(e.overloads, name)
+		return j, nil
+	}
+	if !body && name != "" {
+		e.overloads[name] = true
+	}
+	declare := e.declare()
+	if isDefault {
+		declare = ""
+	}
+	text := prefix + declare + "function " + name + sig.declaration() + ";"
+	return j, &dtsStatement{text: text, names: []string{name}, exported: exported, errs: errs}
+}
+
+// signature 解析 <T>(params): R 及其后的函数体，what 为缺少返回类型时的错误信息
+func (e *dtsEmitter) signature(i, end, at int, what string) (*dtsSignature, int, bool, []*tsError) {
+	sig := &dtsSignature{}
+	var errs []*tsError
+	if e.is(i, "<") {
+		j := e.skip(i)
+		sig.typeParams = e.join(i, j)
+		i = j
+	}
+	if !e.is(i, "(") {
+		return nil, e.statementEnd(i, end, false), false, []*tsError{e.errorAt(i, "expected (")}
+	}
+	close := e.skip(i)
+	sig.params, errs = e.params(i+1, close-1)
+	i = close
+	if e.is(i, ":") {
+		j := e.until(i+1, end, true, func(k int) bool {
+			return e.is(k, "{") && e.operandEnd(k-1) || e.is(k, ";", "=>") || e.startsStatement(k)
+		})
+		sig.ret = e.join(i+1, j)
+		i = j
+	} else if what != "" {
+		errs = append(errs, e.errorAt(at, what))
+		sig.ret = "any"
+	}
+	if e.is(i, "{") {
+		return sig, e.skip(i), true, errs
+	}
+	if e.is(i, ";") {
+		i++
+	}
+	return sig, i, false, errs
+}
+
+// params 解析参数列表 [a, b)
+func (e *dtsEmitter) params(a, b int) ([]dtsParam, []*tsError) {
+	var params []dtsParam
+	var errs []*tsError
+	for i := a; i < b; {
+		j := e.until(i, b, true, func(k int) bool { return e.is(k, ",") })
+		if j > i {
+			p, err := e.param(i, j)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			params = append(params, p)
+		}
+		i = j + 1
+	}
+	return params, errs
+}
+
+func (e *dtsEmitter) param(i, j int) (dtsParam, *tsError) {
+	var p dtsParam
+	var err *tsError
+	for e.is(i, "@") {
+		i = e.decorator(i)
+	}
+	for e.ident(i) && tsParamModifiers[e.tok(i).text] && (e.ident(i+1) || e.is(i+1, "{", "[")) {
+		p.modifiers = append(p.modifiers, e.tok(i).text)
+		i++
+	}
+	rest := e.is(i, "...")
+	if rest {
+		i++
+	}
+	at := i
+	binding := ""
+	if e.is(i, "{", "[") {
+		k := e.skip(i)
+		binding = e.pattern(i, k)
+		i = k
+	} else {
+		p.name = e.tok(i).text
+		binding = p.name
+		i++
+	}
+	if e.is(i, "?") {
+		p.optional = true
+		i++
+	}
+	if e.is(i, ":") {
+		k := e.until(i+1, j, true, func(k int) bool { return e.is(k, "=") })
+		p.typ = e.join(i+1, k)
+		i = k
+	}
+	if e.is(i, "=") && i < j {
+		p.optional = !rest
+		if p.typ == "" {
+			p.typ = e.literalType(i+1, j, true)
+		}
+	}
+	if p.typ == "" {
+		err = e.errorAt(at, dtsErrParameter)
+		p.typ = "any"
+	}
+	if rest {
+		binding = "..." + binding
+	}
+	if p.optional {
+		binding += "?"
+	}
+	p.text = binding + ": " + p.typ
+	return p, err
+}
+
+// pattern 输出解构参数，去掉其中的默认值
+func (e *dtsEmitter) pattern(a, b int) string {
+	var sb strings.Builder
+	for k := a; k < b; k++ {
+		if e.is(k, "=") {
+			k = e.until(k+1, b, false, func(m int) bool { return e.is(m, ",") }) - 1
+			continue
+		}
+		if k > a && e.toks[k].pos > e.toks[k-1].end {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(e.toks[k].text)
+	}
+	return sb.String()
+}
+
+// decorator 跳过 @decorator(...)
+func (e *dtsEmitter) decorator(i int) int {
+	i++
+	if e.is(i, "(") {
+		return e.skip(i)
+	}
+	i++
+	for e.is(i, ".") {
+		i += 2
+	}
+	if e.is(i, "<") {
+		i = e.skip(i)
+	}
+	if e.is(i, "(") {
+		i = e.skip(i)
+	}
+	return i
+}
+
+// literalType 推断字面量初始值 [a, b) 的类型，widen 为 true 时放宽为基础类型（let、参数默认值）
+func (e *dtsEmitter) literalType(a, b int, widen bool) string {
+	sign := ""
+	if b-a == 2 && e.is(a, "-") && e.tok(a+1).kind == tsNumber {
+		sign = "-"
+		a++
+	}
+	if b-a != 1 {
+		return ""
+	}
+	t := e.toks[a]
+	switch {
+	case t.kind == tsNumber && strings.HasSuffix(t.text, "n"):
+		if widen {
+			return "bigint"
+		}
+		return sign + t.text
+	case t.kind == tsNumber:
+		if widen {
+			return "number"
+		}
+		return sign + t.text
+	case t.kind == tsString && sign == "":
+		if widen {
+			return "string"
+		}
+		return t.text
+	case t.kind == tsTemplate && sign == "" && !strings.Contains(t.text, "${"):
+		return "string"
+	case t.kind == tsIdent && sign == "" && (t.text == "true" || t.text == "false"):
+		if widen {
+			return "boolean"
+		}
+		return t.text
+	}
+	return ""
+}
+
+// inferType 从初始值 [a, b) 推断类型：字面量、带完整类型标注的函数、类型断言、
+// 成员均可推断的对象字面量，以及 as const 的字面量、对象与数组
+func (e *dtsEmitter) inferType(a, b int, widen bool) (string, []*tsError) {
+	if t := e.literalType(a, b, widen); t != "" {
+		return t, nil
+	}
+	if t, errs, ok := e.functionType(a, b); ok {
+		return t, errs
+	}
+	last := -1
+	for k := a; k < b; {
+		m := e.until(k, b, false, func(m int) bool { return e.is(m, "as") })
+		if m >= b || !e.is(m, "as") {
+			break
+		}
+		last, k = m, m+1
+	}
+	if last > a && last+2 == b && e.is(last+1, "const") {
+		return e.constType(a, last)
+	}
+	if last > a && last+1 < b {
+		return e.join(last+1, b), nil
+	}
+	if e.is(a, "{") && e.skip(a) == b {
+		return e.objectType(a, b, false)
+	}
+	return "", nil
+}
+
+// constType 推断 as const 的表达式 [a, b) 的类型：字面量保持字面量类型，对象与数组为只读
+func (e *dtsEmitter) constType(a, b int) (string, []*tsError) {
+	switch {
+	case b-a == 1 && e.tok(a).kind == tsTemplate:
+		// 模板字符串的字面量类型需要转换引号，不推断
+		return "", nil
+	case e.is(a, "{") && e.skip(a) == b:
+		return e.objectType(a, b, true)
+	case e.is(a, "[") && e.skip(a) == b:
+		var types []string
+		var errs []*tsError
+		for k := a + 1; k < b-1; {
+			m := e.until(k, b-1, false, func(m int) bool { return e.is(m, ",") })
+			if m == k || e.is(k, "...") {
+				// 空位与展开无法推断
+				return "", nil
+			}
+			t, err := e.constType(k, m)
+			if t == "" {
+				return "", nil
+			}
+			types = append(types, t)
+			errs = append(errs, err...)
+			k = m + 1
+		}
+		return "readonly [" + strings.Join(types, ", ") + "]", errs
+	}
+	return e.inferType(a, b, false)
+}
+
+// objectType 推断对象字面量 [a, b) 的类型，成员需为带名称的属性或方法且值可以推断；
+// 展开、简写属性与计算属性名无法推断。readonly 用于 as const，属性保持字面量类型
+func (e *dtsEmitter) objectType(a, b int, readonly bool) (string, []*tsError) {
+	var members []string
+	var errs []*tsError
+	for k := a + 1; k < b-1; {
+		m := e.until(k, b-1, false, func(m int) bool { return e.is(m, ",") })
+		if m == k {
+			k = m + 1
+			continue
+		}
+		t := e.tok(k)
+		if t.kind != tsIdent && t.kind != tsString && t.kind != tsNumber || e.is(k, "...") {
+			return "", nil
+		}
+		key := t.text
+		switch {
+		case e.is(k+1, ":"):
+			var typ string
+			var err []*tsError
+			if readonly {
+				typ, err = e.constType(k+2, m)
+			} else {
+				typ, err = e.inferType(k+2, m, true)
+			}
+			if typ == "" {
+				return "", nil
+			}
+			errs = append(errs, err...)
+			if readonly {
+				key = "readonly " + key
+			}
+			members = append(members, key+": "+typ)
+		case e.is(k+1, "(", "<"):
+			sig, j, body, err := e.signature(k+1, m, k, dtsErrMethod)
+			if sig == nil || !body || j != m {
+				return "", nil
+			}
+			errs = append(errs, err...)
+			members = append(members, key+sig.declaration())
+		default:
+			// 简写属性、访问器等
+			return "", nil
+		}
+		k = m + 1
+	}
+	if len(members) == 0 {
+		return "{}", errs
+	}
+	return "{ " + strings.Join(members, "; ") + "; }", errs
+}
+
+// functionType 由箭头函数或函数表达式推断函数类型
+func (e *dtsEmitter) functionType(a, b int) (string, []*tsError, bool) {
+	i := a
+	if e.is(i, "async") && !e.tok(i+1).nl {
+		i++
+	}
+	arrow := true
+	if e.is(i, "function") {
+		arrow = false
+		i++
+		if e.is(i, "*") {
+			i++
+		}
+		if e.ident(i) {
+			i++
+		}
+	}
+	if arrow && e.ident(i) && e.is(i+1, "=>") {
+		return "", []*tsError{e.errorAt(i, dtsErrParameter)}, true
+	}
+	if !e.is(i, "(", "<") {
+		return "", nil, false
+	}
+	sig, j, _, errs := e.signature(i, b, a, dtsErrFunction)
+	if sig == nil {
+		return "", nil, false
+	}
+	if arrow {
+		if !e.is(j, "=>") {
+			return "", nil, false
+		}
+	} else if j != b {
+		return "", nil, false
+	}
+	return sig.functionType(), errs, true
+}
+
+// variables 变量声明，每个变量单独输出一条声明
+func (e *dtsEmitter) variables(i, end int, prefix string, exported bool) (int, *dtsStatement) {
+	kind := e.tok(i).text
+	j := e.statementEnd(i, end, false)
+	stmt := &dtsStatement{exported: exported}
+	var lines []string
+	k := i + 1
+	for k < j && !e.is(k, ";") {
+		at := k
+		if e.is(k, "{", "[") {
+			stmt.errs = append(stmt.errs, e.errorAt(at, dtsErrBinding))
+			k = e.until(e.skip(k), j, false, func(m int) bool { return e.is(m, ",", ";") }) + 1
+			continue
+		}
+		name := e.tok(k).text
+		k++
+		if e.is(k, "!") {
+			k++
+		}
+		typ := ""
+		if e.is(k, ":") {
+			t := e.until(k+1, j, true, func(m int) bool { return e.is(m, "=", ",", ";") })
+			typ = e.join(k+1, t)
+			k = t
+		}
+		if e.is(k, "=") {
+			init := k + 1
+			k = e.until(init, j, false, func(m int) bool { return e.is(m, ";") || e.is(m, ",") && e.declaratorStart(m+1, j) })
+			if typ == "" {
+				var errs []*tsError
+				typ, errs = e.inferType(init, k, kind != "const")
+				stmt.errs = append(stmt.errs, errs...)
+			}
+		}
+		if typ == "" {
+			stmt.errs = append(stmt.errs, e.errorAt(at, dtsErrVariable))
+			typ = "any"
+		}
+		lines = append(lines, prefix+e.declare()+kind+" "+name+": "+typ+";")
+		stmt.names = append(stmt.names, name)
+		if e.is(k, ",") {
+			k++
+		}
+	}
+	stmt.text = strings.Join(lines, "\n")
+	return j, stmt
+}
+
+// declaratorStart 判断逗号之后是否为下一个变量而不是初始值中的逗号，例如 f<A, B>()
+func (e *dtsEmitter) declaratorStart(k, end int) bool {
+	if e.is(k, "{", "[") {
+		return true
+	}
+	return e.ident(k) && (k+1 >= end || e.is(k+1, ":", "=", ",", ";", "!"))
+}
+
+// defaultExport export default 表达式
+func (e *dtsEmitter) defaultExport(i, end int) (int, *dtsStatement) {
+	j := e.statementEnd(i, end, false)
+	k := j
+	if k > i && e.is(k-1, ";") {
+		k--
+	}
+	if k == i+1 && e.ident(i) {
+		return j, &dtsStatement{text: "export default " + e.tok(i).text + ";", exported: true}
+	}
+	typ, errs := e.inferType(i, k, false)
+	if typ == "" && len(errs) == 0 {
+		errs = append(errs, e.errorAt(i, dtsErrDefault))
+		typ = "any"
+	}
+	text := e.declare() + "const _default: " + typ + ";\nexport default _default;"
+	return j, &dtsStatement{text: text, names: []string{"_default"}, exported: true, errs: errs}
+}
+
+// class 类声明，成员只保留签名，私有成员不输出类型
+func (e *dtsEmitter) class(i, end int, prefix string, exported, isDefault, abstract bool) (int, *dtsStatement) {
+	i++
+	name := ""
+	if e.ident(i) && !e.is(i, "extends", "implements") {
+		name = e.tok(i).text
+		i++
+	}
+	head := name
+	if e.is(i, "<") {
+		j := e.skip(i)
+		head += e.join(i, j)
+		i = j
+	}
+	stmt := &dtsStatement{names: []string{name}, exported: exported}
+	body := e.until(i, end, true, func(k int) bool { return e.is(k, "{") })
+	heritage := e.join(i, body)
+	if e.is(i, "extends") {
+		k := i + 1
+		for k < body && !e.is(k, "implements") {
+			switch {
+			case e.ident(k) || e.is(k, "."):
+				k++
+			case e.is(k, "<"):
+				k = e.skip(k)
+			default:
+				stmt.errs = append(stmt.errs, e.errorAt(i+1, dtsErrExtends))
+				k = body
+			}
+		}
+	}
+	bodyEnd := e.skip(body)
+	members, errs := e.members(body+1, bodyEnd-1)
+	stmt.errs = append(stmt.errs, errs...)
+
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	if !isDefault {
+		sb.WriteString(e.declare())
+	}
+	if abstract {
+		sb.WriteString("abstract ")
+	}
+	sb.WriteString("class")
+	if head != "" {
+		sb.WriteString(" " + head)
+	}
+	if heritage != "" {
+		sb.WriteString(" " + heritage)
+	}
+	sb.WriteString(" {\n")
+	for _, m := range members {
+		sb.WriteString(indentLines(m, "    "))
+	}
+	sb.WriteString("}")
+	stmt.text = sb.String()
+	return bodyEnd, stmt
+}
+
+// classAccessor 记录访问器的类型，getter 未标注时使用 setter 参数的类型
+type classAccessor struct {
+	line     int // getter 在成员中的位置
+	prefix   string
+	at       int
+	typ      string
+	getter   bool
+	inferred string
+}
+
+// members 生成类成员的声明 [a, b)
+func (e *dtsEmitter) members(a, b int) ([]string, []*tsError) {
+	var lines []string
+	var errs []*tsError
+	hasPrivate := false
+	private := make(map[string]bool)
+	overloads := make(map[string]bool)
+	accessors := make(map[string]*classAccessor)
+	for i := a; i < b; {
+		if e.is(i, ";") {
+			i++
+			continue
+		}
+		doc := docComment(e.toks[i].doc)
+		for e.is(i, "@") {
+			i = e.decorator(i)
+		}
+		var mods []string
+		for e.ident(i) && tsMemberModifiers[e.tok(i).text] && e.memberName(i+1) {
+			mods = append(mods, e.tok(i).text)
+			i++
+		}
+		if e.is(i, "static") && e.is(i+1, "{") {
+			i = e.skip(i + 1)
+			continue
+		}
+		accessor := ""
+		if e.is(i, "get", "set") && e.memberName(i+1) {
+			accessor = e.tok(i).text
+			i++
+		}
+		if e.is(i, "*") {
+			i++
+		}
+		at := i
+		name := ""
+		switch {
+		case e.is(i, "["):
+			j := e.skip(i)
+			if e.until(i+1, j-1, true, func(k int) bool { return e.is(k, ":") }) < j-1 {
+				// 索引签名
+				k := e.memberEnd(i, b, true)
+				lines = append(lines, doc+joinModifiers(mods)+terminate(e.join(i, k)))
+				i = k
+				continue
+			}
+			name = e.join(i, j)
+			i = j
+		case e.ident(i), e.tok(i).kind == tsString, e.tok(i).kind == tsNumber:
+			name = e.tok(i).text
+			i++
+		default:
+			i++
+			continue
+		}
+		isPrivate := contains(mods, "private")
+		optional := ""
+		if e.is(i, "?") {
+			optional = "?"
+			i++
+		} else if e.is(i, "!") {
+			i++
+		}
+		if e.is(i, "(", "<") {
+			what := dtsErrMethod
+			if name == "constructor" || accessor != "" || isPrivate || strings.HasPrefix(name, "#") {
+				what = ""
+			}
+			sig, next, body, serrs := e.signature(i, b, at, what)
+			i = next
+			if sig == nil {
+				errs = append(errs, serrs...)
+				continue
+			}
+			key := strings.Join(mods, " ") + " " + name
+			if body && overloads[key] {
+				delete(overloads, key)
+				continue
+			}
+			if !body {
+				overloads[key] = true
+			}
+			switch {
+			case strings.HasPrefix(name, "#"):
+				hasPrivate = true
+			case isPrivate && name != "constructor":
+				if !private[name] {
+					private[name] = true
+					lines = append(lines, joinModifiers(mods)+name+";")
+				}
+			case name == "constructor":
+				var props []string
+				for _, p := range sig.params {
+					switch {
+					case len(p.modifiers) == 0:
+					case contains(p.modifiers, "private"):
+						props = append(props, joinModifiers(p.modifiers)+p.name+";")
+					default:
+						props = append(props, joinModifiers(p.modifiers)+p.text+";")
+					}
+				}
+				if isPrivate {
+					sig.params = nil
+				}
+				lines = append(lines, props...)
+				lines = append(lines, doc+joinModifiers(mods)+"constructor"+sig.paramList()+";")
+				if !isPrivate {
+					errs = append(errs, serrs...)
+				}
+			case accessor != "":
+				errs = append(errs, serrs...)
+				acc := accessors[name]
+				if acc == nil {
+					acc = &classAccessor{line: -1}
+					accessors[name] = acc
+				}
+				if accessor == "get" {
+					acc.line, acc.prefix, acc.at, acc.getter = len(lines), doc+joinModifiers(mods), at, true
+					acc.typ = sig.ret
+					lines = append(lines, "")
+				} else {
+					if len(sig.params) > 0 {
+						acc.inferred = sig.params[0].typ
+					}
+					lines = append(lines, doc+joinModifiers(mods)+"set "+name+sig.paramList()+";")
+				}
+			default:
+				errs = append(errs, serrs...)
+				lines = append(lines, doc+joinModifiers(mods)+name+optional+sig.declaration()+";")
+			}
+			continue
+		}
+		// 属性
+		k := e.memberEnd(i, b, false)
+		typ := ""
+		if e.is(i, ":") {
+			t := e.until(i+1, k, true, func(m int) bool { return e.is(m, "=", ";") })
+			typ = e.join(i+1, t)
+			i = t
+		}
+		if e.is(i, "=") && typ == "" && !isPrivate {
+			init, stop := i+1, k
+			if stop > init && e.is(stop-1, ";") {
+				stop--
+			}
+			var ierrs []*tsError
+			typ, ierrs = e.inferType(init, stop, !contains(mods, "readonly"))
+			errs = append(errs, ierrs...)
+		}
+		i = k
+		switch {
+		case strings.HasPrefix(name, "#"):
+			hasPrivate = true
+		case isPrivate:
+			if !private[name] {
+				private[name] = true
+				lines = append(lines, joinModifiers(mods)+name+optional+";")
+			}
+		default:
+			if typ == "" {
+				errs = append(errs, e.errorAt(at, dtsErrProperty))
+				typ = "any"
+			}
+			lines = append(lines, doc+joinModifiers(mods)+name+optional+": "+typ+";")
+		}
+	}
+	for name, acc := range accessors {
+		if !acc.getter {
+			continue
+		}
+		typ := acc.typ
+		if typ == "" {
+			typ = acc.inferred
+		}
+		if typ == "" {
+			errs = append(errs, e.errorAt(acc.at, dtsErrAccessor))
+			typ = "any"
+		}
+		lines[acc.line] = acc.prefix + "get " + name + "(): " + typ + ";"
+	}
+	if hasPrivate {
+		lines = append([]string{"#private;"}, lines...)
+	}
+	return lines, errs
+}
+
+// memberName 判断第 i 个记号能否作为类成员名称，用于区分修饰符与同名成员
+func (e *dtsEmitter) memberName(i int) bool {
+	t := e.tok(i)
+	if t.nl && !e.ident(i) {
+		return false
+	}
+	return t.kind == tsIdent || t.kind == tsString || t.kind == tsNumber || e.is(i, "[", "*")
+}
+
+// memberEnd 返回从 i 开始的属性或索引签名之后的位置
+func (e *dtsEmitter) memberEnd(i, end int, types bool) int {
+	j := e.until(i, end, types, func(k int) bool {
+		return k > i && (e.is(k, ";") || e.startsStatement(k))
+	})
+	if j < end && e.is(j, ";") {
+		return j + 1
+	}
+	return j
+}
+
+// namespace 命名空间，内部语句递归生成，只保留导出与被引用的声明
+func (e *dtsEmitter) namespace(i, end int, prefix string, exported bool) (int, *dtsStatement) {
+	kw := e.tok(i).text
+	open := e.until(i+1, end, false, func(k int) bool { return e.is(k, "{") })
+	if !e.is(open, "{") {
+		j := e.statementEnd(i, end, false)
+		return j, &dtsStatement{text: terminate(prefix + e.declare() + e.raw(i, j)), names: []string{e.tok(i + 1).text}, exported: exported}
+	}
+	close := e.skip(open)
+	inner := &dtsEmitter{src: e.src, toks: e.toks, ambient: true, overloads: make(map[string]bool)}
+	body, errs := inner.render(inner.statements(open+1, close-1), false)
+	text := prefix + e.declare() + kw + " " + e.join(i+1, open) + " {\n" + indentLines(body, "    ") + "}"
+	return close, &dtsStatement{text: text, names: []string{e.tok(i + 1).text}, exported: exported, errs: errs}
+}
+
+// importDeclaration import 语句，副作用导入不输出
+func (e *dtsEmitter) importDeclaration(i, end int) (int, *dtsStatement) {
+	j := e.statementEnd(i, end, false)
+	k := i + 1
+	if e.tok(k).kind == tsString {
+		return j, nil
+	}
+	if e.ident(k) && e.is(k+1, "=") || e.is(k, "type") && e.ident(k+1) && e.is(k+2, "=") {
+		// import A = require('a')、import A = N.B
+		name := e.tok(k).text
+		if e.is(k, "type") {
+			name = e.tok(k + 1).text
+		}
+		return j, &dtsStatement{text: terminate(e.raw(i, j)), names: []string{name}}
+	}
+	imp := &dtsImport{}
+	if e.is(k, "type") && !e.is(k+1, "from", ",") {
+		imp.typeOnly = true
+		k++
+	}
+	if e.ident(k) && !e.is(k, "from") || e.is(k, "from") && e.is(k+1, "from", ",") {
+		imp.defaultName = e.tok(k).text
+		k++
+		if e.is(k, ",") {
+			k++
+		}
+	}
+	if e.is(k, "*") && e.is(k+1, "as") {
+		imp.namespace = e.tok(k + 2).text
+		k += 3
+	}
+	if e.is(k, "{") {
+		close := e.skip(k)
+		for s := k + 1; s < close-1; {
+			t := e.until(s, close-1, false, func(m int) bool { return e.is(m, ",") })
+			if t > s {
+				imp.specifiers = append(imp.specifiers, dtsSpecifier{text: e.join(s, t), local: e.tok(t - 1).text})
+			}
+			s = t + 1
+		}
+		k = close
+	}
+	if e.is(k, "from") {
+		stop := j
+		if stop > k && e.is(stop-1, ";") {
+			stop--
+		}
+		imp.from = e.join(k+1, stop)
+	}
+	return j, &dtsStatement{imports: imp}
+}
+
+// render 输出声明，裁剪未导出且未被引用的声明与导入，返回内容及被保留的语句中的错误
+func (e *dtsEmitter) render(stmts []*dtsStatement, module bool) (string, []*tsError) {
+	kept := make([]bool, len(stmts))
+	declared := make(map[string][]int)
+	var queue []int
+	for i, st := range stmts {
+		for _, name := range st.names {
+			declared[name] = append(declared[name], i)
+		}
+		if st.exported || st.ambient {
+			kept[i] = true
+			queue = append(queue, i)
+		}
+	}
+	used := make(map[string]bool)
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, ref := range tsIdentifiers(stmts[i].text) {
+			used[ref] = true
+			for _, d := range declared[ref] {
+				if !kept[d] {
+					kept[d] = true
+					queue = append(queue, d)
+				}
+			}
+		}
+	}
+	var sb strings.Builder
+	var errs []*tsError
+	hasModuleSyntax, local := false, false
+	for i, st := range stmts {
+		if st.imports != nil {
+			hasModuleSyntax = true
+			if line := st.imports.render(used); line != "" {
+				sb.WriteString(line + "\n")
+			}
+			continue
+		}
+		if st.exported {
+			hasModuleSyntax = true
+		}
+		if !kept[i] {
+			continue
+		}
+		errs = append(errs, st.errs...)
+		if st.text == "" {
+			continue
+		}
+		if !st.exported {
+			local = true
+		}
+		sb.WriteString(docComment(st.doc) + st.text + "\n")
+	}
+	if module && hasModuleSyntax && local {
+		sb.WriteString("export {};\n")
+	}
+	return sb.String(), errs
+}
+
+// tsIdentifiers 返回声明文本中引用的标识符（不含属性访问）
+func tsIdentifiers(text string) []string {
+	toks, err := scanTS(text, false)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for i, t := range toks {
+		if t.kind == tsIdent && (i == 0 || toks[i-1].text != ".") {
+			names = append(names, t.text)
+		}
+	}
+	return names
+}
+
+// docComment 将 /** */ 注释整理为以换行结尾的文本，续行对齐到 *
+func docComment(doc string) string {
+	if doc == "" {
+		return ""
+	}
+	lines := strings.Split(doc, "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = " " + strings.TrimSpace(lines[i])
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// indentLines 为每一行添加缩进
+func indentLines(text, indent string) string {
+	if text == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func joinModifiers(mods []string) string {
+	var sb strings.Builder
+	for _, m := range mods {
+		if m == "async" || m == "declare" || m == "override" {
+			continue
+		}
+		sb.WriteString(m + " ")
+	}
+	return sb.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isDeclarationSource 判断 metafile 中的输入是否需要生成声明
+func isDeclarationSource(input string) bool {
+	if strings.Contains(input, ":") || strings.Contains(input, "node_modules/") {
+		return false
+	}
+	for _, ext := range []string{".d.ts", ".d.mts", ".d.cts"} {
+		if strings.HasSuffix(input, ext) {
+			return false
+		}
+	}
+	switch filepath.Ext(input) {
+	case ".ts", ".tsx", ".mts", ".cts":
+		return true
+	}
+	return false
+}
+
+// declarationName 源文件对应的声明文件名：.ts/.tsx -> .d.ts，.mts -> .d.mts，.cts -> .d.cts
+func declarationName(rel string) string {
+	ext := filepath.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+	switch ext {
+	case ".mts":
+		return base + ".d.mts"
+	case ".cts":
+		return base + ".d.cts"
+	}
+	return base + ".d.ts"
+}
+
+// declarationRoot 声明文件目录结构的根：outbase 或所有入口的最近公共目录，与 esbuild 的输出结构一致
+func declarationRoot(options *api.BuildOptions) string {
+	workDir := buildWorkDir(options)
+	abs := func(p string) string {
+		if filepath.IsAbs(p) {
+			return filepath.Clean(p)
+		}
+		return filepath.Join(workDir, p)
+	}
+	if options.Outbase != "" {
+		return abs(options.Outbase)
+	}
+	root := ""
+	for _, entry := range options.EntryPoints {
+		if i := strings.IndexAny(entry, "*?["); i >= 0 {
+			entry = entry[:i] + "x"
+		}
+		dir := filepath.Dir(abs(entry))
+		if root == "" {
+			root = dir
+			continue
+		}
+		for {
+			rel, err := filepath.Rel(root, dir)
+			if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.Dir(root) == root {
+				break
+			}
+			root = filepath.Dir(root)
+		}
+	}
+	if root == "" {
+		return workDir
+	}
+	return root
+}
+
+// tsMessage 将源码中的错误转换为 esbuild 消息
+func tsMessage(file, src string, err *tsError) api.Message {
+	pos := err.pos
+	if pos > len(src) {
+		pos = len(src)
+	}
+	lineStart := strings.LastIndexByte(src[:pos], '\n') + 1
+	lineEnd := strings.IndexByte(src[pos:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(src)
+	} else {
+		lineEnd += pos
+	}
+	return api.Message{
+		Text: err.text,
+		Location: &api.Location{
+			File:     file,
+			Line:     strings.Count(src[:pos], "\n") + 1,
+			Column:   pos - lineStart,
+			LineText: strings.TrimSuffix(src[lineStart:lineEnd], "\r"),
+		},
+	}
+}
+
+// declarationPlugin 构建成功后为参与构建的 TypeScript 源文件生成 .d.ts，
+// 按入口的公共目录写入 dir，与 units types 输出的目录结构一致；导出缺少显式类型时构建失败
+func declarationPlugin(dir string) api.Plugin {
+	return api.Plugin{
+		Name: "declarations",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			workDir := buildWorkDir(options)
+			root := declarationRoot(options)
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				if len(result.Errors) > 0 || result.Metafile == "" {
+					return api.OnEndResult{}, nil
+				}
+				meta, err := parseMetafile(result.Metafile)
+				if err != nil {
+					return api.OnEndResult{}, err
+				}
+				var inputs []string
+				for input := range meta.Inputs {
+					if isDeclarationSource(input) {
+						inputs = append(inputs, input)
+					}
+				}
+				sort.Strings(inputs)
+				var res api.OnEndResult
+				outputs := make(map[string]string, len(inputs))
+				for _, input := range inputs {
+					file := filepath.Join(workDir, filepath.FromSlash(input))
+					data, err := os.ReadFile(file)
+					if err != nil {
+						return api.OnEndResult{}, err
+					}
+					src := string(data)
+					dts, errs := emitDeclarations(src, strings.HasSuffix(input, ".tsx"))
+					for _, err := range errs {
+						res.Errors = append(res.Errors, tsMessage(input, src, err))
+					}
+					rel, err := filepath.Rel(root, file)
+					if err != nil || strings.HasPrefix(rel, "..") {
+						res.Warnings = append(res.Warnings, api.Message{Text: "declaration skipped, file is outside of " + root, Location: &api.Location{File: input}})
+						continue
+					}
+					outputs[declarationName(filepath.ToSlash(rel))] = dts
+				}
+				if len(res.Errors) > 0 {
+					return res, nil
+				}
+				for name, dts := range outputs {
+					if err := writeTyping(dir, name, strings.NewReader(dts)); err != nil {
+						return api.OnEndResult{}, err
+					}
+				}
+				return res, nil
+			})
+		},
+	}
+}
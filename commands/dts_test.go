@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestEmitDeclarations(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+		errs []string
+	}{
+		{
+			name: "functions",
+			src: `import { Foo, unused } from './foo';
+import './style.css';
+/** adds */
+export function add(a: number, b = 2, { c = 1 }: Foo = {}): number {
+  return a + b + /}/.source.length + ` + "`${ {c}.c }`" + `.length;
+}
+export function over(a: string): string;
+export function over(a: number): number;
+export function over(a: any) { return a; }
+`,
+			want: `import { Foo } from './foo';
+/** adds */
+export declare function add(a: number, b?: number, { c }?: Foo): number;
+export declare function over(a: string): string;
+export declare function over(a: number): number;
+`,
+		},
+		{
+			name: "variables",
+			src: `import type { Req } from './req';
+interface Options { debug?: boolean }
+const internal = 5
+export const VERSION = "1.0.0", count: Map<string, number> = new Map();
+export let enabled = true;
+export const handler = async (req: Req, opts?: Options): Promise<void> => {};
+export const table = {} as Record<string, number>;
+export { internal as renamed };
+`,
+			want: `import type { Req } from './req';
+interface Options { debug?: boolean }
+declare const internal: 5;
+export declare const VERSION: "1.0.0";
+export declare const count: Map<string, number>;
+export declare let enabled: boolean;
+export declare const handler: (req: Req, opts?: Options) => Promise<void>;
+export declare const table: Record<string, number>;
+export { internal as renamed };
+export {};
+`,
+		},
+		{
+			name: "class",
+			src: `export class Store<T> extends Base<T> {
+  static readonly kind = 'store';
+  #secret = 1;
+  private cache = new Map();
+  items: T[] = [];
+  constructor(public readonly name: string, private id: number) { super(); }
+  get size(): number { return this.items.length; }
+  get label() { return ''; }
+  set label(v: string) {}
+  add(item: T): this { return this; }
+  private helper() {}
+}
+export default Store;
+`,
+			want: `export declare class Store<T> extends Base<T> {
+    #private;
+    static readonly kind: 'store';
+    private cache;
+    items: T[];
+    public readonly name: string;
+    private id;
+    constructor(name: string, id: number);
+    get size(): number;
+    get label(): string;
+    set label(v: string);
+    add(item: T): this;
+    private helper;
+}
+export default Store;
+`,
+		},
+		{
+			name: "namespace",
+			src: `export enum Color { Red = 1, Green }
+export namespace Util {
+  export const pi: number = 3.14;
+  function hidden() {}
+}
+export type Pair<T> = [T, T]
+`,
+			want: `export declare enum Color { Red = 1, Green }
+export declare namespace Util {
+    export const pi: number;
+}
+export type Pair<T> = [T, T];
+`,
+		},
+		{
+			name: "inferred",
+			src: `export const s = 'x' as const, n = -1 as const, ok = true as const;
+export let tag = "v" as const;
+export const cfg = { port: 8080, host: 'localhost', tls: { enabled: false }, "max-age": 60 };
+export const point = { x: 1, y: [1, 2] as const, name: 'p' as string, at(i: number): number { return i; } } as const;
+export const fmt = { pad: (s: string, n = 2): string => s };
+export const empty = {};
+`,
+			want: `export declare const s: 'x';
+export declare const n: -1;
+export declare const ok: true;
+export declare let tag: "v";
+export declare const cfg: { port: number; host: string; tls: { enabled: boolean; }; "max-age": number; };
+export declare const point: { readonly x: 1; readonly y: readonly [1, 2]; readonly name: string; at(i: number): number; };
+export declare const fmt: { pad: (s: string, n?: number) => string; };
+export declare const empty: {};
+`,
+		},
+		{
+			name: "not inferred",
+			src: `export const a = { ...base };
+export const b = { x };
+export const c = { [key]: 1 };
+export const d = [1, 2];
+export const e = { list: [1] };
+export const f = [...rest] as const;
+`,
+			errs: []string{
+				"1:13 " + dtsErrVariable,
+				"2:13 " + dtsErrVariable,
+				"3:13 " + dtsErrVariable,
+				"4:13 " + dtsErrVariable,
+				"5:13 " + dtsErrVariable,
+				"6:13 " + dtsErrVariable,
+			},
+		},
+		{
+			name: "void",
+			src: `export function f(a: number): void {}
+export function g(): never { throw new Error() }
+export function h(): undefined { return void 0 }
+export const ok = (x: boolean): boolean => x ? true : void 0 === undefined;
+export class C {
+  m(): void {}
+  n(cb: () => void): void {
+    cb();
+  }
+}
+`,
+			want: `export declare function f(a: number): void;
+export declare function g(): never;
+export declare function h(): undefined;
+export declare const ok: (x: boolean) => boolean;
+export declare class C {
+    m(): void;
+    n(cb: () => void): void;
+}
+`,
+		},
+		{
+			name: "augmentations",
+			src: `import type { Store } from './store';
+declare global {
+  interface Window { store: Store }
+}
+declare module './foo' {
+  interface Foo { extra: string }
+}
+export const id: number = 1;
+`,
+			want: `import type { Store } from './store';
+declare global {
+  interface Window { store: Store }
+}
+declare module './foo' {
+  interface Foo { extra: string }
+}
+export declare const id: number;
+export {};
+`,
+		},
+		{
+			name: "errors",
+			src: `export function f(a: number) { return a }
+export const g = (x) => x;
+export const obj = { ...base };
+export const [x, y] = [1, 2];
+export class K extends mixin(Base) {
+  value = compute();
+  m() {}
+}
+export default { ...base };
+function local() { return 1 }
+`,
+			errs: []string{
+				"1:16 " + dtsErrFunction,
+				"2:17 " + dtsErrFunction,
+				"2:18 " + dtsErrParameter,
+				"3:13 " + dtsErrVariable,
+				"4:13 " + dtsErrBinding,
+				"5:23 " + dtsErrExtends,
+				"6:2 " + dtsErrProperty,
+				"7:2 " + dtsErrMethod,
+				"9:15 " + dtsErrDefault,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, errs := emitDeclarations(c.src, false)
+			var messages []string
+			for _, err := range errs {
+				m := tsMessage("a.ts", c.src, err)
+				messages = append(messages, fmt.Sprintf("%d:%d %s", m.Location.Line, m.Location.Column, m.Text))
+			}
+			if strings.Join(messages, "\n") != strings.Join(c.errs, "\n") {
+				t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(messages, "\n"), strings.Join(c.errs, "\n"))
+			}
+			if c.errs == nil && got != c.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestDeclarationPlugin(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/index.ts":       "export { Button } from './ui/button';\nexport const version: string = '1';\n",
+		"src/ui/button.tsx":  "export function Button(props: { label: string }): string {\n  return <b>{props.label}</b>;\n}\n",
+		"src/broken/util.ts": "export const value = compute();\n",
+	}
+	writeTree(t, dir, files)
+	build := func(entry string) api.BuildResult {
+		return api.Build(api.BuildOptions{
+			AbsWorkingDir: dir,
+			EntryPoints:   []string{entry},
+			Outdir:        "dist",
+			Bundle:        true,
+			Metafile:      true,
+			Write:         true,
+			JSX:           api.JSXAutomatic,
+			External:      []string{"react/*"},
+			Plugins:       []api.Plugin{declarationPlugin(filepath.Join(dir, "dist", "types"))},
+		})
+	}
+	if result := build("src/index.ts"); len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	for name, want := range map[string]string{
+		"index.d.ts":     "export { Button } from './ui/button';\nexport declare const version: string;\n",
+		"ui/button.d.ts": "export declare function Button(props: { label: string }): string;\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, "dist", "types", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s:\n%s\nwant:\n%s", name, data, want)
+		}
+	}
+	result := build("src/broken/util.ts")
+	if len(result.Errors) != 1 || result.Errors[0].Text != dtsErrVariable || result.Errors[0].Location.File != "src/broken/util.ts" {
+		t.Errorf("unexpected errors %+v", result.Errors)
+	}
+}
@@ -16,6 +16,9 @@ func Commands() *Command {
 			mvn(),
 			httpd(),
 			esbuild(),
+			transform(),
+			runScript(),
+			sourcemapCmd(),
 		},
 	}
 }
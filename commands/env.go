@@ -0,0 +1,278 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// EnvConfig 定义从 .env 文件加载并注入构建的环境变量
+type EnvConfig struct {
+	Dir    string   `json:"dir"`    // .env 文件所在目录，默认为工作目录
+	Prefix []string `json:"prefix"` // 允许暴露给代码的变量前缀，默认 APP_
+	Types  string   `json:"types"`  // 生成的类型声明文件路径，例如 src/env.d.ts
+}
+
+// defaultEnvPrefix 未配置前缀时只暴露该前缀的变量，避免泄漏其他环境变量
+const defaultEnvPrefix = "APP_"
+
+// defaultEnvMode 未指定构建模式时的默认模式
+const defaultEnvMode = "production"
+
+// envFiles 按优先级从低到高返回需要加载的 .env 文件
+func envFiles(mode string) []string {
+	files := []string{".env", ".env.local"}
+	if mode != "" {
+		files = append(files, ".env."+mode, ".env."+mode+".local")
+	}
+	return files
+}
+
+// loadEnv 加载 dir 下的 .env 文件，后加载的文件与进程环境变量优先，只保留带指定前缀的变量
+func loadEnv(dir, mode string, prefixes []string) (map[string]string, []string, error) {
+	if len(prefixes) == 0 {
+		prefixes = []string{defaultEnvPrefix}
+	}
+	all := make(map[string]string)
+	var loaded []string
+	for _, name := range envFiles(mode) {
+		file := filepath.Join(dir, name)
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		if err := parseEnvFile(string(data), all); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		loaded = append(loaded, file)
+	}
+	vars := make(map[string]string)
+	for key, value := range all {
+		vars[key] = value
+	}
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			vars[key] = value
+		}
+	}
+	for key := range vars {
+		exposed := false
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				exposed = true
+				break
+			}
+		}
+		if !exposed {
+			delete(vars, key)
+		}
+	}
+	return vars, loaded, nil
+}
+
+var (
+	envLinePattern   = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z_][A-Za-z0-9_]*)\s*=\s*(.*)$`)
+	envExpandPattern = regexp.MustCompile(`\\?\$(?:\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}|([A-Za-z_][A-Za-z0-9_]*))`)
+)
+
+// parseEnvFile 解析 dotenv 格式内容写入 vars：支持注释、export 前缀、单双引号与反引号、
+// 双引号内的转义和多行值，以及未加单引号值中的 ${VAR} 与 ${VAR:-default} 展开
+func parseEnvFile(content string, vars map[string]string) error {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		m := envLinePattern.FindStringSubmatch(line)
+		if m == nil {
+			return fmt.Errorf("line %d: invalid syntax", i+1)
+		}
+		key, value := m[1], strings.TrimSpace(m[2])
+		if value != "" && strings.ContainsRune(`"'`+"`", rune(value[0])) {
+			quote := value[0]
+			// 引号未闭合时值跨越多行
+			start := i
+			for !hasClosingQuote(value, quote) {
+				if i++; i >= len(lines) {
+					return fmt.Errorf("line %d: unterminated quoted value", start+1)
+				}
+				value += "\n" + lines[i]
+			}
+			end := closingQuote(value, quote)
+			value = value[1:end]
+			switch quote {
+			case '"':
+				value = expandEnv(unescapeEnv(value), vars)
+			case '`':
+				value = expandEnv(value, vars)
+			}
+		} else {
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+			value = expandEnv(value, vars)
+		}
+		vars[key] = value
+	}
+	return nil
+}
+
+func hasClosingQuote(value string, quote byte) bool {
+	return closingQuote(value, quote) > 0
+}
+
+// closingQuote 返回闭合引号位置，双引号内允许转义
+func closingQuote(value string, quote byte) int {
+	for i := 1; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+func unescapeEnv(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value)
+}
+
+// expandEnv 展开 $VAR、${VAR} 与 ${VAR:-default}，进程环境变量优先于已加载的变量，\$ 输出为 $
+func expandEnv(value string, vars map[string]string) string {
+	return envExpandPattern.ReplaceAllStringFunc(value, func(s string) string {
+		if strings.HasPrefix(s, `\`) {
+			return s[1:]
+		}
+		m := envExpandPattern.FindStringSubmatch(s)
+		name := m[1] + m[3]
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m[2]
+	})
+}
+
+// envDefines 生成 import.meta.env.* 与 process.env.* 的 define，值为 JSON 字面量
+func envDefines(vars map[string]string, mode string) map[string]string {
+	quote := func(s string) string {
+		data, _ := json.Marshal(s)
+		return string(data)
+	}
+	nodeEnv := "development"
+	if mode == "" || mode == "production" {
+		nodeEnv = "production"
+	}
+	if mode == "" {
+		mode = "production"
+	}
+	object := map[string]interface{}{
+		"MODE": mode,
+		"DEV":  nodeEnv != "production",
+		"PROD": nodeEnv == "production",
+	}
+	defines := map[string]string{
+		"import.meta.env.MODE": quote(mode),
+		"import.meta.env.DEV":  fmt.Sprint(nodeEnv != "production"),
+		"import.meta.env.PROD": fmt.Sprint(nodeEnv == "production"),
+		"process.env.NODE_ENV": quote(nodeEnv),
+	}
+	for key, value := range vars {
+		object[key] = value
+		defines["import.meta.env."+key] = quote(value)
+		defines["process.env."+key] = quote(value)
+	}
+	data, _ := json.Marshal(object)
+	defines["import.meta.env"] = string(data)
+	return defines
+}
+
+// writeEnvTypes 生成与注入变量对应的 TypeScript 声明
+func writeEnvTypes(file string, vars map[string]string) error {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("// Generated by units build from .env files, do not edit.\n\n")
+	b.WriteString("interface ImportMetaEnv {\n  readonly MODE: string\n  readonly DEV: boolean\n  readonly PROD: boolean\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "  readonly %s: string\n", key)
+	}
+	b.WriteString("}\n\ninterface ImportMeta {\n  readonly env: ImportMetaEnv\n}\n\n")
+	b.WriteString("declare namespace NodeJS {\n  interface ProcessEnv {\n    readonly NODE_ENV: string\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "    readonly %s: string\n", key)
+	}
+	b.WriteString("  }\n}\n")
+	if existing, err := os.ReadFile(file); err == nil && string(existing) == b.String() {
+		// 内容未变化时不重写，避免触发类型检查器与监听器
+		return nil
+	}
+	return writeFileAtomic(file, []byte(b.String()), 0644)
+}
+
+// envDir .env 文件所在目录，默认为工作目录
+func (c *EsbuildConfig) envDir() string {
+	if c.Env != nil && c.Env.Dir != "" {
+		return c.Env.Dir
+	}
+	if c.AbsWorkingDir != "" {
+		return c.AbsWorkingDir
+	}
+	return "."
+}
+
+// defaultMode 未指定构建模式时使用 production，在加载 .env 文件与收集监听的输入之前调用
+func (c *EsbuildConfig) defaultMode() {
+	if c.Mode == "" {
+		c.Mode = defaultEnvMode
+		c.modeDefaulted = true
+	}
+}
+
+// applyEnv 加载 .env 文件并合并到配置的 define 中，已有的 define 优先
+func applyEnv(config *EsbuildConfig) error {
+	env := config.Env
+	if env == nil {
+		env = &EnvConfig{}
+	}
+	vars, loaded, err := loadEnv(config.envDir(), config.Mode, env.Prefix)
+	if err != nil {
+		return err
+	}
+	// 默认模式下没有 .env 文件也没有配置时不注入，保持未使用该功能的构建不变
+	if len(loaded) == 0 && config.modeDefaulted && config.Env == nil {
+		return nil
+	}
+	if config.Define == nil {
+		config.Define = make(map[string]string)
+	}
+	for key, value := range envDefines(vars, config.Mode) {
+		if _, ok := config.Define[key]; !ok {
+			config.Define[key] = value
+		}
+	}
+	if len(loaded) > 0 {
+		log.Printf("Loaded %s, %d variables exposed", strings.Join(loaded, ", "), len(vars))
+	}
+	if env.Types != "" {
+		if err := writeEnvTypes(env.Types, vars); err != nil {
+			return fmt.Errorf("failed to write %s: %v", env.Types, err)
+		}
+	}
+	return nil
+}
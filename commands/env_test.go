@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	vars := make(map[string]string)
+	content := "# comment\nexport APP_A=plain # trailing\nAPP_B='single $APP_A'\nAPP_C=\"double\\n${APP_A}\"\nAPP_D=\"multi\nline\"\nAPP_E=${APP_MISSING:-fallback}\nAPP_F=\\$APP_A\n"
+	if err := parseEnvFile(content, vars); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"APP_A": "plain",
+		"APP_B": "single $APP_A",
+		"APP_C": "double\nplain",
+		"APP_D": "multi\nline",
+		"APP_E": "fallback",
+		"APP_F": "$APP_A",
+	}
+	for key, value := range want {
+		if vars[key] != value {
+			t.Errorf("%s = %q, want %q", key, vars[key], value)
+		}
+	}
+	if err := parseEnvFile("APP_X=\"open", vars); err == nil {
+		t.Errorf("expected error for unterminated quote")
+	}
+}
+
+func TestEnvBuild(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".env", "APP_TITLE=base\nAPP_API=http://localhost\nSECRET=hidden\n")
+	write(".env.staging", "APP_API=https://staging.example.com\n")
+	write(".env.production", "APP_API=https://example.com\n")
+	write("main.js", "console.log(import.meta.env.APP_TITLE, process.env.APP_API, import.meta.env.MODE, process.env.SECRET)\n")
+
+	config := &EsbuildConfig{AbsWorkingDir: dir, Mode: "staging", Env: &EnvConfig{Types: filepath.Join(dir, "env.d.ts")}}
+	if err := applyEnv(config); err != nil {
+		t.Fatal(err)
+	}
+	result := api.Build(api.BuildOptions{
+		AbsWorkingDir: dir,
+		EntryPoints:   []string{"main.js"},
+		Bundle:        true,
+		Define:        config.Define,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	out := string(result.OutputFiles[0].Contents)
+	for _, want := range []string{`"base"`, `"https://staging.example.com"`, `"staging"`, "process.env.SECRET"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+	types, err := os.ReadFile(filepath.Join(dir, "env.d.ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(types), "readonly APP_API: string") || strings.Contains(string(types), "SECRET") {
+		t.Errorf("unexpected env.d.ts:\n%s", types)
+	}
+}
+
+func TestEnvDefaultMode(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.js"), []byte("console.log(import.meta.env.MODE, import.meta.env.APP_API)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := &EsbuildConfig{AbsWorkingDir: dir, EntryPoints: []string{filepath.Join(dir, "main.js")}, Outdir: filepath.Join(dir, "dist"), Bundle: true}
+	if _, err := esbuildOptions(config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Define) != 0 {
+		t.Errorf("expected no defines without .env files, got %v", config.Define)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".env.production"), []byte("APP_API=https://example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config = &EsbuildConfig{AbsWorkingDir: dir, EntryPoints: []string{filepath.Join(dir, "main.js")}, Outdir: filepath.Join(dir, "dist"), Bundle: true}
+	inputs := strings.Join(configInputs("", config), "\n")
+	options, err := esbuildOptions(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(inputs, filepath.Join(dir, ".env.production")) {
+		t.Errorf("watch inputs missing .env.production:\n%s", inputs)
+	}
+	result := api.Build(options)
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	out := string(result.OutputFiles[0].Contents)
+	for _, want := range []string{`"production"`, `"https://example.com"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+}
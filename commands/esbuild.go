@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/evanw/esbuild/pkg/api"
 	"github.com/urfave/cli/v3"
@@ -21,13 +23,13 @@ type EsbuildConfig struct {
 	LogOverride map[string]string `json:"logOverride"`
 	AbsPaths    bool              `json:"absPaths"`
 
-	Sourcemap      string `json:"sourcemap"`
-	SourceRoot     string `json:"sourceRoot"`
-	SourcesContent string `json:"sourcesContent"`
+	Sourcemap      string `json:"sourcemap"`      // true、linked、external、inline、both，true 等同 linked
+	SourceRoot     string `json:"sourceRoot"`     // 写入 source map 的 sourceRoot
+	SourcesContent *bool  `json:"sourcesContent"` // false 时不在 source map 中包含源码，默认包含
 
-	Target    string          `json:"target"`
-	Engines   []api.Engine    `json:"engines"`
-	Supported map[string]bool `json:"supported"`
+	Target    string              `json:"target"`
+	Engines   []map[string]string `json:"engines"` // 引擎最低版本，例如 {"node": "18"}，browser 键为 browserslist 查询
+	Supported map[string]bool     `json:"supported"`
 
 	MangleProps       string                 `json:"mangleProps"`
 	ReserveProps      string                 `json:"reserveProps"`
@@ -88,15 +90,51 @@ type EsbuildConfig struct {
 	EntryPoints []string `json:"entryPoints"`
 
 	Stdin          *api.StdinOptions `json:"stdin"`
-	Write          bool              `json:"write"`
+	Write          *bool             `json:"write"` // false 时只在内存中保留输出，由 httpd --build 提供，默认 true
 	AllowOverwrite bool              `json:"allowOverwrite"`
 	Watch          bool              `json:"watch"`
+
+	Npm *NpmResolveConfig `json:"npm"`
+
+	ImportMap         json.RawMessage `json:"importMap"`         // 内联 import map 对象或文件路径
+	ImportMapExternal bool            `json:"importMapExternal"` // 映射到 URL 的模块保持外部引用
+
+	Budgets  []SizeBudget `json:"budgets"`  // 输出文件体积预算
+	Compress []string     `json:"compress"` // 为输出写入预压缩文件：gzip、br
+
+	Manifest string `json:"manifest"` // 资源清单路径，相对于输出目录
+
+	Mode string     `json:"mode"` // 构建模式，决定加载的 .env.{mode} 文件，默认 production
+	Env  *EnvConfig `json:"env"`
+
+	modeDefaulted bool // 未指定构建模式，使用默认的 production
+
+	WatchDelay int    `json:"watchDelay"` // 检测到变化后延迟重新构建的毫秒数，默认 100
+	WatchMode  string `json:"watchMode"`  // poll 使用 esbuild 内置轮询（默认），notify 使用文件系统通知
+
+	OnSuccess []BuildHook `json:"onSuccess"` // 监听模式下构建成功后执行的钩子
+	OnFailure []BuildHook `json:"onFailure"` // 监听模式下构建失败后执行的钩子
+	HookDelay int         `json:"hookDelay"` // 钩子防抖时间（毫秒），默认 300
+
+	Plugins []PluginConfig `json:"plugins"` // 按顺序启用的内置插件：copy、replace、virtual、raw、glob-import、svg-component、clean
+
+	Lib *LibConfig `json:"lib"` // 库构建模式：输出 esm、cjs 等格式并更新 package.json
+
+	Declaration    bool   `json:"declaration"`    // 根据显式类型标注生成 .d.ts（isolatedDeclarations）
+	DeclarationDir string `json:"declarationDir"` // 声明输出目录，相对输出目录，默认 types
+
+	CSS *CSSConfig `json:"css"` // CSS 模块类型声明、嵌套与 @layer 降级、工具类样式生成
+
+	Cache *BuildCacheConfig `json:"cache"` // 持久化构建缓存：插件加载结果、npm 归档内容与包元数据
 }
 
 func esbuild() *cli.Command {
 	return &cli.Command{
 		Name:  "build",
 		Usage: "bundle and minify JavaScript/TypeScript with esbuild Go API",
+		Commands: []*cli.Command{
+			esbuildAnalyze(),
+		},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "config",
@@ -147,9 +185,17 @@ func esbuild() *cli.Command {
 			},
 			&cli.StringFlag{
 				Name:  "sourcemap",
-				Usage: "generate source maps (true, false, inline, external, both)",
+				Usage: "generate source maps (true, false, linked, inline, external, both), true is linked",
 				Value: "false",
 			},
+			&cli.StringFlag{
+				Name:  "source-root",
+				Usage: "sourceRoot written into source maps",
+			},
+			&cli.BoolFlag{
+				Name:  "sources-content",
+				Usage: "include original sources in source maps (default true)",
+			},
 			&cli.BoolFlag{
 				Name:    "watch",
 				Aliases: []string{"w"},
@@ -168,8 +214,7 @@ func esbuild() *cli.Command {
 			},
 			&cli.StringFlag{
 				Name:  "target",
-				Usage: "language target (e.g. es2015, es2020)",
-				Value: "es2015",
+				Usage: "comma separated targets (e.g. es2020,chrome90,safari14,node18, esnext) or browserslist query (e.g. '> 0.5%, last 2 versions, not dead'), package.json browserslist by default",
 			},
 			&cli.StringFlag{
 				Name:    "tsconfig",
@@ -225,179 +270,601 @@ func esbuild() *cli.Command {
 				Name:  "loader",
 				Usage: "configure loader for file extensions (ext:loader)",
 			},
+			&cli.StringSliceFlag{
+				Name:  "npm-archive",
+				Usage: "folders of npm .tgz archives used to resolve bare imports",
+			},
+			&cli.StringFlag{
+				Name:  "npm-registry",
+				Usage: "npm registry to fetch missing packages from (e.g. https://registry.npmjs.org)",
+			},
+			&cli.StringFlag{
+				Name:  "npm-cache",
+				Usage: "cache folder of packages fetched from npm registry",
+			},
+			&cli.StringFlag{
+				Name:  "import-map",
+				Usage: "import map file (json or html) used to resolve imports",
+			},
+			&cli.BoolFlag{
+				Name:  "import-map-external",
+				Usage: "keep imports mapped to URLs external instead of bundling them",
+			},
+			&cli.StringSliceFlag{
+				Name:  "compress",
+				Usage: "write precompressed siblings for outputs (gzip, br)",
+			},
+			&cli.StringFlag{
+				Name:  "manifest",
+				Usage: "write asset manifest (relative to outdir, e.g. manifest.json)",
+			},
+			&cli.IntFlag{
+				Name:  "watch-delay",
+				Usage: "milliseconds to wait after a change before rebuilding (default 100)",
+			},
+			&cli.StringFlag{
+				Name:  "watch-mode",
+				Usage: "how to detect changes: poll (esbuild polling) or notify (file system events)",
+			},
+			&cli.StringFlag{
+				Name:  "on-success",
+				Usage: "shell command to run after each successful build in watch mode",
+			},
+			&cli.StringFlag{
+				Name:  "on-failure",
+				Usage: "shell command to run after each failed build in watch mode",
+			},
+			&cli.BoolFlag{
+				Name:  "lib",
+				Usage: "build a library into outdir/esm and outdir/cjs (default outdir dist), keep dependencies external and update package.json entry fields",
+			},
+			&cli.StringSliceFlag{
+				Name:  "lib-formats",
+				Usage: "library output formats (esm, cjs, umd, iife), implies --lib",
+			},
+			&cli.BoolFlag{
+				Name:    "declaration",
+				Aliases: []string{"dts"},
+				Usage:   "emit .d.ts from explicit type annotations (isolatedDeclarations), missing annotations on exports fail the build",
+			},
+			&cli.StringFlag{
+				Name:  "declaration-dir",
+				Usage: "directory for emitted declarations, relative to outdir (default types)",
+			},
+			&cli.BoolFlag{
+				Name:  "css-types",
+				Usage: "emit .module.css.d.ts next to css modules with the exported class names",
+			},
+			&cli.BoolFlag{
+				Name:  "no-cache",
+				Usage: "disable the persistent build cache and refetch npm metadata",
+			},
+			&cli.StringFlag{
+				Name:  "cache-dir",
+				Usage: "folder of the persistent build cache (default user cache dir units/build)",
+			},
+			&cli.BoolFlag{
+				Name:  "cache-stats",
+				Usage: "report build cache hits, misses and size after each build",
+			},
+			&cli.StringFlag{
+				Name:  "mode",
+				Usage: "build mode, loads .env.{mode} and .env.{mode}.local besides .env and .env.local (default production)",
+			},
+			&cli.StringFlag{
+				Name:  "env-dir",
+				Usage: "folder of .env files (default working directory)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "env-prefix",
+				Usage: "prefixes of env variables exposed as import.meta.env and process.env (default APP_)",
+			},
+			&cli.StringFlag{
+				Name:  "env-types",
+				Usage: "write typescript declarations of exposed env variables (e.g. src/env.d.ts)",
+			},
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
-			var config EsbuildConfig
-
-			// 如果提供了配置文件，从文件加载配置
-			if configFile := cmd.String("config"); configFile != "" {
-				configData, err := ioutil.ReadFile(configFile)
+			config, err := esbuildConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if config.Watch {
+				// 配置相关的文件变化后重新读取配置并创建新的构建
+				build, err := newWatchBuild(ctx, cmd.String("config"), config)
 				if err != nil {
-					return fmt.Errorf("failed to read config file: %v", err)
+					return err
 				}
-
-				if err := json.Unmarshal(configData, &config); err != nil {
-					return fmt.Errorf("failed to parse config file: %v", err)
-				}
-			}
-
-			// 用命令行参数覆盖配置文件中的设置
-			if entries := cmd.StringSlice("entry"); len(entries) > 0 {
-				config.EntryPoints = entries
-			}
-			if outfile := cmd.String("outfile"); outfile != "" {
-				config.Outfile = outfile
-			}
-			if outdir := cmd.String("outdir"); outdir != "" {
-				config.Outdir = outdir
-			}
-			if cmd.IsSet("bundle") {
-				config.Bundle = cmd.Bool("bundle")
-			}
-			if cmd.IsSet("minify") {
-				minify := cmd.Bool("minify")
-				config.MinifyWhitespace = minify
-				config.MinifyIdentifiers = minify
-				config.MinifySyntax = minify
-			}
-			if cmd.IsSet("minify-whitespace") {
-				config.MinifyWhitespace = cmd.Bool("minify-whitespace")
-			}
-			if cmd.IsSet("minify-identifiers") {
-				config.MinifyIdentifiers = cmd.Bool("minify-identifiers")
-			}
-			if cmd.IsSet("minify-syntax") {
-				config.MinifySyntax = cmd.Bool("minify-syntax")
-			}
-			if cmd.IsSet("sourcemap") {
-				config.Sourcemap = cmd.String("sourcemap")
-			}
-			if cmd.IsSet("watch") {
-				config.Watch = cmd.Bool("watch")
-			}
-			if platform := cmd.String("platform"); platform != "" {
-				config.Platform = platform
-			}
-			if format := cmd.String("format"); format != "" {
-				config.Format = format
-			}
-			if target := cmd.String("target"); target != "" {
-				config.Target = target
-			}
-			if tsconfig := cmd.String("tsconfig"); tsconfig != "" {
-				config.Tsconfig = tsconfig
-			}
-			if jsx := cmd.String("jsx"); jsx != "" {
-				config.JSX = jsx
-			}
-			if jsxFactory := cmd.String("jsx-factory"); jsxFactory != "" {
-				config.JSXFactory = jsxFactory
-			}
-			if jsxFragment := cmd.String("jsx-fragment"); jsxFragment != "" {
-				config.JSXFragment = jsxFragment
-			}
-			if jsxImportSource := cmd.String("jsx-import-source"); jsxImportSource != "" {
-				config.JSXImportSource = jsxImportSource
-			}
-			if cmd.IsSet("jsx-dev") {
-				config.JSXDev = cmd.Bool("jsx-dev")
-			}
-			if external := cmd.StringSlice("external"); len(external) > 0 {
-				config.External = external
-			}
-			if globalName := cmd.String("global-name"); globalName != "" {
-				config.GlobalName = globalName
-			}
-			if cmd.IsSet("splitting") {
-				config.Splitting = cmd.Bool("splitting")
-			}
-			if cmd.IsSet("metafile") {
-				config.Metafile = cmd.Bool("metafile")
-			}
-			if cmd.IsSet("treeShaking") {
-				config.TreeShaking = cmd.String("treeShaking")
-			}
-			if defines := cmd.StringSlice("define"); len(defines) > 0 {
-				config.Define = make(map[string]string)
-				for _, d := range defines {
-					parts := strings.SplitN(d, "=", 2)
-					if len(parts) == 2 {
-						config.Define[parts[0]] = parts[1]
-					} else {
-						config.Define[parts[0]] = "true"
+				return runEsbuildWatch(ctx, build, func() (*watchBuild, error) {
+					config, err := esbuildConfig(cmd)
+					if err != nil {
+						return nil, err
 					}
-				}
+					return newWatchBuild(ctx, cmd.String("config"), config)
+				})
 			}
-			if loaders := cmd.StringSlice("loader"); len(loaders) > 0 {
-				config.Loader = make(map[string]string)
-				for _, l := range loaders {
-					parts := strings.SplitN(l, ":", 2)
-					if len(parts) == 2 {
-						config.Loader[parts[0]] = parts[1]
-					}
-				}
+			buildOptions, err := esbuildOptions(&config)
+			if err != nil {
+				return err
 			}
 
-			// 验证必要参数
-			if len(config.EntryPoints) == 0 {
-				return fmt.Errorf("at least one entry point must be specified")
-			}
-			if config.Outfile == "" && config.Outdir == "" {
-				return fmt.Errorf("either outfile or outdir must be specified")
-			}
-			var ts = api.TreeShakingDefault
-			if config.TreeShaking == "" {
-				ts = api.TreeShakingDefault
-			} else if strings.EqualFold(config.TreeShaking, "true") {
-				ts = api.TreeShakingTrue
-			}
-			// 构建 esbuild 选项
-			buildOptions := api.BuildOptions{
-				EntryPoints:       config.EntryPoints,
-				Bundle:            config.Bundle,
-				MinifyWhitespace:  config.MinifyWhitespace,
-				MinifyIdentifiers: config.MinifyIdentifiers,
-				MinifySyntax:      config.MinifySyntax,
-				Platform:          parsePlatform(config.Platform),
-				Format:            parseFormat(config.Format),
-				Target:            parseTarget(config.Target),
-				Tsconfig:          config.Tsconfig,
-				Write:             true,
-				Metafile:          config.Metafile,
-				Splitting:         config.Splitting,
-				GlobalName:        config.GlobalName,
-				JSX:               parseJSX(config.JSX),
-				JSXFactory:        config.JSXFactory,
-				JSXFragment:       config.JSXFragment,
-				JSXImportSource:   config.JSXImportSource,
-				JSXDev:            config.JSXDev,
-				TreeShaking:       ts,
-				External:          config.External,
-				Define:            config.Define,
-				Sourcemap:         parseSourceMap(config.Sourcemap),
+			// 执行构建
+			if config.Lib != nil {
+				return runLibBuild(buildOptions, config.Lib)
 			}
+			return runEsbuildOnce(buildOptions, config.Metafile)
+		},
+	}
+}
 
-			// 设置加载器
-			if len(config.Loader) > 0 {
-				buildOptions.Loader = make(map[string]api.Loader)
-				for ext, loader := range config.Loader {
-					buildOptions.Loader[ext] = parseLoader(loader)
-				}
-			}
+// esbuildConfig 读取配置文件并用命令行参数覆盖
+func esbuildConfig(cmd *cli.Command) (EsbuildConfig, error) {
+	var config EsbuildConfig
 
-			if config.Outfile != "" {
-				buildOptions.Outfile = config.Outfile
-			} else {
-				buildOptions.Outdir = config.Outdir
-			}
+	// 如果提供了配置文件，从文件加载配置
+	if configFile := cmd.String("config"); configFile != "" {
+		var err error
+		if config, err = loadEsbuildConfig(configFile); err != nil {
+			return config, err
+		}
+	}
 
-			// 执行构建
-			if config.Watch {
-				return runEsbuildWatch(ctx, buildOptions)
+	// 用命令行参数覆盖配置文件中的设置
+	if entries := cmd.StringSlice("entry"); len(entries) > 0 {
+		config.EntryPoints = entries
+	}
+	if outfile := cmd.String("outfile"); outfile != "" {
+		config.Outfile = outfile
+	}
+	if outdir := cmd.String("outdir"); outdir != "" {
+		config.Outdir = outdir
+	}
+	if cmd.IsSet("bundle") {
+		config.Bundle = cmd.Bool("bundle")
+	}
+	if cmd.IsSet("minify") {
+		minify := cmd.Bool("minify")
+		config.MinifyWhitespace = minify
+		config.MinifyIdentifiers = minify
+		config.MinifySyntax = minify
+	}
+	if cmd.IsSet("minify-whitespace") {
+		config.MinifyWhitespace = cmd.Bool("minify-whitespace")
+	}
+	if cmd.IsSet("minify-identifiers") {
+		config.MinifyIdentifiers = cmd.Bool("minify-identifiers")
+	}
+	if cmd.IsSet("minify-syntax") {
+		config.MinifySyntax = cmd.Bool("minify-syntax")
+	}
+	if cmd.IsSet("sourcemap") {
+		config.Sourcemap = cmd.String("sourcemap")
+	}
+	if cmd.IsSet("source-root") {
+		config.SourceRoot = cmd.String("source-root")
+	}
+	if cmd.IsSet("sources-content") {
+		content := cmd.Bool("sources-content")
+		config.SourcesContent = &content
+	}
+	if cmd.IsSet("watch") {
+		config.Watch = cmd.Bool("watch")
+	}
+	if platform := cmd.String("platform"); platform != "" {
+		config.Platform = platform
+	}
+	if format := cmd.String("format"); format != "" {
+		config.Format = format
+	}
+	if target := cmd.String("target"); target != "" {
+		config.Target = target
+	}
+	if tsconfig := cmd.String("tsconfig"); tsconfig != "" {
+		config.Tsconfig = tsconfig
+	}
+	if jsx := cmd.String("jsx"); jsx != "" {
+		config.JSX = jsx
+	}
+	if jsxFactory := cmd.String("jsx-factory"); jsxFactory != "" {
+		config.JSXFactory = jsxFactory
+	}
+	if jsxFragment := cmd.String("jsx-fragment"); jsxFragment != "" {
+		config.JSXFragment = jsxFragment
+	}
+	if jsxImportSource := cmd.String("jsx-import-source"); jsxImportSource != "" {
+		config.JSXImportSource = jsxImportSource
+	}
+	if cmd.IsSet("jsx-dev") {
+		config.JSXDev = cmd.Bool("jsx-dev")
+	}
+	if external := cmd.StringSlice("external"); len(external) > 0 {
+		config.External = external
+	}
+	if globalName := cmd.String("global-name"); globalName != "" {
+		config.GlobalName = globalName
+	}
+	if cmd.IsSet("splitting") {
+		config.Splitting = cmd.Bool("splitting")
+	}
+	if cmd.IsSet("metafile") {
+		config.Metafile = cmd.Bool("metafile")
+	}
+	if cmd.IsSet("treeShaking") {
+		config.TreeShaking = cmd.String("treeShaking")
+	}
+	if defines := cmd.StringSlice("define"); len(defines) > 0 {
+		config.Define = parseDefines(defines)
+	}
+	if loaders := cmd.StringSlice("loader"); len(loaders) > 0 {
+		config.Loader = make(map[string]string)
+		for _, l := range loaders {
+			parts := strings.SplitN(l, ":", 2)
+			if len(parts) == 2 {
+				config.Loader[parts[0]] = parts[1]
 			}
-			return runEsbuildOnce(buildOptions)
-		},
+		}
 	}
+
+	if archives := cmd.StringSlice("npm-archive"); len(archives) > 0 {
+		if config.Npm == nil {
+			config.Npm = &NpmResolveConfig{}
+		}
+		config.Npm.Archives = archives
+	}
+	if registry := cmd.String("npm-registry"); registry != "" {
+		if config.Npm == nil {
+			config.Npm = &NpmResolveConfig{}
+		}
+		config.Npm.Registry = registry
+	}
+	if cache := cmd.String("npm-cache"); cache != "" {
+		if config.Npm == nil {
+			config.Npm = &NpmResolveConfig{}
+		}
+		config.Npm.Cache = cache
+	}
+
+	if importMap := cmd.String("import-map"); importMap != "" {
+		config.ImportMap, _ = json.Marshal(importMap)
+	}
+	if cmd.IsSet("import-map-external") {
+		config.ImportMapExternal = cmd.Bool("import-map-external")
+	}
+	if compress := cmd.StringSlice("compress"); len(compress) > 0 {
+		config.Compress = compress
+	}
+	if manifest := cmd.String("manifest"); manifest != "" {
+		config.Manifest = manifest
+	}
+	if cmd.IsSet("watch-delay") {
+		config.WatchDelay = int(cmd.Int("watch-delay"))
+	}
+	if mode := cmd.String("watch-mode"); mode != "" {
+		config.WatchMode = mode
+	}
+	if run := cmd.String("on-success"); run != "" {
+		config.OnSuccess = []BuildHook{{Run: run}}
+	}
+	if run := cmd.String("on-failure"); run != "" {
+		config.OnFailure = []BuildHook{{Run: run}}
+	}
+	if mode := cmd.String("mode"); mode != "" {
+		config.Mode = mode
+	}
+	if dir := cmd.String("env-dir"); dir != "" {
+		if config.Env == nil {
+			config.Env = &EnvConfig{}
+		}
+		config.Env.Dir = dir
+	}
+	if prefixes := cmd.StringSlice("env-prefix"); len(prefixes) > 0 {
+		if config.Env == nil {
+			config.Env = &EnvConfig{}
+		}
+		config.Env.Prefix = prefixes
+	}
+	if types := cmd.String("env-types"); types != "" {
+		if config.Env == nil {
+			config.Env = &EnvConfig{}
+		}
+		config.Env.Types = types
+	}
+	if cmd.Bool("lib") && config.Lib == nil {
+		config.Lib = &LibConfig{}
+	}
+	if formats := cmd.StringSlice("lib-formats"); len(formats) > 0 {
+		if config.Lib == nil {
+			config.Lib = &LibConfig{}
+		}
+		config.Lib.Formats = formats
+	}
+	if cmd.IsSet("declaration") {
+		config.Declaration = cmd.Bool("declaration")
+	}
+	if dir := cmd.String("declaration-dir"); dir != "" {
+		config.DeclarationDir = dir
+	}
+	if cmd.Bool("css-types") {
+		if config.CSS == nil {
+			config.CSS = &CSSConfig{}
+		}
+		if config.CSS.Modules == nil {
+			config.CSS.Modules = &CSSModulesConfig{}
+		}
+		config.CSS.Modules.Types = true
+	}
+	if cmd.Bool("no-cache") || cmd.String("cache-dir") != "" || cmd.Bool("cache-stats") {
+		if config.Cache == nil {
+			config.Cache = &BuildCacheConfig{}
+		}
+		if cmd.Bool("no-cache") {
+			config.Cache.Disabled = true
+		}
+		if dir := cmd.String("cache-dir"); dir != "" {
+			config.Cache.Dir = dir
+		}
+		if cmd.Bool("cache-stats") {
+			config.Cache.Stats = true
+		}
+	}
+	return config, nil
+}
+
+// configInputs 影响构建选项的文件，监听模式下变化时重新读取配置
+func configInputs(configFile string, config *EsbuildConfig) []string {
+	config.defaultMode()
+	workDir := config.AbsWorkingDir
+	if workDir == "" {
+		workDir = "."
+	}
+	var files []string
+	if configFile != "" {
+		files = append(files, configFile)
+	}
+	tsconfig := config.Tsconfig
+	if tsconfig == "" {
+		tsconfig = "tsconfig.json"
+	}
+	if !filepath.IsAbs(tsconfig) {
+		tsconfig = filepath.Join(workDir, tsconfig)
+	}
+	files = append(files, tsconfig, filepath.Join(workDir, "package.json"))
+	for _, name := range envFiles(config.Mode) {
+		files = append(files, filepath.Join(config.envDir(), name))
+	}
+	if file := importMapFile(config.ImportMap); file != "" {
+		files = append(files, file)
+	}
+	// html 入口不是 esbuild 的输入，页面变化后需要重新计算其中的脚本与样式入口
+	for _, entry := range config.EntryPoints {
+		if isHTMLEntry(entry) {
+			files = append(files, entry)
+		}
+	}
+	for i, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			files[i] = abs
+		}
+	}
+	return files
+}
+
+// loadEsbuildConfig 读取构建配置文件
+func loadEsbuildConfig(file string) (EsbuildConfig, error) {
+	var config EsbuildConfig
+	configData, err := ioutil.ReadFile(file)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := json.Unmarshal(configData, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	return config, nil
+}
+
+// esbuildOptions 根据配置生成 esbuild 构建选项与插件
+func esbuildOptions(config *EsbuildConfig) (api.BuildOptions, error) {
+	config.defaultMode()
+	if err := applyEnv(config); err != nil {
+		return api.BuildOptions{}, err
+	}
+
+	// 拆分 html 入口，页面中引用的脚本和样式作为新的入口
+	entryPoints, pages, err := htmlEntryPoints(config.EntryPoints, config.Outdir, config.Outbase)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+	config.EntryPoints = entryPoints
+
+	// 验证必要参数
+	if len(config.EntryPoints) == 0 {
+		return api.BuildOptions{}, fmt.Errorf("at least one entry point must be specified")
+	}
+	if config.Lib != nil {
+		if config.Outfile != "" {
+			return api.BuildOptions{}, fmt.Errorf("library build writes to outdir, outfile is not supported")
+		}
+		if config.Watch {
+			return api.BuildOptions{}, fmt.Errorf("library build does not support watch mode")
+		}
+		if config.Outdir == "" {
+			config.Outdir = "dist"
+		}
+	}
+	if config.Outfile == "" && config.Outdir == "" {
+		return api.BuildOptions{}, fmt.Errorf("either outfile or outdir must be specified")
+	}
+	var ts = api.TreeShakingDefault
+	if config.TreeShaking == "" {
+		ts = api.TreeShakingDefault
+	} else if strings.EqualFold(config.TreeShaking, "true") {
+		ts = api.TreeShakingTrue
+	}
+	// 构建 esbuild 选项
+	buildOptions := api.BuildOptions{
+		EntryPoints:       config.EntryPoints,
+		Bundle:            config.Bundle,
+		MinifyWhitespace:  config.MinifyWhitespace,
+		MinifyIdentifiers: config.MinifyIdentifiers,
+		MinifySyntax:      config.MinifySyntax,
+		Platform:          parsePlatform(config.Platform),
+		Format:            parseFormat(config.Format),
+		Tsconfig:          config.Tsconfig,
+		Write:             config.Write == nil || *config.Write,
+		Metafile:          config.Metafile,
+		Splitting:         config.Splitting,
+		GlobalName:        config.GlobalName,
+		JSX:               parseJSX(config.JSX),
+		JSXFactory:        config.JSXFactory,
+		JSXFragment:       config.JSXFragment,
+		JSXImportSource:   config.JSXImportSource,
+		JSXDev:            config.JSXDev,
+		TreeShaking:       ts,
+		External:          config.External,
+		Define:            config.Define,
+		Sourcemap:         parseSourceMap(config.Sourcemap),
+		SourceRoot:        config.SourceRoot,
+	}
+	if config.SourcesContent != nil && !*config.SourcesContent {
+		buildOptions.SourcesContent = api.SourcesContentExclude
+	}
+
+	// 解析目标，未指定时使用 package.json 中的 browserslist
+	workDir := config.AbsWorkingDir
+	if workDir == "" {
+		workDir = "."
+	}
+	if buildOptions.Target, buildOptions.Engines, err = parseTargets(config.Target, workDir); err != nil {
+		return api.BuildOptions{}, err
+	}
+	engines, err := parseEngineTargets(config.Engines)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+	buildOptions.Engines = append(buildOptions.Engines, engines...)
+	buildOptions.Supported = config.Supported
+
+	// 设置加载器
+	if len(config.Loader) > 0 {
+		buildOptions.Loader = make(map[string]api.Loader)
+		for ext, loader := range config.Loader {
+			buildOptions.Loader[ext] = parseLoader(loader)
+		}
+	}
+
+	// 持久化缓存以环境变量注入后的配置为指纹
+	cache := newBuildCache(config.Cache, cacheFingerprint(*config))
+	if config.Cache != nil && config.Cache.Stats {
+		buildOptions.Plugins = append(buildOptions.Plugins, cache.statsPlugin())
+	}
+
+	// 配置中声明的内置插件优先于其他解析插件
+	plugins, err := configPlugins(config.Plugins)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+	for _, plugin := range plugins {
+		buildOptions.Plugins = append(buildOptions.Plugins, cache.wrap(plugin))
+	}
+
+	// 样式：按目标展开 @layer，嵌套交给 esbuild 按目标降级
+	css := config.CSS
+	if css == nil {
+		css = &CSSConfig{}
+	}
+	layers, err := applyCSSLowering(&buildOptions, css.Lower)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+	if config.CSS != nil || layers {
+		plugin, err := cssPlugin(css, layers)
+		if err != nil {
+			return api.BuildOptions{}, err
+		}
+		if css.Modules != nil && css.Modules.Types {
+			buildOptions.Metafile = true
+		}
+		buildOptions.Plugins = append(buildOptions.Plugins, cache.wrap(plugin))
+	}
+
+	// 按 import map 解析模块，需在 npm 解析之前
+	importMap, err := loadImportMap(config.ImportMap)
+	if err != nil {
+		return api.BuildOptions{}, err
+	}
+	if importMap != nil {
+		base := config.AbsWorkingDir
+		if base == "" {
+			base, _ = os.Getwd()
+		}
+		buildOptions.Plugins = append(buildOptions.Plugins, importMapPlugin(importMap, base, config.ImportMapExternal))
+	}
+
+	// 从 npm 归档解析裸模块
+	if config.Npm.enabled() {
+		buildOptions.Plugins = append(buildOptions.Plugins, npmResolvePlugin(*config.Npm, cache))
+	}
+
+	if config.Outfile != "" {
+		buildOptions.Outfile = config.Outfile
+	} else {
+		buildOptions.Outdir = config.Outdir
+	}
+	buildOptions.Outbase = config.Outbase
+	buildOptions.EntryNames = config.EntryNames
+	buildOptions.ChunkNames = config.ChunkNames
+	buildOptions.AssetNames = config.AssetNames
+	buildOptions.PublicPath = config.PublicPath
+
+	// 类型声明，库构建时与 package.json 的 types 目录一致
+	if config.Declaration {
+		dir := config.DeclarationDir
+		if dir == "" && config.Lib != nil {
+			dir = config.Lib.Types
+		}
+		if dir == "" {
+			dir = "types"
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(buildOutdir(&buildOptions), dir)
+		}
+		buildOptions.Metafile = true
+		buildOptions.Plugins = append(buildOptions.Plugins, declarationPlugin(dir))
+	}
+
+	// html 入口需要根据 metafile 重写页面
+	if len(pages) > 0 {
+		buildOptions.Metafile = true
+		buildOptions.Plugins = append(buildOptions.Plugins, htmlEntryPlugin(pages))
+	}
+	// 资源清单
+	if config.Manifest != "" {
+		buildOptions.Metafile = true
+		buildOptions.Plugins = append(buildOptions.Plugins, manifestPlugin(config.Manifest))
+	}
+
+	// 体积预算与预压缩
+	if len(config.Budgets) > 0 || len(config.Compress) > 0 {
+		buildOptions.Plugins = append(buildOptions.Plugins, sizeBudgetPlugin(config.Budgets, config.Compress))
+	}
+	return buildOptions, nil
+}
+
+// 辅助函数：解析 key=value 形式的全局常量定义，缺少值时为 true
+func parseDefines(defines []string) map[string]string {
+	if len(defines) == 0 {
+		return nil
+	}
+	result := make(map[string]string)
+	for _, d := range defines {
+		parts := strings.SplitN(d, "=", 2)
+		if len(parts) == 2 {
+			result[parts[0]] = parts[1]
+		} else {
+			result[parts[0]] = "true"
+		}
+	}
+	return result
 }
 
 // 辅助函数：解析平台类型
@@ -424,32 +891,6 @@ func parseFormat(format string) api.Format {
 	}
 }
 
-// 辅助函数：解析目标类型
-func parseTarget(target string) api.Target {
-	switch target {
-	case "es2024":
-		return api.ES2024
-	case "es2023":
-		return api.ES2023
-	case "es2022":
-		return api.ES2022
-	case "es2021":
-		return api.ES2021
-	case "es2020":
-		return api.ES2020
-	case "es2019":
-		return api.ES2019
-	case "es2018":
-		return api.ES2018
-	case "es2017":
-		return api.ES2017
-	case "es2016":
-		return api.ES2016
-	default:
-		return api.ES2015
-	}
-}
-
 // 辅助函数：解析JSX模式
 func parseJSX(jsx string) api.JSX {
 	switch jsx {
@@ -465,14 +906,14 @@ func parseJSX(jsx string) api.JSX {
 // 辅助函数：解析sourcemap选项
 func parseSourceMap(sourcemap string) api.SourceMap {
 	switch sourcemap {
+	case "true", "linked":
+		return api.SourceMapLinked
 	case "inline":
 		return api.SourceMapInline
 	case "external":
 		return api.SourceMapExternal
 	case "both":
 		return api.SourceMapInlineAndExternal
-	case "true":
-		return api.SourceMapInline
 	default:
 		return api.SourceMapNone
 	}
@@ -518,8 +959,9 @@ func parseLoader(loader string) api.Loader {
 	}
 }
 
-// runEsbuildOnce 执行一次 esbuild 构建
-func runEsbuildOnce(options api.BuildOptions) error {
+// runEsbuildOnce 执行一次 esbuild 构建，writeMeta 时写入 meta.json；
+// html 入口、清单等功能也会开启 Metafile，只在用户指定 --metafile 时写入
+func runEsbuildOnce(options api.BuildOptions, writeMeta bool) error {
 	result := api.Build(options)
 	if len(result.Errors) > 0 {
 		for _, err := range result.Errors {
@@ -534,7 +976,12 @@ func runEsbuildOnce(options api.BuildOptions) error {
 		}
 	}
 
-	if options.Metafile && len(result.Metafile) > 0 {
+	if !options.Write {
+		log.Printf("Build completed in memory, %d output files not written", len(result.OutputFiles))
+		return nil
+	}
+
+	if writeMeta && len(result.Metafile) > 0 {
 		metafilePath := "meta.json"
 		if options.Outdir != "" {
 			metafilePath = filepath.Join(options.Outdir, "meta.json")
@@ -550,38 +997,132 @@ func runEsbuildOnce(options api.BuildOptions) error {
 	return nil
 }
 
-// runEsbuildWatch 监控文件变化并执行 esbuild
-func runEsbuildWatch(ctx context.Context, options api.BuildOptions) error {
-	// 创建构建上下文
-	buildCtx, err := api.Context(options)
+// watchBuild 监听模式下由配置创建的构建，配置相关的文件变化后整体替换
+type watchBuild struct {
+	context  api.BuildContext
+	settings watchSettings
+	hooks    *hookRunner
+	inputs   []string   // 影响构建选项的文件：配置文件、tsconfig、package.json 与 .env
+	failed   chan error // notify 模式下监听失败
+}
+
+// newWatchBuild 根据配置创建构建上下文，此时尚未开始构建
+func newWatchBuild(ctx context.Context, configFile string, config EsbuildConfig) (*watchBuild, error) {
+	inputs := configInputs(configFile, &config)
+	buildOptions, err := esbuildOptions(&config)
 	if err != nil {
-		return fmt.Errorf("failed to create build context: %v", err)
+		return nil, err
 	}
-	defer buildCtx.Dispose()
-
-	// 第一次构建
-	result := buildCtx.Rebuild()
-	if len(result.Errors) > 0 {
-		for _, err := range result.Errors {
-			log.Printf("Error: %s", err.Text)
+	settings := watchSettings{
+		delay: time.Duration(config.WatchDelay) * time.Millisecond,
+		mode:  config.WatchMode,
+		dir:   buildWorkDir(&buildOptions),
+	}
+	if settings.mode != "" && settings.mode != "poll" && settings.mode != "notify" {
+		return nil, fmt.Errorf("unknown watch mode %q, expected poll or notify", settings.mode)
+	}
+	if settings.delay <= 0 {
+		settings.delay = defaultWatchDelay
+	}
+	if outdir := buildOutdir(&buildOptions); outdir != "" && outdir != settings.dir {
+		settings.ignore = append(settings.ignore, outdir)
+	} else if buildOptions.Outfile != "" {
+		settings.ignore = append(settings.ignore, filepath.Join(settings.dir, buildOptions.Outfile))
+	}
+	b := &watchBuild{settings: settings, inputs: inputs, failed: make(chan error, 1)}
+	// 钩子放在最后，等待其他插件完成输出
+	if len(config.OnSuccess) > 0 || len(config.OnFailure) > 0 {
+		b.hooks = newHookRunner(ctx, config.OnSuccess, config.OnFailure, time.Duration(config.HookDelay)*time.Millisecond)
+		buildOptions.Plugins = append(buildOptions.Plugins, b.hooks.plugin())
+	}
+	buildOptions.Plugins = append(buildOptions.Plugins, watchReportPlugin())
+	buildCtx, ctxErr := api.Context(buildOptions)
+	if ctxErr != nil {
+		if b.hooks != nil {
+			b.hooks.stop()
 		}
-		return fmt.Errorf("initial build failed with %d errors", len(result.Errors))
+		return nil, fmt.Errorf("failed to create build context: %v", ctxErr)
 	}
+	b.context = buildCtx
+	return b, nil
+}
 
-	// 启动 watch 模式
-	watchErr := buildCtx.Watch(api.WatchOptions{
-		Delay: 100,
-	})
-
-	if watchErr != nil {
-		return fmt.Errorf("failed to start watch mode: %v", watchErr)
+// start 执行第一次构建并开始监听，返回停止监听并释放上下文的函数
+func (b *watchBuild) start(ctx context.Context) (func(), error) {
+	// 第一次构建，失败时继续监听等待修复
+	b.context.Rebuild()
+	if b.settings.mode == "notify" {
+		log.Printf("Watching %s for changes... (press Ctrl+C to stop)", b.settings.dir)
+		notifyCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			if err := watchNotify(notifyCtx, b.context, b.settings); err != nil {
+				b.failed <- err
+			}
+		}()
+		return func() {
+			cancel()
+			<-done
+			b.dispose()
+		}, nil
+	}
+	if err := b.context.Watch(api.WatchOptions{Delay: int(b.settings.delay / time.Millisecond)}); err != nil {
+		b.dispose()
+		return nil, fmt.Errorf("failed to start watch mode: %v", err)
 	}
-
 	log.Println("Watching for changes... (press Ctrl+C to stop)")
+	return b.dispose, nil
+}
 
-	// 监听上下文取消信号
-	<-ctx.Done()
-	log.Println("Stopping watch mode...")
+// dispose 取消进行中的构建并释放上下文，避免留下写了一半的输出
+func (b *watchBuild) dispose() {
+	b.context.Cancel()
+	b.context.Dispose()
+	if b.hooks != nil {
+		b.hooks.stop()
+	}
+}
 
-	return nil
+// runEsbuildWatch 监控文件变化并执行 esbuild，每次构建的结果由 watchReportPlugin 输出；
+// 配置相关的文件变化后调用 reload 创建新的构建替换当前构建，配置错误时保留当前构建
+func runEsbuildWatch(ctx context.Context, build *watchBuild, reload func() (*watchBuild, error)) error {
+	inputs, err := newInputWatcher()
+	if err != nil {
+		build.dispose()
+		return err
+	}
+	defer inputs.close()
+	for {
+		inputs.set(build.inputs, build.settings.delay)
+		stop, err := build.start(ctx)
+		if err != nil {
+			return err
+		}
+		var next *watchBuild
+		for next == nil {
+			select {
+			case <-ctx.Done():
+				log.Println("Stopping watch mode...")
+				stop()
+				return nil
+			case err := <-build.failed:
+				stop()
+				return err
+			case name := <-inputs.changes:
+				if wd, err := os.Getwd(); err == nil {
+					if rel, err := filepath.Rel(wd, name); err == nil && !strings.HasPrefix(rel, "..") {
+						name = rel
+					}
+				}
+				log.Printf("%s changed, reloading build configuration", name)
+				if next, err = reload(); err != nil {
+					log.Printf("Configuration error: %v", err)
+					log.Println("Keep watching with the previous configuration")
+				}
+			}
+		}
+		stop()
+		build = next
+	}
 }
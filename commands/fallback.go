@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+)
+
+// fallbackRule URL 前缀下找不到文件时返回的页面
+type fallbackRule struct {
+	prefix string // URL 前缀，以 / 结尾
+	page   string // 页面的 URL 路径
+}
+
+// parseFallbackRules 解析 [/prefix/=]page 形式的规则，page 不以 / 开头时相对前缀，
+// 前缀更长的规则优先
+func parseFallbackRules(values []string) ([]fallbackRule, error) {
+	var rules []fallbackRule
+	for _, v := range values {
+		prefix, page, ok := strings.Cut(v, "=")
+		if !ok {
+			prefix, page = "/", v
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid fallback %q, prefix must start with /", v)
+		}
+		if page == "" {
+			return nil, fmt.Errorf("invalid fallback %q, missing page", v)
+		}
+		if !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		if !strings.HasPrefix(page, "/") {
+			page = prefix + page
+		}
+		rules = append(rules, fallbackRule{prefix: prefix, page: path.Clean(page)})
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].prefix) > len(rules[j].prefix) })
+	return rules, nil
+}
+
+// matchFallback 返回匹配 URL 路径的页面，没有匹配时为空
+func matchFallback(rules []fallbackRule, urlPath string) string {
+	for _, rule := range rules {
+		if strings.HasPrefix(urlPath, rule.prefix) || urlPath+"/" == rule.prefix {
+			return rule.page
+		}
+	}
+	return ""
+}
+
+// acceptsDocument 判断是否为浏览器导航：GET 或 HEAD、接受 html、路径不像静态资源
+func acceptsDocument(r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	// 客户端路由中的点（例如 /users/john.doe）不是资源扩展名
+	ext := path.Ext(r.URL.Path)
+	return ext == "" || mime.TypeByExtension(ext) == ""
+}
+
+// notFoundWriter 丢弃 404 响应，由调用方改为返回回退页面
+type notFoundWriter struct {
+	http.ResponseWriter
+	notFound bool
+}
+
+func (w *notFoundWriter) WriteHeader(status int) {
+	if status == http.StatusNotFound {
+		w.notFound = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *notFoundWriter) Write(b []byte) (int, error) {
+	if w.notFound {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// serveFallback 文件不存在时，导航请求返回 SPA 文档，其他请求返回 404 页面
+func (h *hotReloadHandler) serveFallback(w http.ResponseWriter, r *http.Request) {
+	// 清除文件服务器为 404 设置的响应头
+	for _, key := range []string{"Content-Type", "X-Content-Type-Options", "Content-Length"} {
+		w.Header().Del(key)
+	}
+	if page := matchFallback(h.spa, r.URL.Path); page != "" && acceptsDocument(r) {
+		if h.servePage(w, r, page, http.StatusOK) {
+			return
+		}
+	}
+	if page := matchFallback(h.notFound, r.URL.Path); page != "" {
+		if h.servePage(w, r, page, http.StatusNotFound) {
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// servePage 以指定状态返回页面，html 页面同样注入热重载脚本，页面不存在时返回 false
+func (h *hotReloadHandler) servePage(w http.ResponseWriter, r *http.Request, page string, status int) bool {
+	req := r.Clone(r.Context())
+	req.Method = http.MethodGet
+	// 文件服务器会把 /index.html 重定向到目录
+	if path.Base(page) == "index.html" {
+		page = strings.TrimSuffix(page, "index.html")
+	}
+	req.URL.Path = page
+	req.URL.RawPath = ""
+	// 页面与请求的 URL 不同，条件请求与范围请求不适用
+	for _, key := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range", "Range"} {
+		req.Header.Del(key)
+	}
+	rec := newResponseRecorder()
+	h.serveDocument(rec, req)
+	if rec.status != http.StatusOK {
+		return false
+	}
+	rec.status = status
+	if status != http.StatusOK {
+		rec.Header().Del("ETag")
+		rec.Header().Del("Last-Modified")
+	}
+	rec.Header().Set("Cache-Control", "no-cache")
+	rec.flush(w, rec.buf.String())
+	return true
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeFallback(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":     "<html><body>root app</body></html>",
+		"404.html":       "<html><body>not here</body></html>",
+		"admin/app.html": "<html><body>admin app</body></html>",
+		"main.js":        "console.log(1)",
+	}
+	writeTree(t, dir, files)
+	spa, err := parseFallbackRules([]string{"index.html", "/admin=app.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notFound, err := parseFallbackRules([]string{"/404.html"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &hotReloadHandler{fs: http.FileServer(http.Dir(dir)), dir: dir, injectExts: []string{".html"}, spa: spa, notFound: notFound}
+	cases := []struct {
+		path, accept string
+		status       int
+		body         string
+	}{
+		{"/settings/profile", "text/html,application/xhtml+xml", 200, "root app"},
+		{"/users/john.doe", "text/html", 200, "root app"},
+		{"/admin/users/1", "text/html", 200, "admin app"},
+		{"/missing.js", "text/html", 404, "not here"},
+		{"/api/users", "application/json", 404, "not here"},
+		{"/main.js", "*/*", 200, "console.log(1)"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.path, nil)
+		req.Header.Set("Accept", c.accept)
+		req.Header.Set("If-None-Match", `"stale"`)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		body := rec.Body.String()
+		if rec.Code != c.status || !strings.Contains(body, c.body) {
+			t.Errorf("%s: got %d %q, want %d %q", c.path, rec.Code, body, c.status, c.body)
+		}
+		if strings.HasSuffix(c.body, "app") || c.body == "not here" {
+			if !strings.Contains(body, "/_hotreload") || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+				t.Errorf("%s: expected injected html, got %q %v", c.path, body, rec.Header())
+			}
+		}
+	}
+	if _, err := parseFallbackRules([]string{"admin=app.html"}); err == nil {
+		t.Error("expected error for prefix without leading slash")
+	}
+}
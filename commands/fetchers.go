@@ -152,24 +152,31 @@ func typingFromFile(pkg string, o string) error {
 		// 只处理 .ts.d 文件
 		if strings.HasSuffix(header.Name, ".d.ts") {
 			extractedFiles++
-			targetPath := filepath.Join(o, strings.ReplaceAll(header.Name, "package/", ""))
-			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-				return fmt.Errorf("create directory: %w", err)
-			}
-			outFile, err := os.Create(targetPath)
-			if err != nil {
-				return fmt.Errorf("create file: %s: %w", targetPath, err)
-			}
-			// 复制文件内容
-			if _, err := io.Copy(outFile, tr); err != nil {
-				outFile.Close()
-				return fmt.Errorf("write file: %s: %w", targetPath, err)
+			if err := writeTyping(o, strings.ReplaceAll(header.Name, "package/", ""), tr); err != nil {
+				return err
 			}
-			outFile.Close()
 		}
 	}
 	return nil
 }
+
+// writeTyping 将类型声明写入输出目录下的相对路径
+func writeTyping(o, name string, r io.Reader) error {
+	targetPath := filepath.Join(o, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	outFile, err := os.Create(targetPath)
+	if err != nil {
+		return fmt.Errorf("create file: %s: %w", targetPath, err)
+	}
+	// 复制文件内容
+	if _, err := io.Copy(outFile, r); err != nil {
+		outFile.Close()
+		return fmt.Errorf("write file: %s: %w", targetPath, err)
+	}
+	return outFile.Close()
+}
 func typingFromFolder(pkg string, o string) error {
 	return filepath.Walk(pkg, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
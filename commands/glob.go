@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// expandBraces 展开模式中的 {a,b} 分组，例如 *.{ts,tsx} -> *.ts, *.tsx
+func expandBraces(pattern string) []string {
+	open := strings.IndexByte(pattern, '{')
+	if open < 0 {
+		return []string{pattern}
+	}
+	depth := 0
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				var out []string
+				start := open + 1
+				var alternatives []string
+				level := 0
+				for j := open + 1; j < i; j++ {
+					switch pattern[j] {
+					case '{':
+						level++
+					case '}':
+						level--
+					case ',':
+						if level == 0 {
+							alternatives = append(alternatives, pattern[start:j])
+							start = j + 1
+						}
+					}
+				}
+				alternatives = append(alternatives, pattern[start:i])
+				for _, alt := range alternatives {
+					out = append(out, expandBraces(pattern[:open]+alt+pattern[i+1:])...)
+				}
+				return out
+			}
+		}
+	}
+	return []string{pattern}
+}
+
+// matchGlob 按 / 分隔的路径匹配模式，** 匹配任意层目录，支持 {a,b} 分组
+func matchGlob(pattern, name string) bool {
+	for _, p := range expandBraces(pattern) {
+		if matchGlobParts(strings.Split(p, "/"), strings.Split(name, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlobParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobParts(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// globBase 返回模式中不包含通配符的目录前缀
+func globBase(pattern string) string {
+	parts := strings.Split(filepath.ToSlash(pattern), "/")
+	var base []string
+	for _, part := range parts[:len(parts)-1] {
+		if strings.ContainsAny(part, "*?[{") {
+			break
+		}
+		base = append(base, part)
+	}
+	if len(base) == 0 {
+		return "."
+	}
+	if len(base) == 1 && base[0] == "" {
+		return "/"
+	}
+	return filepath.FromSlash(strings.Join(base, "/"))
+}
+
+// expandGlob 展开模式为匹配的文件列表，跳过以 . 开头的目录
+func expandGlob(pattern string) ([]string, error) {
+	base := globBase(pattern)
+	slashed := filepath.ToSlash(filepath.Clean(pattern))
+	var files []string
+	err := filepath.WalkDir(base, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != base && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchGlob(slashed, filepath.ToSlash(p)) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
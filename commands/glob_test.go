@@ -0,0 +1,28 @@
+package commands
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"src/**/*.ts", "src/a.ts", true},
+		{"src/**/*.ts", "src/a/b/c.ts", true},
+		{"src/**/*.ts", "lib/a.ts", false},
+		{"**/*.{ts,tsx}", "a/b.tsx", true},
+		{"*.css", "a/b.css", false},
+		{"assets/**", "assets/img/logo.png", true},
+		{"a/{b,c/{d,e}}/*.js", "a/c/e/x.js", true},
+	}
+	for _, c := range cases {
+		if got := matchGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+	for pattern, want := range map[string]string{"src/**/*.ts": "src", "*.ts": ".", "a/b/{c,d}/*.js": "a/b"} {
+		if got := globBase(pattern); got != want {
+			t.Errorf("globBase(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
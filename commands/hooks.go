@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// BuildHook 监听模式下构建结束后执行的钩子，配置中可以直接写 shell 命令字符串
+type BuildHook struct {
+	Run    string            `json:"run"`    // shell 命令
+	Action string            `json:"action"` // 内置动作：copy、remove、mkdir
+	Args   []string          `json:"args"`   // 内置动作参数，copy 为 [源, 目标目录]
+	Dir    string            `json:"dir"`    // 命令的工作目录
+	Env    map[string]string `json:"env"`    // 额外的环境变量
+	Daemon bool              `json:"daemon"` // 长期运行的进程（例如 node 服务），每次重新构建后重启
+}
+
+func (h *BuildHook) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &h.Run); err == nil {
+		return nil
+	}
+	type plain BuildHook
+	return json.Unmarshal(data, (*plain)(h))
+}
+
+func (h *BuildHook) String() string {
+	if h.Run != "" {
+		return h.Run
+	}
+	return strings.TrimSpace(h.Action + " " + strings.Join(h.Args, " "))
+}
+
+// defaultHookDelay 连续重新构建时钩子的防抖时间
+const defaultHookDelay = 300 * time.Millisecond
+
+// daemonStopTimeout 结束常驻进程时等待退出的时间，超时后强制结束
+const daemonStopTimeout = 5 * time.Second
+
+// hookDaemon 正在运行的常驻进程
+type hookDaemon struct {
+	cmd    *exec.Cmd
+	exited chan struct{}
+}
+
+// hookRunner 在构建结束后按结果执行钩子：防抖合并连续的构建，
+// 新的构建结束时取消仍在执行的钩子并重启常驻进程
+type hookRunner struct {
+	ctx       context.Context
+	onSuccess []BuildHook
+	onFailure []BuildHook
+	delay     time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	cancel  context.CancelFunc // 取消当前执行中的钩子
+	done    chan struct{}      // 当前钩子执行结束时关闭
+	daemons []*hookDaemon
+	stopped bool
+}
+
+func newHookRunner(ctx context.Context, onSuccess, onFailure []BuildHook, delay time.Duration) *hookRunner {
+	if delay <= 0 {
+		delay = defaultHookDelay
+	}
+	return &hookRunner{ctx: ctx, onSuccess: onSuccess, onFailure: onFailure, delay: delay}
+}
+
+// plugin 返回在构建结束时调度钩子的插件，应放在其他插件之后
+func (r *hookRunner) plugin() api.Plugin {
+	return api.Plugin{
+		Name: "hooks",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				hooks := r.onSuccess
+				status := "success"
+				if len(result.Errors) > 0 {
+					hooks, status = r.onFailure, "failure"
+				}
+				if len(hooks) == 0 {
+					return api.OnEndResult{}, nil
+				}
+				outputs := make([]string, 0, len(result.OutputFiles))
+				for _, f := range result.OutputFiles {
+					outputs = append(outputs, f.Path)
+				}
+				env := []string{
+					"UNITS_BUILD_STATUS=" + status,
+					"UNITS_BUILD_ERRORS=" + strconv.Itoa(len(result.Errors)),
+					"UNITS_BUILD_WARNINGS=" + strconv.Itoa(len(result.Warnings)),
+					"UNITS_BUILD_OUTDIR=" + buildOutdir(options),
+					"UNITS_BUILD_OUTPUTS=" + strings.Join(outputs, string(os.PathListSeparator)),
+				}
+				r.schedule(hooks, env)
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
+
+// schedule 延迟执行钩子，延迟期间再次调度时只执行最后一次
+func (r *hookRunner) schedule(hooks []BuildHook, env []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(r.delay, func() { r.fire(hooks, env) })
+}
+
+// fire 取消上一轮钩子并结束常驻进程，然后按顺序执行钩子，失败时停止后续钩子
+func (r *hookRunner) fire(hooks []BuildHook, env []string) {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	previous := r.done
+	ctx, cancel := context.WithCancel(r.ctx)
+	done := make(chan struct{})
+	r.cancel, r.done = cancel, done
+	r.mu.Unlock()
+
+	defer close(done)
+	if previous != nil {
+		<-previous
+	}
+	r.stopDaemons()
+	for i := range hooks {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := r.runHook(ctx, &hooks[i], env); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Hook %q failed: %v", hooks[i].String(), err)
+			}
+			return
+		}
+	}
+}
+
+// runHook 执行单个钩子，常驻进程启动后立即返回
+func (r *hookRunner) runHook(ctx context.Context, h *BuildHook, env []string) error {
+	if h.Run == "" {
+		return runHookAction(h)
+	}
+	cmd := shellCommand(h.Run)
+	cmd.Dir = h.Dir
+	cmd.Env = append(os.Environ(), env...)
+	for k, v := range h.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = nil, os.Stdout, os.Stderr
+	setProcessGroup(cmd)
+	log.Printf("Hook: %s", h.Run)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	var waitErr error
+	go func() {
+		waitErr = cmd.Wait()
+		close(exited)
+	}()
+	if h.Daemon {
+		r.mu.Lock()
+		r.daemons = append(r.daemons, &hookDaemon{cmd: cmd, exited: exited})
+		r.mu.Unlock()
+		return nil
+	}
+	select {
+	case <-exited:
+		return waitErr
+	case <-ctx.Done():
+		stopProcess(cmd, exited)
+		return ctx.Err()
+	}
+}
+
+// stopDaemons 结束所有常驻进程的进程组
+func (r *hookRunner) stopDaemons() {
+	r.mu.Lock()
+	daemons := r.daemons
+	r.daemons = nil
+	r.mu.Unlock()
+	for _, d := range daemons {
+		stopProcess(d.cmd, d.exited)
+	}
+}
+
+// stop 取消等待中与执行中的钩子并结束常驻进程，在监听结束时调用
+func (r *hookRunner) stop() {
+	r.mu.Lock()
+	r.stopped = true
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+	done := r.done
+	r.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+	r.stopDaemons()
+}
+
+// stopProcess 先请求进程组退出，超时后强制结束
+func stopProcess(cmd *exec.Cmd, exited chan struct{}) {
+	select {
+	case <-exited:
+		return
+	default:
+	}
+	if err := terminateProcessGroup(cmd); err != nil {
+		log.Printf("Failed to stop %s: %v", cmd.Path, err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(daemonStopTimeout):
+		_ = killProcessGroup(cmd)
+		<-exited
+	}
+}
+
+// runHookAction 执行内置动作
+func runHookAction(h *BuildHook) error {
+	switch h.Action {
+	case "copy":
+		if len(h.Args) != 2 {
+			return fmt.Errorf("copy requires source and destination")
+		}
+		n, err := copyGlob(filepath.Join(h.Dir, h.Args[0]), filepath.Join(h.Dir, h.Args[1]))
+		if err == nil {
+			log.Printf("Hook: copied %d files to %s", n, h.Args[1])
+		}
+		return err
+	case "remove":
+		for _, p := range h.Args {
+			if err := os.RemoveAll(filepath.Join(h.Dir, p)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "mkdir":
+		for _, p := range h.Args {
+			if err := os.MkdirAll(filepath.Join(h.Dir, p), 0755); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "":
+		return fmt.Errorf("hook requires run or action")
+	default:
+		return fmt.Errorf("unknown hook action %q", h.Action)
+	}
+}
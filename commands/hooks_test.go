@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks test uses sh")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	runner := newHookRunner(context.Background(), []BuildHook{
+		{Run: "echo $UNITS_BUILD_STATUS $GREETING >> " + log, Env: map[string]string{"GREETING": "hi"}},
+		{Run: "sleep 30", Daemon: true},
+		{Action: "mkdir", Args: []string{filepath.Join(dir, "made")}},
+	}, nil, 50*time.Millisecond)
+
+	env := []string{"UNITS_BUILD_STATUS=success"}
+	runner.schedule(runner.onSuccess, env)
+	runner.schedule(runner.onSuccess, env)
+	waitFor(t, func() bool {
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		return len(runner.daemons) == 1
+	})
+	runner.mu.Lock()
+	first := runner.daemons[0]
+	runner.mu.Unlock()
+
+	runner.schedule(runner.onSuccess, env)
+	waitFor(t, func() bool {
+		select {
+		case <-first.exited:
+			return true
+		default:
+			return false
+		}
+	})
+	waitFor(t, func() bool {
+		runner.mu.Lock()
+		defer runner.mu.Unlock()
+		return len(runner.daemons) == 1 && runner.daemons[0] != first
+	})
+	runner.stop()
+	runner.mu.Lock()
+	if len(runner.daemons) != 0 {
+		t.Errorf("daemons still running after stop")
+	}
+	runner.mu.Unlock()
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Split(strings.TrimSpace(string(data)), "\n"); len(got) != 2 || got[0] != "success hi" {
+		t.Errorf("debounced hooks ran %q, want two runs of \"success hi\"", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "made")); err != nil {
+		t.Errorf("mkdir action not run: %v", err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
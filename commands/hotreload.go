@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"encoding/json"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// reloadEvent 热重载事件，以 JSON 通过 SSE 发送给页面
+type reloadEvent struct {
+	Type string `json:"type"`           // css 替换样式表，asset 刷新图片等资源，full 重新加载页面
+	Path string `json:"path,omitempty"` // 变化文件的 URL 路径
+}
+
+const (
+	reloadCSS   = "css"
+	reloadAsset = "asset"
+	reloadFull  = "full"
+)
+
+// fullReloadExts 变化后需要重新加载页面的文件：页面与脚本
+var fullReloadExts = map[string]bool{
+	".html": true, ".htm": true, ".xhtml": true,
+	".js": true, ".mjs": true, ".cjs": true, ".jsx": true, ".ts": true, ".tsx": true, ".mts": true, ".cts": true,
+	".json": true, ".wasm": true,
+}
+
+// reloadEventFor 根据 URL 路径的扩展名决定热重载方式，source map 不触发重载
+func reloadEventFor(urlPath string) (reloadEvent, bool) {
+	ext := strings.ToLower(path.Ext(urlPath))
+	switch {
+	case ext == ".map":
+		return reloadEvent{}, false
+	case ext == ".css":
+		return reloadEvent{Type: reloadCSS, Path: urlPath}, true
+	case fullReloadExts[ext] || ext == "":
+		return reloadEvent{Type: reloadFull}, true
+	}
+	return reloadEvent{Type: reloadAsset, Path: urlPath}, true
+}
+
+// reloadEvents 合并一组变化的 URL 路径，存在页面或脚本时只发送一次完整重载
+func reloadEvents(urlPaths []string) []reloadEvent {
+	var events []reloadEvent
+	for _, p := range urlPaths {
+		e, ok := reloadEventFor(p)
+		if !ok {
+			continue
+		}
+		if e.Type == reloadFull {
+			return []reloadEvent{e}
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// urlPathOf 站点目录中文件对应的 URL 路径，不在站点目录内时为空
+func (h *hotReloadHandler) urlPathOf(name string) string {
+	rel, err := filepath.Rel(h.dir, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return "/" + filepath.ToSlash(rel)
+}
+
+// notifyChanges 按变化的 URL 路径通知客户端
+func (h *hotReloadHandler) notifyChanges(urlPaths ...string) {
+	for _, e := range reloadEvents(urlPaths) {
+		h.notifyClients(e)
+	}
+}
+
+// notifyClients 通知所有客户端
+func (h *hotReloadHandler) notifyClients(e reloadEvent) {
+	msg, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("Failed to encode reload event: %v", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		select {
+		case client <- msg:
+		default:
+			// 如果客户端无法接收消息，跳过
+		}
+	}
+}
+
+// hotReloadScript 注入页面的热重载脚本：样式表原地替换，图片刷新地址，页面与脚本重新加载
+const hotReloadScript = `
+<script>
+	(function() {
+		const bust = function(url) {
+			const u = new URL(url, location.href);
+			u.searchParams.set("_hr", Date.now());
+			return u.href;
+		};
+		const same = function(url, path) {
+			return url && new URL(url, location.href).pathname === path;
+		};
+		const swapStylesheets = function(path) {
+			const links = Array.from(document.querySelectorAll('link[rel="stylesheet"]'));
+			// 没有直接引用时（例如 @import 或样式中的资源）刷新全部样式表
+			const matched = links.filter(function(link) { return same(link.href, path); });
+			(matched.length ? matched : links).forEach(function(link) {
+				const next = link.cloneNode();
+				next.href = bust(link.href);
+				next.onload = next.onerror = function() { link.remove(); };
+				link.after(next);
+			});
+		};
+		const refreshImages = function(path) {
+			let found = false;
+			document.querySelectorAll("img").forEach(function(img) {
+				if (same(img.src, path)) {
+					img.src = bust(img.src);
+					found = true;
+				}
+			});
+			if (!found) {
+				swapStylesheets(path);
+			}
+		};
+		const evtSource = new EventSource("/_hotreload");
+		evtSource.onmessage = function(e) {
+			let event;
+			try {
+				event = JSON.parse(e.data);
+			} catch (err) {
+				event = {type: "full"};
+			}
+			switch (event.type) {
+			case "css":
+				console.log("Updating stylesheet " + event.path);
+				swapStylesheets(event.path);
+				break;
+			case "asset":
+				console.log("Updating asset " + event.path);
+				refreshImages(event.path);
+				break;
+			default:
+				console.log("Reloading page...");
+				location.reload();
+			}
+		};
+		evtSource.onerror = function() {
+			console.log("EventSource error. Closing connection.");
+			evtSource.close();
+		};
+	})();
+</script>
+`
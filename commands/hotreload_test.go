@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadEvents(t *testing.T) {
+	cases := []struct {
+		paths []string
+		want  []reloadEvent
+	}{
+		{[]string{"/css/app.css", "/css/app.css.map"}, []reloadEvent{{Type: reloadCSS, Path: "/css/app.css"}}},
+		{[]string{"/img/logo.PNG"}, []reloadEvent{{Type: reloadAsset, Path: "/img/logo.PNG"}}},
+		{[]string{"/app.css", "/app.js"}, []reloadEvent{{Type: reloadFull}}},
+		{[]string{"/index.html"}, []reloadEvent{{Type: reloadFull}}},
+		{[]string{"/app.js.map"}, nil},
+	}
+	for _, c := range cases {
+		got := reloadEvents(c.paths)
+		if len(got) != len(c.want) {
+			t.Errorf("%v: got %v, want %v", c.paths, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%v: got %v, want %v", c.paths, got, c.want)
+			}
+		}
+	}
+
+	h := &hotReloadHandler{dir: "/site", clients: make(map[chan []byte]bool), shutdown: make(chan struct{})}
+	if p := h.urlPathOf("/site/css/app.css"); p != "/css/app.css" {
+		t.Errorf("unexpected url path %q", p)
+	}
+	if p := h.urlPathOf("/other/app.css"); p != "" {
+		t.Errorf("unexpected url path %q for a file outside the site", p)
+	}
+	server := httptest.NewServer(h)
+	defer server.Close()
+	defer close(h.shutdown)
+	resp, err := http.Get(server.URL + "/_hotreload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		h.mu.Lock()
+		n := len(h.clients)
+		h.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client not registered")
+		}
+	}
+	h.notifyChanges("/app.css", "/logo.png")
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			lines = append(lines, data)
+		}
+	}
+	if got := strings.Join(lines, "\n"); got != `{"type":"css","path":"/app.css"}`+"\n"+`{"type":"asset","path":"/logo.png"}` {
+		t.Errorf("unexpected events %s", got)
+	}
+}
@@ -0,0 +1,413 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// htmlPage 作为入口的 html 页面
+type htmlPage struct {
+	source  string   // html 源文件绝对路径
+	output  string   // html 输出文件绝对路径
+	scripts []string // <script type="module" src> 引用的脚本绝对路径
+	styles  []string // <link rel="stylesheet" href> 引用的样式绝对路径
+}
+
+var (
+	htmlTagPattern  = regexp.MustCompile(`(?is)<(script|link|img|source|video|audio)\b[^>]*>`)
+	htmlAttrPattern = regexp.MustCompile(`(?is)(\s)([a-z-]+)(?:\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+))?`)
+	htmlHeadClose   = regexp.MustCompile(`(?i)</head>`)
+)
+
+// htmlAssetAttrs 静态资源所在的标签属性
+var htmlAssetAttrs = map[string][]string{
+	"img":    {"src"},
+	"source": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"link":   {"href"},
+}
+
+// htmlSrcsetAttrs 含有 srcset 候选列表的标签
+var htmlSrcsetAttrs = map[string]bool{"img": true, "source": true}
+
+// htmlAttrs 解析标签属性，属性名统一为小写
+func htmlAttrs(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range htmlAttrPattern.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[2])] = strings.Trim(m[3], `"'`)
+	}
+	return attrs
+}
+
+// setHTMLAttr 替换标签中的属性值
+func setHTMLAttr(tag, name, value string) string {
+	for _, loc := range htmlAttrPattern.FindAllStringSubmatchIndex(tag, -1) {
+		if strings.EqualFold(tag[loc[4]:loc[5]], name) && loc[6] >= 0 {
+			return tag[:loc[6]] + `"` + value + `"` + tag[loc[7]:]
+		}
+	}
+	return tag
+}
+
+// isLocalReference 判断是否为需要构建的本地引用
+func isLocalReference(ref string) bool {
+	return ref != "" && !isURLSpecifier(ref) && !strings.Contains(ref, ":") && !strings.HasPrefix(ref, "#")
+}
+
+// htmlRefPath 将 html 中的引用转换为文件路径，/ 开头的引用相对于 html 所在目录
+func htmlRefPath(html, ref string) string {
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+	return filepath.Join(filepath.Dir(html), filepath.FromSlash(strings.TrimPrefix(ref, "/")))
+}
+
+// htmlLinkRel 判断 link 标签的 rel 是否包含指定值
+func htmlLinkRel(attrs map[string]string, rel string) bool {
+	for _, r := range strings.Fields(strings.ToLower(attrs["rel"])) {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTMLEntry 判断入口是否为 html 页面
+func isHTMLEntry(entry string) bool {
+	return strings.EqualFold(filepath.Ext(entry), ".html") || strings.EqualFold(filepath.Ext(entry), ".htm")
+}
+
+// htmlEntryPoints 从入口中拆分出 html 页面，返回其余入口和页面中发现的脚本与样式入口
+func htmlEntryPoints(entries []string, outdir, outbase string) ([]string, []*htmlPage, error) {
+	var rest, sources []string
+	for _, entry := range entries {
+		if isHTMLEntry(entry) {
+			abs, err := filepath.Abs(entry)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get absolute path: %v", err)
+			}
+			sources = append(sources, abs)
+		} else {
+			rest = append(rest, entry)
+		}
+	}
+	if len(sources) == 0 {
+		return entries, nil, nil
+	}
+	if outdir == "" {
+		return nil, nil, fmt.Errorf("html entry points require outdir")
+	}
+	absOut, err := filepath.Abs(outdir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	base := outbase
+	if base == "" {
+		base = commonDir(sources)
+	}
+	if base, err = filepath.Abs(base); err != nil {
+		return nil, nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	seen := make(map[string]bool)
+	for _, entry := range rest {
+		if abs, err := filepath.Abs(entry); err == nil {
+			seen[abs] = true
+		}
+	}
+	var pages []*htmlPage
+	for _, source := range sources {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read html entry: %v", err)
+		}
+		rel, err := filepath.Rel(base, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		page := &htmlPage{source: source, output: filepath.Join(absOut, rel)}
+		if page.output == page.source {
+			return nil, nil, fmt.Errorf("html output %s would overwrite its source", page.output)
+		}
+		for _, tag := range htmlTagPattern.FindAllStringSubmatch(string(data), -1) {
+			attrs := htmlAttrs(tag[0])
+			switch name := strings.ToLower(tag[1]); {
+			case name == "script":
+				if strings.EqualFold(attrs["type"], "module") && isLocalReference(attrs["src"]) {
+					page.scripts = append(page.scripts, htmlRefPath(source, attrs["src"]))
+				}
+			case name == "link" && htmlLinkRel(attrs, "stylesheet"):
+				if isLocalReference(attrs["href"]) {
+					page.styles = append(page.styles, htmlRefPath(source, attrs["href"]))
+				}
+			}
+		}
+		for _, file := range append(page.scripts, page.styles...) {
+			if !seen[file] {
+				seen[file] = true
+				rest = append(rest, file)
+			}
+		}
+		pages = append(pages, page)
+	}
+	return rest, pages, nil
+}
+
+// commonDir 计算文件的公共父目录
+func commonDir(files []string) string {
+	dir := filepath.Dir(files[0])
+	for _, file := range files[1:] {
+		for {
+			rel, err := filepath.Rel(dir, file)
+			if err == nil && !strings.HasPrefix(rel, "..") {
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return dir
+}
+
+// htmlEntryPlugin 在每次构建结束后根据 metafile 重写 html 页面并复制静态资源
+func htmlEntryPlugin(pages []*htmlPage) api.Plugin {
+	return api.Plugin{
+		Name: "html-entry",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				if len(result.Errors) > 0 || result.Metafile == "" {
+					return api.OnEndResult{}, nil
+				}
+				meta, err := parseMetafile(result.Metafile)
+				if err != nil {
+					return api.OnEndResult{}, err
+				}
+				var end api.OnEndResult
+				for _, page := range pages {
+					files, warnings, err := page.render(meta, options)
+					if err != nil {
+						return api.OnEndResult{}, err
+					}
+					for _, w := range warnings {
+						end.Warnings = append(end.Warnings, api.Message{Text: w, PluginName: "html-entry"})
+					}
+					// 不写入磁盘时页面与资源加入输出文件，与构建产物一起由内存文件系统提供
+					if !options.Write {
+						result.OutputFiles = append(result.OutputFiles, files...)
+						continue
+					}
+					for _, f := range files {
+						if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+							return api.OnEndResult{}, fmt.Errorf("create directory: %w", err)
+						}
+						if err := os.WriteFile(f.Path, f.Contents, 0644); err != nil {
+							return api.OnEndResult{}, fmt.Errorf("failed to write %s: %v", f.Path, err)
+						}
+					}
+				}
+				return end, nil
+			})
+		},
+	}
+}
+
+// render 重写页面引用为构建输出文件名，返回页面与引用的静态资源
+func (p *htmlPage) render(meta *metafile, options *api.BuildOptions) ([]api.OutputFile, []string, error) {
+	data, err := os.ReadFile(p.source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read html entry: %v", err)
+	}
+	workDir := options.AbsWorkingDir
+	if workDir == "" {
+		workDir, _ = os.Getwd()
+	}
+	outdir, err := filepath.Abs(options.Outdir)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		warnings []string
+		files    []api.OutputFile
+	)
+	// url 计算输出文件相对于页面的引用地址
+	url := func(out string) string {
+		abs := filepath.Join(workDir, filepath.FromSlash(out))
+		if options.PublicPath != "" {
+			rel, _ := filepath.Rel(outdir, abs)
+			return strings.TrimSuffix(options.PublicPath, "/") + "/" + filepath.ToSlash(rel)
+		}
+		rel, _ := filepath.Rel(filepath.Dir(p.output), abs)
+		return filepath.ToSlash(rel)
+	}
+	input := func(file string) string {
+		rel, err := filepath.Rel(workDir, file)
+		if err != nil {
+			return file
+		}
+		return filepath.ToSlash(rel)
+	}
+	var head []string
+	addHead := func(tag string) {
+		for _, h := range head {
+			if h == tag {
+				return
+			}
+		}
+		head = append(head, tag)
+	}
+	html := htmlTagPattern.ReplaceAllStringFunc(string(data), func(tag string) string {
+		name := strings.ToLower(htmlTagPattern.FindStringSubmatch(tag)[1])
+		attrs := htmlAttrs(tag)
+		switch {
+		case name == "script":
+			if !strings.EqualFold(attrs["type"], "module") || !isLocalReference(attrs["src"]) {
+				return tag
+			}
+			entry := input(htmlRefPath(p.source, attrs["src"]))
+			out, o := meta.entryOutput(entry, ".js")
+			if o == nil {
+				warnings = append(warnings, fmt.Sprintf("%s: no output for script %s", p.source, attrs["src"]))
+				return tag
+			}
+			for _, chunk := range meta.staticChunks(out) {
+				addHead(fmt.Sprintf(`<link rel="modulepreload" href="%s">`, url(chunk)))
+			}
+			if o.CssBundle != "" {
+				addHead(fmt.Sprintf(`<link rel="stylesheet" href="%s">`, url(o.CssBundle)))
+			}
+			return setHTMLAttr(tag, "src", url(out))
+		case name == "link" && htmlLinkRel(attrs, "stylesheet"):
+			if !isLocalReference(attrs["href"]) {
+				return tag
+			}
+			out, o := meta.entryOutput(input(htmlRefPath(p.source, attrs["href"])), ".css")
+			if o == nil {
+				warnings = append(warnings, fmt.Sprintf("%s: no output for stylesheet %s", p.source, attrs["href"]))
+				return tag
+			}
+			return setHTMLAttr(tag, "href", url(out))
+		case name == "link" && (htmlLinkRel(attrs, "modulepreload") || htmlLinkRel(attrs, "preconnect") || htmlLinkRel(attrs, "dns-prefetch")):
+			return tag
+		}
+		// assetURL 复制引用的资源，返回输出文件的引用地址
+		assetURL := func(ref string) (string, bool) {
+			if !isLocalReference(ref) {
+				return ref, false
+			}
+			asset, err := p.asset(htmlRefPath(p.source, ref), outdir, options.AssetNames)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %v", p.source, err))
+				return ref, false
+			}
+			files = append(files, asset)
+			rel, _ := filepath.Rel(workDir, asset.Path)
+			return url(filepath.ToSlash(rel)), true
+		}
+		for _, attr := range htmlAssetAttrs[name] {
+			if ref, ok := assetURL(attrs[attr]); ok {
+				tag = setHTMLAttr(tag, attr, ref)
+			}
+		}
+		if srcset := attrs["srcset"]; htmlSrcsetAttrs[name] && srcset != "" {
+			tag = setHTMLAttr(tag, "srcset", rewriteSrcset(srcset, assetURL))
+		}
+		return tag
+	})
+	if len(head) > 0 {
+		tags := strings.Join(head, "\n") + "\n"
+		if loc := htmlHeadClose.FindStringIndex(html); loc != nil {
+			html = html[:loc[0]] + tags + html[loc[0]:]
+		} else {
+			html = tags + html
+		}
+	}
+	files = append(files, outputFile(p.output, []byte(html)))
+	return files, warnings, nil
+}
+
+// rewriteSrcset 替换 srcset 中每个候选的地址，保留宽度与像素密度描述
+func rewriteSrcset(srcset string, rewrite func(ref string) (string, bool)) string {
+	var candidates []string
+	for i := 0; i < len(srcset); {
+		// 候选之间以逗号分隔，地址中可以含有逗号（例如 data: 地址），以空白结束
+		for i < len(srcset) && (srcset[i] == ',' || isHTMLSpace(srcset[i])) {
+			i++
+		}
+		start := i
+		for i < len(srcset) && !isHTMLSpace(srcset[i]) {
+			i++
+		}
+		ref := srcset[start:i]
+		descriptor := ""
+		if trimmed := strings.TrimRight(ref, ","); trimmed != ref {
+			ref = trimmed
+		} else {
+			start = i
+			for i < len(srcset) && srcset[i] != ',' {
+				i++
+			}
+			descriptor = strings.TrimSpace(srcset[start:i])
+		}
+		if ref == "" {
+			continue
+		}
+		ref, _ = rewrite(ref)
+		if descriptor != "" {
+			ref += " " + descriptor
+		}
+		candidates = append(candidates, ref)
+	}
+	return strings.Join(candidates, ", ")
+}
+
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// asset 按 assetNames 模板确定资源文件的输出路径，默认为 [name]-[hash]；
+// 与 esbuild 一样补充扩展名（模板以 .[ext] 结尾时不重复），输出路径不能超出 outdir
+func (p *htmlPage) asset(file, outdir, pattern string) (api.OutputFile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return api.OutputFile{}, fmt.Errorf("failed to read asset: %v", err)
+	}
+	if pattern == "" {
+		pattern = "[name]-[hash]"
+	}
+	sum := sha256.Sum256(data)
+	hash := base32.StdEncoding.EncodeToString(sum[:])[:8]
+	ext := filepath.Ext(file)
+	dir, _ := filepath.Rel(filepath.Dir(p.source), filepath.Dir(file))
+	name := strings.NewReplacer(
+		"[name]", strings.TrimSuffix(filepath.Base(file), ext),
+		"[hash]", hash,
+		"[dir]", filepath.ToSlash(dir),
+		"[ext]", strings.TrimPrefix(ext, "."),
+	).Replace(pattern)
+	if name = path.Clean(name); !strings.HasSuffix(name, ext) {
+		name += ext
+	}
+	dest := filepath.Join(outdir, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(outdir, dest); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return api.OutputFile{}, fmt.Errorf("asset %s would be written outside outdir: %s", file, dest)
+	}
+	return outputFile(dest, data), nil
+}
+
+// outputFile 插件生成的输出文件，哈希与 esbuild 输出一样用于 ETag
+func outputFile(name string, data []byte) api.OutputFile {
+	sum := sha256.Sum256(data)
+	return api.OutputFile{Path: name, Contents: data, Hash: base32.StdEncoding.EncodeToString(sum[:])[:16]}
+}
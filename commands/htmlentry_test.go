@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestHTMLEntryPoints(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/index.html": `<html><head><link rel="stylesheet" href="./style.css"><link rel="icon" href="/logo.png"></head>` +
+			`<body><img src="logo.png" alt="a logo"><img srcset="logo.png 1x, img/big.png 2x" src="img/big.png"><script type="module" src="./main.js"></script>` +
+			`<script src="https://cdn.example.com/x.js"></script></body></html>`,
+		"src/style.css":   `body{color:red}`,
+		"src/main.js":     `import "./app.css"; import {shared} from "./shared.js"; console.log(shared); import("./lazy.js");`,
+		"src/lazy.js":     `import {shared} from "./shared.js"; console.log("lazy", shared);`,
+		"src/shared.js":   `export const shared = 1;`,
+		"src/app.css":     `.app{color:blue}`,
+		"src/logo.png":    "PNG",
+		"src/img/big.png": "BIG",
+	}
+	writeTree(t, dir, files)
+	outdir := filepath.Join(dir, "dist")
+	entries, pages, err := htmlEntryPoints([]string{filepath.Join(dir, "src", "index.html")}, outdir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 || len(entries) != 2 {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	result := api.Build(api.BuildOptions{
+		EntryPoints:   entries,
+		Bundle:        true,
+		Splitting:     true,
+		Format:        api.FormatESModule,
+		Metafile:      true,
+		Write:         true,
+		AbsWorkingDir: dir,
+		Outdir:        outdir,
+		EntryNames:    "[name]-[hash]",
+		Plugins:       []api.Plugin{htmlEntryPlugin(pages)},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build failed: %v", result.Errors[0].Text)
+	}
+	if len(result.Warnings) > 0 {
+		t.Fatalf("unexpected warning: %v", result.Warnings[0].Text)
+	}
+	data, err := os.ReadFile(filepath.Join(outdir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	for _, pattern := range []string{
+		`<script type="module" src="main-[A-Z0-9]+\.js">`,
+		`<link rel="stylesheet" href="style-[A-Z0-9]+\.css">`,
+		`<link rel="stylesheet" href="main-[A-Z0-9]+\.css">`,
+		`<link rel="modulepreload" href="chunk-[A-Z0-9]+\.js">`,
+		`<img src="logo-[A-Z0-9]+\.png" alt="a logo">`,
+		`<img srcset="logo-[A-Z0-9]+\.png 1x, big-[A-Z0-9]+\.png 2x" src="big-[A-Z0-9]+\.png">`,
+		`<script src="https://cdn.example.com/x.js">`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(html) {
+			t.Errorf("html does not match %s:\n%s", pattern, html)
+		}
+	}
+	if strings.Contains(html, "lazy") {
+		t.Errorf("dynamic chunks should not be preloaded:\n%s", html)
+	}
+}
+
+func TestHTMLAsset(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"src/img/logo.png": "PNG"})
+	page := &htmlPage{source: filepath.Join(dir, "src", "index.html")}
+	outdir := filepath.Join(dir, "dist")
+	file := filepath.Join(dir, "src", "img", "logo.png")
+	for pattern, want := range map[string]string{
+		"":                    `logo-[A-Z0-9]{8}\.png`,
+		"[dir]/[name].[ext]":  `img/logo\.png`,
+		"assets/[ext]/[name]": `assets/png/logo\.png`,
+	} {
+		asset, err := page.asset(file, outdir, pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(outdir, asset.Path)
+		if !regexp.MustCompile("^" + want + "$").MatchString(filepath.ToSlash(rel)) {
+			t.Errorf("%q: unexpected output %s", pattern, rel)
+		}
+	}
+	// 资源位于页面目录之外时 [dir] 以 .. 开头
+	outside := &htmlPage{source: filepath.Join(dir, "src", "img", "sub", "index.html")}
+	if _, err := outside.asset(file, outdir, "../[dir]/[name]"); err == nil {
+		t.Error("expected an error for an asset outside outdir")
+	}
+
+	got := rewriteSrcset(" a.png 1x,b.png  480w , https://cdn.test/c.png 2x,data:image/png;base64,AA== 3x", func(ref string) (string, bool) {
+		if !isLocalReference(ref) {
+			return ref, false
+		}
+		return "out/" + ref, true
+	})
+	if want := "out/a.png 1x, out/b.png 480w, https://cdn.test/c.png 2x, data:image/png;base64,AA== 3x"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
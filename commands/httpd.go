@@ -3,12 +3,16 @@ package commands
 import (
 	"context"
 	"fmt"
-	"github.com/fsnotify/fsnotify"
 	. "github.com/urfave/cli/v3"
+	"io"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,6 +22,9 @@ func httpd() *Command {
 	return &Command{
 		Name:  "httpd",
 		Usage: "http local server",
+		Commands: []*Command{
+			httpdCA(),
+		},
 		Flags: []Flag{
 			&IntFlag{
 				Name:        "port",
@@ -34,10 +41,19 @@ func httpd() *Command {
 			&StringSliceFlag{
 				Name:        "watch",
 				Aliases:     []string{"w"},
-				Usage:       "file extensions to watch for hot reload (e.g. .html,.js,.css)",
+				Usage:       "file extensions to watch for hot reload (e.g. .html,.js,.css,.png), stylesheets and images are updated in place, other files reload the page",
 				DefaultText: ".html",
 				Value:       []string{".html"},
 			},
+			&StringSliceFlag{
+				Name:  "ignore",
+				Usage: "gitignore-style patterns excluded from watching, in addition to .gitignore files in the directory, .git and node_modules",
+			},
+			&DurationFlag{
+				Name:  "debounce",
+				Usage: "time to wait after the last change before notifying pages, merging bursts of changes",
+				Value: defaultWatchDelay,
+			},
 			&StringSliceFlag{
 				Name:        "inject",
 				Aliases:     []string{"j"},
@@ -45,12 +61,64 @@ func httpd() *Command {
 				DefaultText: ".html",
 				Value:       []string{".html"},
 			},
+			&StringFlag{
+				Name:  "vendor",
+				Usage: "folder of extracted npm packages to generate an import map for",
+			},
+			&StringFlag{
+				Name:  "import-map",
+				Usage: "import map file (json or html) to inject into html pages",
+			},
+			&StringFlag{
+				Name:  "build",
+				Usage: "build config file (see units build --config) to build in watch mode and serve from memory without writing to disk",
+			},
+			&StringFlag{
+				Name:        "build-prefix",
+				Usage:       "url prefix of the in-memory build outputs, the static directory serves everything else",
+				DefaultText: "/",
+				Value:       "/",
+			},
+			&StringSliceFlag{
+				Name:  "spa",
+				Usage: "document served with status 200 for unknown paths of html navigations (client-side routes), as page or /prefix/=page, page is relative to the prefix unless it starts with /",
+			},
+			&StringSliceFlag{
+				Name:  "404",
+				Usage: "page served with status 404 for unknown paths, as page or /prefix/=page, the longest matching prefix wins",
+			},
+			&StringSliceFlag{
+				Name:  "proxy",
+				Usage: "forward a path prefix (including websockets) to a backend as /prefix=http://host:port[/path], a target path replaces the prefix",
+			},
+			&StringFlag{
+				Name:  "proxy-config",
+				Usage: "json file with an array of proxy rules {prefix, target, rewrite, host, timeout, cookieDomain}",
+			},
+			&StringFlag{
+				Name:  "proxy-host",
+				Usage: "Host header of --proxy requests: empty keeps the request host, target uses the backend address, other values are sent as is",
+			},
+			&BoolFlag{
+				Name:  "tls",
+				Usage: "serve https with HTTP/2 using a certificate issued by a local CA created once in the user config dir (see httpd ca export)",
+			},
+			&StringSliceFlag{
+				Name:  "host",
+				Usage: "additional host names or IPs covered by the --tls certificate, localhost, the hostname and LAN IPs are always included",
+			},
+			&DurationFlag{
+				Name:  "proxy-timeout",
+				Usage: "time to wait for the response headers of --proxy backends",
+				Value: defaultProxyTimeout,
+			},
 		},
 		Arguments: []Argument{
 			&StringArgs{
 				Name:      "dir",
 				UsageText: "file folder",
 				Min:       0,
+				Max:       1,
 			},
 		},
 		Action: func(ctx context.Context, cmd *Command) error {
@@ -75,34 +143,121 @@ func httpd() *Command {
 			}
 			// 创建文件系统处理器
 			fs := http.FileServer(http.Dir(absDir))
+			// 监听站点目录，按扩展名过滤需要热重载的文件
+			watcher, err := newTreeWatcher(absDir, cmd.StringSlice("ignore"), cmd.Duration("debounce"), func(name string) bool {
+				for _, ext := range watchExts {
+					if strings.HasSuffix(name, ext) {
+						return true
+					}
+				}
+				return false
+			})
+			if err != nil {
+				return err
+			}
 			// 创建带热重载的处理器
 			handler := &hotReloadHandler{
 				fs:         fs,
 				dir:        absDir,
-				watcher:    setupWatcher(absDir, watchExts),
+				watcher:    watcher,
 				clients:    make(map[chan []byte]bool),
 				mu:         sync.Mutex{},
 				shutdown:   make(chan struct{}),
 				watchExts:  watchExts,
 				injectExts: injectExts,
 			}
+			// 反向代理，优先于静态文件
+			rules, err := parseProxyFlags(cmd.StringSlice("proxy"), cmd.String("proxy-host"), cmd.Duration("proxy-timeout"))
+			if err != nil {
+				return err
+			}
+			if file := cmd.String("proxy-config"); file != "" {
+				more, err := loadProxyRules(file)
+				if err != nil {
+					return err
+				}
+				rules = append(rules, more...)
+			}
+			if handler.proxies, err = newProxyRoutes(rules); err != nil {
+				return err
+			}
+			for _, p := range handler.proxies {
+				log.Printf("Proxy %s -> %s", p.prefix, p.target)
+			}
+			// 找不到文件时的回退页面
+			if handler.spa, err = parseFallbackRules(cmd.StringSlice("spa")); err != nil {
+				return err
+			}
+			if handler.notFound, err = parseFallbackRules(cmd.StringSlice("404")); err != nil {
+				return err
+			}
+			// 加载需要注入的 import map
+			if file := cmd.String("import-map"); file != "" {
+				if handler.importMap, err = readImportMap(file); err != nil {
+					return err
+				}
+			}
+			if vendor := cmd.String("vendor"); vendor != "" {
+				if err = handler.mountVendor(vendor); err != nil {
+					return err
+				}
+			}
+
+			// 在内存中构建，输出不写入磁盘，也不会触发文件监听；配置与 html 页面变化后重新读取配置
+			if file := cmd.String("build"); file != "" {
+				stop, err := handler.serveBuildConfig(file, cmd.String("build-prefix"))
+				if err != nil {
+					return err
+				}
+				defer stop()
+			}
 
 			// 启动文件监听
 			go handler.watchFiles()
 
-			// 设置HTTP服务器
-			addr := fmt.Sprintf("%s:%d", ip, port)
+			// 设置HTTP服务器，* 监听所有地址
+			listen := ip
+			if listen == "*" {
+				listen = ""
+			}
+			addr := net.JoinHostPort(listen, strconv.Itoa(int(port)))
 			server := &http.Server{
 				Addr:    addr,
 				Handler: handler,
 			}
-
-			log.Printf("Serving %s on http://%s\n", absDir, addr)
+			scheme := "http"
+			if cmd.Bool("tls") {
+				dir, err := localCADir()
+				if err != nil {
+					return err
+				}
+				ca, err := loadLocalCA(dir)
+				if err != nil {
+					return err
+				}
+				hosts := localHosts(cmd.StringSlice("host"))
+				if server.TLSConfig, err = ca.tlsConfig(hosts); err != nil {
+					return err
+				}
+				scheme = "https"
+				log.Printf("TLS certificate for %s issued by %s", strings.Join(hosts, ", "), ca.file)
+			}
+			display := listen
+			if display == "" {
+				display = "localhost"
+			}
+			log.Printf("Serving %s on %s://%s\n", absDir, scheme, net.JoinHostPort(display, strconv.Itoa(int(port))))
 			log.Println("Press Ctrl+C to stop")
 
 			// 启动服务器
 			go func() {
-				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				var err error
+				if server.TLSConfig != nil {
+					err = server.ListenAndServeTLS("", "")
+				} else {
+					err = server.ListenAndServe()
+				}
+				if err != nil && err != http.ErrServerClosed {
 					log.Fatalf("Server error: %v", err)
 				}
 			}()
@@ -123,66 +278,135 @@ func httpd() *Command {
 type hotReloadHandler struct {
 	fs         http.Handler
 	dir        string
-	watcher    *fsnotify.Watcher
+	watcher    *treeWatcher
 	clients    map[chan []byte]bool
 	mu         sync.Mutex
 	shutdown   chan struct{}
 	watchExts  []string // 需要监听的文件扩展名
 	injectExts []string // 需要注入热重载脚本的文件扩展名
+
+	importMap    *ImportMap   // 注入到 html 的 import map
+	vendorPrefix string       // vendor 目录不在站点目录内时的挂载前缀
+	vendorFs     http.Handler // vendor 目录的文件处理器
+
+	memory *memoryFS // 内存中的构建输出，优先于静态目录
+
+	spa      []fallbackRule // 导航请求找不到文件时返回的文档
+	notFound []fallbackRule // 找不到文件时返回的 404 页面
+
+	proxies []*proxyRoute // 反向代理，前缀更长的优先
+}
+
+// mountVendor 为 vendor 目录生成 import map，目录不在站点内时挂载到 /_vendor/
+func (h *hotReloadHandler) mountVendor(vendor string) error {
+	absVendor, err := filepath.Abs(vendor)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	prefix := "/_vendor/"
+	if rel, err := filepath.Rel(h.dir, absVendor); err == nil && rel == "." {
+		// 站点目录本身即为 vendor 目录
+		prefix = "/"
+	} else if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		prefix = "/" + filepath.ToSlash(rel) + "/"
+	} else {
+		h.vendorPrefix = prefix
+		h.vendorFs = http.StripPrefix(strings.TrimSuffix(prefix, "/"), http.FileServer(http.Dir(absVendor)))
+	}
+	m, err := vendorImportMap(absVendor, prefix)
+	if err != nil {
+		return err
+	}
+	if h.importMap == nil {
+		h.importMap = m
+	} else {
+		h.importMap.merge(m)
+	}
+	log.Printf("Import map generated for vendor packages under %s", prefix)
+	return nil
 }
 
 // ServeHTTP 处理HTTP请求
 func (h *hotReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// 检查是否需要注入热重载脚本
+	// 处理热重载事件源请求
+	if r.URL.Path == "/_hotreload" {
+		h.handleHotReload(w, r)
+		return
+	}
+
+	// 反向代理
+	for _, p := range h.proxies {
+		if p.match(r.URL.Path) {
+			p.proxy.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	// vendor 目录
+	if h.vendorFs != nil && strings.HasPrefix(r.URL.Path, h.vendorPrefix) {
+		h.vendorFs.ServeHTTP(w, r)
+		return
+	}
+
+	if len(h.spa) == 0 && len(h.notFound) == 0 {
+		h.serveDocument(w, r)
+		return
+	}
+	nf := &notFoundWriter{ResponseWriter: w}
+	h.serveDocument(nf, r)
+	if nf.notFound {
+		h.serveFallback(w, r)
+	}
+}
+
+// serveDocument 返回文件，html 页面注入 import map 与热重载脚本
+func (h *hotReloadHandler) serveDocument(w http.ResponseWriter, r *http.Request) {
+	// 检查是否需要注入热重载脚本，目录请求按其中的 index.html 处理
+	name := r.URL.Path
+	if strings.HasSuffix(name, "/") {
+		name += "index.html"
+	}
 	for _, ext := range h.injectExts {
-		if strings.HasSuffix(r.URL.Path, ext) {
-			// 先让文件服务器处理请求
-			rec := &responseRecorder{ResponseWriter: w}
-			h.fs.ServeHTTP(rec, r)
-
-			// 检查是否成功返回了内容
-			if rec.status == http.StatusOK {
-				// 根据内容类型决定是否注入脚本
-				contentType := rec.Header().Get("Content-Type")
-				if strings.Contains(contentType, "text/html") ||
-					strings.Contains(contentType, "application/xhtml+xml") {
-					// 注入热重载脚本
-					body := rec.buf.String()
-					reloadScript := `
-						<script>
-							(function() {
-								const evtSource = new EventSource("/_hotreload");
-								evtSource.onmessage = function(e) {
-									if (e.data === "reload") {
-										console.log("Reloading page...");
-										location.reload();
-									}
-								};
-								evtSource.onerror = function() {
-									console.log("EventSource error. Closing connection.");
-									evtSource.close();
-								};
-							})();
-						</script>
-					`
-					// 在</body>标签前插入脚本，如果没有</body>则追加到末尾
-					if strings.Contains(body, "</body>") {
-						body = strings.Replace(body, "</body>", reloadScript+"</body>", 1)
-					} else {
-						body += reloadScript
-					}
-					w.Header().Set("Content-Length", fmt.Sprint(len(body)))
-					w.WriteHeader(rec.status)
-					_, _ = w.Write([]byte(body))
-					return
+		if strings.HasSuffix(name, ext) {
+			// 先缓存文件的响应，需要修改内容，不使用预压缩文件
+			rec := newResponseRecorder()
+			plain := r.Clone(r.Context())
+			plain.Header.Del("Accept-Encoding")
+			h.serveFile(rec, plain)
+			body := rec.buf.String()
+
+			// 根据内容类型决定是否注入脚本，压缩的内容不能直接修改
+			contentType := rec.Header().Get("Content-Type")
+			if rec.status == http.StatusOK && rec.Header().Get("Content-Encoding") == "" && (strings.Contains(contentType, "text/html") ||
+				strings.Contains(contentType, "application/xhtml+xml")) {
+				// 注入 import map 和热重载脚本
+				body = injectImportMap(body, h.importMap)
+				// 在</body>标签前插入脚本，如果没有</body>则追加到末尾
+				if strings.Contains(body, "</body>") {
+					body = strings.Replace(body, "</body>", hotReloadScript+"</body>", 1)
+				} else {
+					body += hotReloadScript
 				}
+				// 内容已改变，原有的校验头不再适用
+				rec.Header().Del("ETag")
+				rec.Header().Del("Last-Modified")
 			}
+			rec.flush(w, body)
+			return
 		}
 	}
 
-	// 处理热重载事件源请求
-	if r.URL.Path == "/_hotreload" {
-		h.handleHotReload(w, r)
+	h.serveFile(w, r)
+}
+
+// serveFile 依次从内存中的构建输出、预压缩文件与静态目录返回文件
+func (h *hotReloadHandler) serveFile(w http.ResponseWriter, r *http.Request) {
+	if h.memory != nil && h.memory.serve(w, r) {
+		return
+	}
+
+	// 客户端支持时返回预压缩文件
+	if h.servePrecompressed(w, r) {
 		return
 	}
 
@@ -190,6 +414,74 @@ func (h *hotReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.fs.ServeHTTP(w, r)
 }
 
+// precompressed 预压缩文件扩展名及对应的 Content-Encoding，按优先级排列
+var precompressed = []struct{ ext, encoding string }{{".br", "br"}, {".gz", "gzip"}}
+
+// servePrecompressed 存在未过期的 .br 或 .gz 文件且客户端接受该编码时直接返回
+func (h *hotReloadHandler) servePrecompressed(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	if len(accepted) == 0 {
+		return false
+	}
+	name := filepath.Join(h.dir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	info, err := os.Stat(name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	for _, p := range precompressed {
+		if !accepted[p.encoding] {
+			continue
+		}
+		f, err := os.Open(name + p.ext)
+		if err != nil {
+			continue
+		}
+		stat, err := f.Stat()
+		if err != nil || stat.ModTime().Before(info.ModTime()) {
+			// 预压缩文件比原文件旧时忽略
+			f.Close()
+			continue
+		}
+		defer f.Close()
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", p.encoding)
+		w.Header().Add("Vary", "Accept-Encoding")
+		http.ServeContent(w, r, name, stat.ModTime(), f)
+		return true
+	}
+	return false
+}
+
+// acceptedEncodings 解析 Accept-Encoding，忽略 q=0 的编码
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		encoding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if encoding == "" {
+			continue
+		}
+		rejected := false
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if q, ok := strings.CutPrefix(param, "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					rejected = true
+				}
+			}
+		}
+		accepted[encoding] = !rejected
+	}
+	return accepted
+}
+
 // handleHotReload 处理SSE连接
 func (h *hotReloadHandler) handleHotReload(w http.ResponseWriter, r *http.Request) {
 	// 设置SSE头
@@ -197,8 +489,8 @@ func (h *hotReloadHandler) handleHotReload(w http.ResponseWriter, r *http.Reques
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	// 创建消息通道
-	messageChan := make(chan []byte)
+	// 创建消息通道，缓冲一次构建产生的多个事件
+	messageChan := make(chan []byte, 16)
 
 	// 注册客户端
 	h.mu.Lock()
@@ -213,8 +505,9 @@ func (h *hotReloadHandler) handleHotReload(w http.ResponseWriter, r *http.Reques
 		close(messageChan)
 	}()
 
-	// 保持连接打开
+	// 保持连接打开，先发送响应头使客户端建立连接
 	flusher, _ := w.(http.Flusher)
+	flusher.Flush()
 	for {
 		select {
 		case msg := <-messageChan:
@@ -231,92 +524,53 @@ func (h *hotReloadHandler) handleHotReload(w http.ResponseWriter, r *http.Reques
 	}
 }
 
-// watchFiles 监听文件变化
+// watchFiles 监听文件变化，合并后的变化按 URL 路径通知客户端
 func (h *hotReloadHandler) watchFiles() {
 	for {
 		select {
-		case event, ok := <-h.watcher.Events:
+		case files, ok := <-h.watcher.changes:
 			if !ok {
 				return
 			}
-			// 检查是否是监听的文件类型
-			for _, ext := range h.watchExts {
-				if event.Has(fsnotify.Write) && strings.HasSuffix(event.Name, ext) {
-					log.Printf("File changed: %s", event.Name)
-					h.notifyClients("reload")
-					break
-				}
-			}
-		case err, ok := <-h.watcher.Errors:
-			if !ok {
-				return
+			paths := make([]string, 0, len(files))
+			for _, name := range files {
+				log.Printf("File changed: %s", name)
+				paths = append(paths, h.urlPathOf(name))
 			}
-			log.Printf("Watcher error: %v", err)
+			h.notifyChanges(paths...)
 		case <-h.shutdown:
 			return
 		}
 	}
 }
 
-// notifyClients 通知所有客户端重新加载
-func (h *hotReloadHandler) notifyClients(msg string) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	for client := range h.clients {
-		select {
-		case client <- []byte(msg):
-		default:
-			// 如果客户端无法接收消息，跳过
-		}
-	}
-}
-
 // close 关闭资源
 func (h *hotReloadHandler) close() {
 	close(h.shutdown)
 	if h.watcher != nil {
-		h.watcher.Close()
+		h.watcher.close()
 	}
 }
 
-// setupWatcher 设置文件监听器
-func setupWatcher(dir string, watchExts []string) *fsnotify.Watcher {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		log.Fatalf("Failed to create watcher: %v", err)
-	}
-	// 递归添加目录监听
-	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			// 忽略隐藏目录（以.开头）
-			if filepath.Base(path)[0] == '.' {
-				return filepath.SkipDir
-			}
-			return watcher.Add(path)
-		}
-		return nil
-	})
-
-	if err != nil {
-		log.Fatalf("Failed to watch directory: %v", err)
-	}
-
-	return watcher
-}
-
-// responseRecorder 用于捕获文件服务器的响应
+// responseRecorder 用于捕获文件服务器的响应，状态与响应头在 flush 时才写出
 type responseRecorder struct {
-	http.ResponseWriter
+	header http.Header
 	buf    strings.Builder
 	status int
 }
 
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header)}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
 func (r *responseRecorder) WriteHeader(status int) {
-	r.status = status
-	r.ResponseWriter.WriteHeader(status)
+	if r.status == 0 {
+		r.status = status
+	}
 }
 
 func (r *responseRecorder) Write(b []byte) (int, error) {
@@ -325,3 +579,18 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 	}
 	return r.buf.Write(b)
 }
+
+// flush 写出缓存的响应，body 为可能被修改过的内容
+func (r *responseRecorder) flush(w http.ResponseWriter, body string) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if r.header.Get("Content-Length") != "" {
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+	}
+	w.WriteHeader(r.status)
+	_, _ = io.WriteString(w, body)
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServePrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.js.gz"), compressGzip([]byte("console.log(1)")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	h := &hotReloadHandler{fs: http.FileServer(http.Dir(dir)), dir: dir}
+	cases := []struct {
+		accept, encoding string
+	}{
+		{"gzip, deflate, br", "gzip"},
+		{"br", ""},
+		{"gzip;q=0", ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/app.js", nil)
+		req.Header.Set("Accept-Encoding", c.accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Errorf("Accept-Encoding %q: got encoding %q, want %q", c.accept, got, c.encoding)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+			t.Errorf("unexpected content type %q", ct)
+		}
+	}
+}
+
+func TestServeDocumentPrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	page := "<html><body>hello</body></html>"
+	writeTree(t, dir, map[string]string{
+		"page.html":    page,
+		"page.html.br": "BROTLI",
+	})
+	h := &hotReloadHandler{fs: http.FileServer(http.Dir(dir)), dir: dir, injectExts: []string{".html"}}
+	req := httptest.NewRequest("GET", "/page.html", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("injected page served with encoding %q", got)
+	}
+	if body := rec.Body.String(); !strings.HasPrefix(body, "<html><body>hello") || !strings.Contains(body, "/_hotreload") {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+}
+
+func TestMountVendor(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"dep/package.json":        `{"name":"dep","module":"index.js"}`,
+		"dep/index.js":            "export default 1",
+		"vendor/lib/package.json": `{"name":"lib","main":"lib.js"}`,
+		"vendor/lib/lib.js":       "export default 2",
+	})
+	for vendor, want := range map[string]string{
+		dir:                          "/dep/index.js",
+		filepath.Join(dir, "vendor"): "/vendor/lib/lib.js",
+	} {
+		h := &hotReloadHandler{dir: dir}
+		if err := h.mountVendor(vendor); err != nil {
+			t.Fatal(err)
+		}
+		name := strings.Split(strings.TrimPrefix(want, "/vendor"), "/")[1]
+		if got := h.importMap.Imports[name]; got != want {
+			t.Errorf("%s: got %q, want %q", vendor, got, want)
+		}
+		if h.vendorFs != nil {
+			t.Errorf("%s: vendor inside the site should be served by the site", vendor)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package commands
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultIgnorePatterns 总是忽略的目录
+var defaultIgnorePatterns = []string{".git/", "node_modules/"}
+
+// ignoreRule .gitignore 形式的规则
+type ignoreRule struct {
+	base    string // 规则所在目录，相对根目录，以 / 分隔，根目录为空
+	pattern string // 相对 base 的模式，** 匹配任意层目录
+	negate  bool   // ! 开头，重新包含之前排除的路径
+	dirOnly bool   // / 结尾，只匹配目录
+	file    bool   // 来自 .gitignore 文件
+}
+
+// ignoreMatcher 按 .gitignore 的规则判断路径是否忽略，后出现与更深目录中的规则优先
+type ignoreMatcher struct {
+	root  string
+	rules []ignoreRule
+}
+
+func newIgnoreMatcher(root string, patterns []string) *ignoreMatcher {
+	m := &ignoreMatcher{root: root}
+	m.rules = append(parseIgnorePatterns("", defaultIgnorePatterns), parseIgnorePatterns("", patterns)...)
+	return m
+}
+
+// parseIgnorePatterns 解析 .gitignore 的行，跳过空行与注释
+func parseIgnorePatterns(base string, lines []string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// 不含 / 的模式匹配任意层级中的名称，否则相对规则所在目录
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// load 读取目录中的 .gitignore，替换该目录原有的规则
+func (m *ignoreMatcher) load(dir string) {
+	base := m.rel(dir)
+	if base == "." {
+		base = ""
+	}
+	kept := m.rules[:0]
+	for _, r := range m.rules {
+		if !r.file || r.base != base {
+			kept = append(kept, r)
+		}
+	}
+	m.rules = kept
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	for _, r := range parseIgnorePatterns(base, lines) {
+		r.file = true
+		m.rules = append(m.rules, r)
+	}
+	// 更深目录中的规则在后，保证优先
+	sort.SliceStable(m.rules, func(i, j int) bool { return ignoreDepth(m.rules[i].base) < ignoreDepth(m.rules[j].base) })
+}
+
+func ignoreDepth(base string) int {
+	if base == "" {
+		return 0
+	}
+	return strings.Count(base, "/") + 1
+}
+
+func (m *ignoreMatcher) rel(name string) string {
+	rel, err := filepath.Rel(m.root, name)
+	if err != nil {
+		return name
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ignored 判断路径是否忽略，根目录从不忽略
+func (m *ignoreMatcher) ignored(name string, dir bool) bool {
+	rel := m.rel(name)
+	if rel == "." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !dir {
+			continue
+		}
+		sub := rel
+		if r.base != "" {
+			var ok bool
+			if sub, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+				continue
+			}
+		}
+		if matchGlob(r.pattern, sub) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
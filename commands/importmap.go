@@ -0,0 +1,388 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// ImportMap 浏览器 import map 结构，参见 https://html.spec.whatwg.org/multipage/webappapis.html#import-maps
+type ImportMap struct {
+	Imports map[string]string            `json:"imports,omitempty"`
+	Scopes  map[string]map[string]string `json:"scopes,omitempty"`
+}
+
+// loadImportMap 从内联 JSON 对象或文件路径加载 import map
+func loadImportMap(raw json.RawMessage) (*ImportMap, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var file string
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("invalid import map: %v", err)
+		}
+		return readImportMap(file)
+	}
+	var m ImportMap
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("invalid import map: %v", err)
+	}
+	return &m, nil
+}
+
+// importMapFile 配置中 import map 文件的路径，内联对象或未配置时为空
+func importMapFile(raw json.RawMessage) string {
+	var file string
+	if raw = bytes.TrimSpace(raw); len(raw) == 0 || raw[0] != '"' || json.Unmarshal(raw, &file) != nil {
+		return ""
+	}
+	return file
+}
+
+// readImportMap 读取 import map 文件，支持 .json 或包含 <script type="importmap"> 的 html
+func readImportMap(file string) (*ImportMap, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import map: %v", err)
+	}
+	if loc := importMapScriptPattern.FindSubmatchIndex(data); loc != nil {
+		data = data[loc[2]:loc[3]]
+	}
+	var m ImportMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse import map %s: %v", file, err)
+	}
+	return &m, nil
+}
+
+// merge 合并另一个 import map，已存在的映射优先
+func (m *ImportMap) merge(o *ImportMap) {
+	if o == nil {
+		return
+	}
+	if m.Imports == nil && len(o.Imports) > 0 {
+		m.Imports = make(map[string]string)
+	}
+	for k, v := range o.Imports {
+		if _, ok := m.Imports[k]; !ok {
+			m.Imports[k] = v
+		}
+	}
+	if m.Scopes == nil && len(o.Scopes) > 0 {
+		m.Scopes = make(map[string]map[string]string)
+	}
+	for scope, imports := range o.Scopes {
+		if m.Scopes[scope] == nil {
+			m.Scopes[scope] = make(map[string]string)
+		}
+		for k, v := range imports {
+			if _, ok := m.Scopes[scope][k]; !ok {
+				m.Scopes[scope][k] = v
+			}
+		}
+	}
+}
+
+// resolve 按规范解析说明符：先匹配最长的作用域，再匹配顶层 imports
+func (m *ImportMap) resolve(spec, scope string) (string, bool) {
+	if scope != "" && len(m.Scopes) > 0 {
+		scopes := make([]string, 0, len(m.Scopes))
+		for key := range m.Scopes {
+			scopes = append(scopes, key)
+		}
+		sort.Slice(scopes, func(i, j int) bool { return len(scopes[i]) > len(scopes[j]) })
+		for _, key := range scopes {
+			prefix := normalizeImportMapKey(key)
+			if scope == prefix || strings.HasSuffix(prefix, "/") && strings.HasPrefix(scope, prefix) {
+				if target, ok := matchSpecifierMap(m.Scopes[key], spec); ok {
+					return target, true
+				}
+			}
+		}
+	}
+	return matchSpecifierMap(m.Imports, spec)
+}
+
+// matchSpecifierMap 精确匹配，或按以 / 结尾的最长前缀匹配
+func matchSpecifierMap(specMap map[string]string, spec string) (string, bool) {
+	if target, ok := specMap[spec]; ok {
+		return target, true
+	}
+	best := ""
+	for key := range specMap {
+		if strings.HasSuffix(key, "/") && strings.HasPrefix(spec, normalizeImportMapKey(key)) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	target := specMap[best]
+	if !strings.HasSuffix(target, "/") {
+		// 前缀映射的目标必须同样以 / 结尾
+		return "", false
+	}
+	return target + spec[len(normalizeImportMapKey(best)):], true
+}
+
+// normalizeImportMapKey 将 ./ 开头的相对键转换为以 / 开头的站点路径
+func normalizeImportMapKey(key string) string {
+	if strings.HasPrefix(key, "./") || strings.HasPrefix(key, "../") {
+		clean := path.Clean("/" + key)
+		if strings.HasSuffix(key, "/") && clean != "/" {
+			clean += "/"
+		}
+		return clean
+	}
+	return key
+}
+
+func isURLSpecifier(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "//")
+}
+
+const httpNamespace = "http-url"
+
+// importMapResolving 标记由插件自身发起的解析，避免递归
+type importMapResolving struct{}
+
+// importMapPlugin 创建按 import map 解析模块的 esbuild 插件，base 为站点根目录
+// external 为 true 时映射到 URL 的模块保持外部引用，否则下载后打包
+func importMapPlugin(m *ImportMap, base string, external bool) api.Plugin {
+	var (
+		mu    sync.Mutex
+		cache = make(map[string]string)
+	)
+	return api.Plugin{
+		Name: "import-map",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: `.*`}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				if args.Kind == api.ResolveEntryPoint || args.PluginData == (importMapResolving{}) || strings.HasPrefix(args.Path, "data:") {
+					return api.OnResolveResult{}, nil
+				}
+				spec, scope := args.Path, ""
+				relative := strings.HasPrefix(spec, "./") || strings.HasPrefix(spec, "../")
+				switch args.Namespace {
+				case "file":
+					if args.Importer != "" {
+						if rel, err := filepath.Rel(base, args.Importer); err == nil && !strings.HasPrefix(rel, "..") {
+							scope = "/" + filepath.ToSlash(rel)
+							if relative {
+								spec = path.Join(path.Dir(scope), spec)
+							}
+						}
+					}
+				case httpNamespace:
+					scope = args.Importer
+					if relative || strings.HasPrefix(spec, "/") {
+						u, err := url.Parse(args.Importer)
+						if err != nil {
+							return api.OnResolveResult{}, err
+						}
+						ref, err := url.Parse(spec)
+						if err != nil {
+							return api.OnResolveResult{}, err
+						}
+						spec = u.ResolveReference(ref).String()
+					}
+				}
+				target, ok := m.resolve(spec, scope)
+				if !ok {
+					if args.Namespace == httpNamespace && isURLSpecifier(spec) {
+						return importMapURLResult(spec, external), nil
+					}
+					return api.OnResolveResult{}, nil
+				}
+				if isURLSpecifier(target) {
+					return importMapURLResult(target, external), nil
+				}
+				local := target
+				if strings.HasPrefix(local, "/") {
+					local = "." + local
+				}
+				result := build.Resolve(local, api.ResolveOptions{
+					ResolveDir: base,
+					Kind:       args.Kind,
+					Importer:   args.Importer,
+					PluginData: importMapResolving{},
+				})
+				if len(result.Errors) > 0 {
+					return api.OnResolveResult{Errors: result.Errors}, nil
+				}
+				resolved := api.OnResolveResult{
+					Path:      result.Path,
+					Namespace: result.Namespace,
+					External:  result.External,
+					Suffix:    result.Suffix,
+				}
+				if !result.SideEffects {
+					resolved.SideEffects = api.SideEffectsFalse
+				}
+				return resolved, nil
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: httpNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				mu.Lock()
+				contents, ok := cache[args.Path]
+				mu.Unlock()
+				if !ok {
+					log.Printf("import map: fetch %s", args.Path)
+					resp, err := client.Get(args.Path)
+					if err != nil {
+						return api.OnLoadResult{}, fmt.Errorf("fetch %s: %w", args.Path, err)
+					}
+					defer resp.Body.Close()
+					if resp.StatusCode != http.StatusOK {
+						return api.OnLoadResult{}, fmt.Errorf("fetch %s: %s", args.Path, resp.Status)
+					}
+					data, err := io.ReadAll(resp.Body)
+					if err != nil {
+						return api.OnLoadResult{}, fmt.Errorf("fetch %s: %w", args.Path, err)
+					}
+					contents = string(data)
+					mu.Lock()
+					cache[args.Path] = contents
+					mu.Unlock()
+				}
+				loader := api.LoaderJS
+				if u, err := url.Parse(args.Path); err == nil {
+					switch path.Ext(u.Path) {
+					case ".css":
+						loader = api.LoaderCSS
+					case ".json":
+						loader = api.LoaderJSON
+					case ".ts", ".mts":
+						loader = api.LoaderTS
+					case ".tsx":
+						loader = api.LoaderTSX
+					case ".jsx":
+						loader = api.LoaderJSX
+					}
+				}
+				return api.OnLoadResult{Contents: &contents, Loader: loader}, nil
+			})
+		},
+	}
+}
+
+func importMapURLResult(target string, external bool) api.OnResolveResult {
+	if strings.HasPrefix(target, "//") {
+		target = "https:" + target
+	}
+	if external {
+		return api.OnResolveResult{Path: target, External: true}
+	}
+	return api.OnResolveResult{Path: target, Namespace: httpNamespace}
+}
+
+// vendorImportMap 为本地 vendor 目录中已解压的 npm 包生成 import map，prefix 为目录对应的 URL 前缀
+func vendorImportMap(dir, prefix string) (*ImportMap, error) {
+	m := &ImportMap{Imports: make(map[string]string)}
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	add := func(pkgDir, name string) {
+		data, err := os.ReadFile(filepath.Join(pkgDir, "package.json"))
+		if err != nil {
+			return
+		}
+		pkg := &npmPackage{name: name}
+		if err := json.Unmarshal(data, &pkg.manifest); err != nil {
+			log.Printf("import map: skip %s: %v", pkgDir, err)
+			return
+		}
+		pkg.version = pkg.manifest.Version
+		base := prefix + name + "/"
+		m.Imports[name+"/"] = base
+		entry, err := pkg.entry(".", []string{"browser", "import", "module", "default"}, []string{"browser", "module", "main"})
+		if err != nil {
+			return
+		}
+		if file, ok := probeFile(pkgDir, entry); ok {
+			m.Imports[name] = base + file
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vendor directory: %v", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(entry.Name(), "@") {
+			scoped, _ := os.ReadDir(filepath.Join(dir, entry.Name()))
+			for _, s := range scoped {
+				if s.IsDir() {
+					add(filepath.Join(dir, entry.Name(), s.Name()), entry.Name()+"/"+s.Name())
+				}
+			}
+			continue
+		}
+		add(filepath.Join(dir, entry.Name()), entry.Name())
+	}
+	return m, nil
+}
+
+// probeFile 在磁盘上按 npmProbeExtensions 补全文件名，返回相对路径
+func probeFile(dir, file string) (string, bool) {
+	file = strings.TrimPrefix(path.Clean(file), "./")
+	candidates := []string{file}
+	for _, ext := range npmProbeExtensions {
+		candidates = append(candidates, file+ext)
+	}
+	for _, ext := range npmProbeExtensions {
+		candidates = append(candidates, path.Join(file, "index"+ext))
+	}
+	for _, c := range candidates {
+		if s, err := os.Stat(filepath.Join(dir, filepath.FromSlash(c))); err == nil && !s.IsDir() {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+var (
+	importMapScriptPattern = regexp.MustCompile(`(?is)<script[^>]*type=["']?importmap["']?[^>]*>(.*?)</script>`)
+	headOpenPattern        = regexp.MustCompile(`(?i)<head[^>]*>`)
+	firstScriptPattern     = regexp.MustCompile(`(?i)<script`)
+)
+
+// injectImportMap 将 import map 注入 html，已有 import map 时合并（页面中的映射优先）
+func injectImportMap(body string, m *ImportMap) string {
+	if m == nil {
+		return body
+	}
+	if loc := importMapScriptPattern.FindStringSubmatchIndex(body); loc != nil {
+		var existing ImportMap
+		if err := json.Unmarshal([]byte(body[loc[2]:loc[3]]), &existing); err != nil {
+			log.Printf("import map: page import map is invalid: %v", err)
+			return body
+		}
+		existing.merge(m)
+		data, _ := json.MarshalIndent(existing, "", "  ")
+		return body[:loc[2]] + "\n" + string(data) + "\n" + body[loc[3]:]
+	}
+	data, _ := json.MarshalIndent(m, "", "  ")
+	script := "<script type=\"importmap\">\n" + string(data) + "\n</script>"
+	// import map 必须出现在所有模块脚本之前
+	if loc := firstScriptPattern.FindStringIndex(body); loc != nil {
+		return body[:loc[0]] + script + "\n" + body[loc[0]:]
+	}
+	if loc := headOpenPattern.FindStringIndex(body); loc != nil {
+		return body[:loc[1]] + script + body[loc[1]:]
+	}
+	return script + body
+}
@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestImportMapResolve(t *testing.T) {
+	m := &ImportMap{
+		Imports: map[string]string{
+			"lit":       "https://cdn.example.com/lit@3/index.js",
+			"lit/":      "https://cdn.example.com/lit@3/",
+			"app/":      "./src/",
+			"broken/":   "./no-slash",
+			"/legacy/a": "/modern/a.js",
+		},
+		Scopes: map[string]map[string]string{
+			"./src/old/": {"lit": "https://cdn.example.com/lit@2/index.js"},
+		},
+	}
+	cases := []struct {
+		spec, scope, want string
+	}{
+		{"lit", "/src/main.js", "https://cdn.example.com/lit@3/index.js"},
+		{"lit", "/src/old/page.js", "https://cdn.example.com/lit@2/index.js"},
+		{"lit/decorators.js", "/src/old/page.js", "https://cdn.example.com/lit@3/decorators.js"},
+		{"app/util.js", "", "./src/util.js"},
+		{"broken/x", "", ""},
+		{"/legacy/a", "", "/modern/a.js"},
+		{"react", "", ""},
+	}
+	for _, c := range cases {
+		if got, _ := m.resolve(c.spec, c.scope); got != c.want {
+			t.Errorf("resolve(%q, %q) = %q, want %q", c.spec, c.scope, got, c.want)
+		}
+	}
+}
+
+func TestInjectImportMap(t *testing.T) {
+	m := &ImportMap{Imports: map[string]string{"vue": "/vendor/vue/index.js"}}
+	out := injectImportMap(`<html><head><script type="module" src="main.js"></script></head></html>`, m)
+	if strings.Index(out, `type="importmap"`) > strings.Index(out, `type="module"`) {
+		t.Errorf("import map must precede module scripts:\n%s", out)
+	}
+	out = injectImportMap(`<head><script type="importmap">{"imports":{"vue":"/cdn/vue.js"}}</script></head>`, m)
+	if strings.Count(out, "importmap") != 1 || !strings.Contains(out, "/cdn/vue.js") {
+		t.Errorf("page import map should win when merged:\n%s", out)
+	}
+}
+
+func TestImportMapPlugin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "vendor", "util"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vendor", "util", "index.js"), []byte(`export const util = "local-util";`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entry := filepath.Join(dir, "main.js")
+	if err := os.WriteFile(entry, []byte(`import {util} from "util"; import {html} from "lit"; console.log(util, html);`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := &ImportMap{Imports: map[string]string{
+		"util": "/vendor/util/index.js",
+		"lit":  "https://cdn.example.com/lit.js",
+	}}
+	result := api.Build(api.BuildOptions{
+		EntryPoints:   []string{entry},
+		Bundle:        true,
+		Format:        api.FormatESModule,
+		AbsWorkingDir: dir,
+		Outfile:       filepath.Join(dir, "out.js"),
+		Plugins:       []api.Plugin{importMapPlugin(m, dir, true)},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build failed: %v", result.Errors[0].Text)
+	}
+	out := string(result.OutputFiles[0].Contents)
+	for _, want := range []string{"local-util", `from "https://cdn.example.com/lit.js"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestImportMapInput(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "importmap.json")
+	config := &EsbuildConfig{AbsWorkingDir: dir, ImportMap: json.RawMessage(`"` + filepath.ToSlash(file) + `"`)}
+	if inputs := configInputs("", config); !slices.Contains(inputs, file) {
+		t.Errorf("watch inputs missing the import map: %v", inputs)
+	}
+	config.ImportMap = json.RawMessage(`{"imports": {}}`)
+	if importMapFile(config.ImportMap) != "" {
+		t.Error("inline import map has no file")
+	}
+}
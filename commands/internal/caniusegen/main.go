@@ -0,0 +1,235 @@
+// caniusegen 从固定版本的 caniuse-lite 生成 browserslist 查询使用的 caniuse.json 快照
+//
+//	go run ./internal/caniusegen -version 1.0.30001718 -integrity sha512-... -o caniuse.json
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// agents 快照中保留的浏览器，即可映射为 esbuild 引擎的浏览器，以及是否为移动端
+var agents = []struct {
+	name   string
+	mobile bool
+}{
+	{"chrome", false},
+	{"edge", false},
+	{"firefox", false},
+	{"safari", false},
+	{"ios_saf", true},
+	{"opera", false},
+	{"ie", false},
+	{"and_chr", true},
+	{"and_ff", true},
+}
+
+func main() {
+	var (
+		version   = flag.String("version", "", "caniuse-lite version")
+		integrity = flag.String("integrity", "", "expected sha512 integrity of the npm tarball")
+		output    = flag.String("o", "caniuse.json", "output file")
+		esr       = flag.String("esr", "", "Firefox ESR versions, comma separated (from browserslist)")
+		node      = flag.String("node", "", "maintained Node.js major versions, comma separated (from node-releases)")
+		dead      = flag.String("dead", "ie", "browsers without official support, comma separated (from browserslist)")
+	)
+	flag.Parse()
+	if *version == "" || *integrity == "" {
+		log.Fatal("-version and -integrity are required")
+	}
+	files, err := download(*version, *integrity)
+	if err != nil {
+		log.Fatal(err)
+	}
+	snapshot, err := unpack(files)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data := encode(*version, snapshot, split(*esr), split(*node), split(*dead))
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Generated %s from caniuse-lite@%s", *output, *version)
+}
+
+func split(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// download 下载 npm 包并校验完整性，返回 data 目录中的文件
+func download(version, integrity string) (map[string][]byte, error) {
+	url := fmt.Sprintf("https://registry.npmjs.org/caniuse-lite/-/caniuse-lite-%s.tgz", version)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	archive, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha512.Sum512(archive)
+	if got := "sha512-" + base64.StdEncoding.EncodeToString(sum[:]); got != integrity {
+		return nil, fmt.Errorf("integrity mismatch for caniuse-lite@%s: got %s", version, got)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch h.Name {
+		case "package/data/agents.js", "package/data/browsers.js", "package/data/browserVersions.js":
+			if files[strings.TrimPrefix(h.Name, "package/data/")], err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// agentVersion 解包后的浏览器版本
+type agentVersion struct {
+	name     string
+	released *int64 // 发布时间（秒），未发布或未知时为空
+	usage    float64
+}
+
+// unpack 按 caniuse-lite 的 unpacker 解开压缩的键名，返回浏览器及按发布顺序排列的版本
+func unpack(files map[string][]byte) (map[string][]agentVersion, error) {
+	load := func(name string) (map[string]any, error) {
+		src, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("missing data/%s in package", name)
+		}
+		vm := goja.New()
+		module := vm.NewObject()
+		if err := module.Set("exports", vm.NewObject()); err != nil {
+			return nil, err
+		}
+		if err := vm.Set("module", module); err != nil {
+			return nil, err
+		}
+		if _, err := vm.RunScript(name, string(src)); err != nil {
+			return nil, fmt.Errorf("failed to evaluate data/%s: %v", name, err)
+		}
+		exports, ok := module.Get("exports").Export().(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected exports in data/%s", name)
+		}
+		return exports, nil
+	}
+	packed, err := load("agents.js")
+	if err != nil {
+		return nil, err
+	}
+	browsers, err := load("browsers.js")
+	if err != nil {
+		return nil, err
+	}
+	versions, err := load("browserVersions.js")
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]agentVersion)
+	for key, value := range packed {
+		name, _ := browsers[key].(string)
+		agent, _ := value.(map[string]any)
+		usage, _ := agent["A"].(map[string]any)
+		released, _ := agent["F"].(map[string]any)
+		list, _ := agent["C"].([]any)
+		var vs []agentVersion
+		for _, k := range list {
+			k, _ := k.(string)
+			if k == "" {
+				// 版本列表中的占位
+				continue
+			}
+			v := agentVersion{name: fmt.Sprint(versions[k]), usage: number(usage[k])}
+			if r, ok := released[k]; ok && r != nil {
+				seconds := int64(number(r))
+				v.released = &seconds
+			}
+			vs = append(vs, v)
+		}
+		out[name] = vs
+	}
+	return out, nil
+}
+
+func number(v any) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// encode 按 commands/browserslist.go 读取的格式输出，每个浏览器一行
+func encode(source string, snapshot map[string][]agentVersion, esr, node, dead []string) []byte {
+	var latest int64
+	lines := make([]string, 0, len(agents))
+	for _, a := range agents {
+		var entries []string
+		for _, v := range snapshot[a.name] {
+			released := ""
+			if v.released != nil {
+				released = time.Unix(*v.released, 0).UTC().Format(time.DateOnly)
+				latest = max(latest, *v.released)
+			} else if v.usage == 0 {
+				// 尚未发布的版本
+				continue
+			}
+			name, _ := json.Marshal(v.name)
+			entries = append(entries, fmt.Sprintf("[%s,%q,%s]", name, released, strconv.FormatFloat(v.usage, 'f', -1, 64)))
+		}
+		typ := "desktop"
+		if a.mobile {
+			typ = "mobile"
+		}
+		lines = append(lines, fmt.Sprintf("%q:{\"type\":%q,\"versions\":[%s]}", a.name, typ, strings.Join(entries, ",")))
+	}
+	list := func(values []string) string {
+		data, _ := json.Marshal(values)
+		return string(data)
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "{\"source\":%q,\"updated\":%q,\"firefoxEsr\":%s,\"maintainedNode\":%s,\"dead\":%s,\"agents\":{\n",
+		"caniuse-lite@"+source, time.Unix(latest, 0).UTC().Format(time.DateOnly), list(esr), list(node), list(dead))
+	sb.WriteString(strings.Join(lines, ",\n"))
+	sb.WriteString("}}\n")
+	return []byte(sb.String())
+}
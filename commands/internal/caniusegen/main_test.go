@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnpack(t *testing.T) {
+	files := map[string][]byte{
+		"browsers.js":        []byte(`module.exports={A:"ie",B:"chrome"};`),
+		"browserVersions.js": []byte(`module.exports={"0":"11","1":"120","2":"121","3":"122"};`),
+		"agents.js": []byte(`module.exports={A:{A:{"0":0.3},B:"ms",C:["","0"],E:"IE",F:{"0":1381968000}},` +
+			`B:{A:{"1":0.5,"2":12.25,"3":0},B:"webkit",C:["1","2","3"],E:"Chrome",F:{"1":1701993600,"2":1705363200,"3":null}}};`),
+	}
+	snapshot, err := unpack(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(encode("1.0.0", snapshot, []string{"128"}, []string{"22"}, []string{"ie"}))
+	want := `{"source":"caniuse-lite@1.0.0","updated":"2024-01-16","firefoxEsr":["128"],"maintainedNode":["22"],"dead":["ie"],"agents":{` + "\n" +
+		`"chrome":{"type":"desktop","versions":[["120","2023-12-08",0.5],["121","2024-01-16",12.25]]},` + "\n"
+	if !strings.HasPrefix(got, want) || !strings.Contains(got, `"ie":{"type":"desktop","versions":[["11","2013-10-17",0.3]]}`) {
+		t.Errorf("unexpected snapshot:\n%s", got)
+	}
+}
@@ -0,0 +1,537 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// FetchStub fetch 的桩响应，键为 URL 或 "METHOD URL"，以 * 结尾时按前缀匹配
+type FetchStub struct {
+	Status  int               `json:"status"` // 默认 200
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+	JSON    json.RawMessage   `json:"json"` // 作为 JSON 返回的内容，优先于 body
+	File    string            `json:"file"` // 从文件读取响应内容，相对桩文件所在目录
+}
+
+// loadFetchStubs 读取 fetch 桩文件
+func loadFetchStubs(file string) (map[string]*FetchStub, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read fetch stub: %v", err)
+	}
+	stubs := make(map[string]*FetchStub)
+	if err := json.Unmarshal(data, &stubs); err != nil {
+		return nil, fmt.Errorf("parse fetch stub %s: %v", file, err)
+	}
+	for key, stub := range stubs {
+		if stub.File != "" && !filepath.IsAbs(stub.File) {
+			stub.File = filepath.Join(filepath.Dir(file), stub.File)
+		}
+		if stub.File != "" && stub.JSON != nil {
+			return nil, fmt.Errorf("fetch stub %q: json and file are exclusive", key)
+		}
+	}
+	return stubs, nil
+}
+
+// jsRuntime 嵌入的 JavaScript 运行时：console、定时器、桩 fetch、受限的 fs，
+// 脚本与所有回调都在事件循环所在的 goroutine 中执行
+type jsRuntime struct {
+	vm      *goja.Runtime
+	jobs    chan func() error
+	done    chan struct{}
+	pending int // 未完成的定时器与 fetch
+	timers  map[int64]*jsTimer
+	timerID int64
+
+	workDir string
+	allow   []string // 允许 fs 访问的目录（绝对路径）
+	stubs   map[string]*FetchStub
+	argv    []string
+	stdout  io.Writer
+	stderr  io.Writer
+
+	rejections map[*goja.Promise]bool
+}
+
+type jsTimer struct {
+	timer    *time.Timer
+	interval time.Duration
+	repeat   bool
+}
+
+// jsExit process.exit 中断执行时携带的退出码
+type jsExit struct {
+	code int
+}
+
+// newJSRuntime 创建运行时，allow 为允许 fs 访问的目录，相对 workDir
+func newJSRuntime(workDir string, allow []string, stubs map[string]*FetchStub, argv []string) (*jsRuntime, error) {
+	r := &jsRuntime{
+		vm:         goja.New(),
+		jobs:       make(chan func() error, 16),
+		done:       make(chan struct{}),
+		timers:     make(map[int64]*jsTimer),
+		workDir:    workDir,
+		stubs:      stubs,
+		argv:       argv,
+		stdout:     os.Stdout,
+		stderr:     os.Stderr,
+		rejections: make(map[*goja.Promise]bool),
+	}
+	for _, dir := range allow {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		r.allow = append(r.allow, abs)
+	}
+	r.vm.SetPromiseRejectionTracker(func(p *goja.Promise, op goja.PromiseRejectionOperation) {
+		if op == goja.PromiseRejectionReject {
+			r.rejections[p] = true
+		} else {
+			delete(r.rejections, p)
+		}
+	})
+	if err := r.setupGlobals(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// run 执行脚本并运行事件循环直到没有待处理的任务，返回 process.exit 的退出码
+func (r *jsRuntime) run(ctx context.Context, name, src string) (int, error) {
+	defer r.stop()
+	stop := context.AfterFunc(ctx, func() {
+		r.vm.Interrupt(ctx.Err())
+	})
+	defer stop()
+	if _, err := r.vm.RunScript(name, src); err != nil {
+		return r.result(err)
+	}
+	if err := r.checkRejections(); err != nil {
+		return r.result(err)
+	}
+	for r.pending > 0 {
+		select {
+		case job := <-r.jobs:
+			if err := job(); err != nil {
+				return r.result(err)
+			}
+			if err := r.checkRejections(); err != nil {
+				return r.result(err)
+			}
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	return int(r.vm.Get("process").ToObject(r.vm).Get("exitCode").ToInteger()), nil
+}
+
+// result 区分 process.exit、取消与脚本异常
+func (r *jsRuntime) result(err error) (int, error) {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		switch v := interrupted.Value().(type) {
+		case *jsExit:
+			return v.code, nil
+		case error:
+			return 0, v
+		}
+	}
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return 1, fmt.Errorf("uncaught %s", exception.String())
+	}
+	return 1, err
+}
+
+// checkRejections 未处理的 Promise 拒绝视为未捕获的异常
+func (r *jsRuntime) checkRejections() error {
+	for p := range r.rejections {
+		delete(r.rejections, p)
+		return fmt.Errorf("uncaught (in promise) %s", r.inspect(p.Result()))
+	}
+	return nil
+}
+
+// stop 停止所有定时器，丢弃之后投递的任务
+func (r *jsRuntime) stop() {
+	close(r.done)
+	for id, t := range r.timers {
+		t.timer.Stop()
+		delete(r.timers, id)
+	}
+}
+
+// post 从其他 goroutine 投递到事件循环
+func (r *jsRuntime) post(job func() error) {
+	select {
+	case r.jobs <- job:
+	case <-r.done:
+	}
+}
+
+func (r *jsRuntime) setupGlobals() error {
+	vm := r.vm
+	console := vm.NewObject()
+	for name, stderr := range map[string]bool{"log": false, "info": false, "debug": false, "warn": true, "error": true} {
+		stderr := stderr
+		_ = console.Set(name, func(call goja.FunctionCall) goja.Value {
+			parts := make([]string, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				parts[i] = r.inspect(arg)
+			}
+			w := r.stdout
+			if stderr {
+				w = r.stderr
+			}
+			fmt.Fprintln(w, strings.Join(parts, " "))
+			return goja.Undefined()
+		})
+	}
+	process := vm.NewObject()
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	_ = process.Set("argv", r.argv)
+	_ = process.Set("env", env)
+	_ = process.Set("platform", "units")
+	_ = process.Set("cwd", func() string { return r.workDir })
+	_ = process.Set("exit", func(call goja.FunctionCall) goja.Value {
+		code := int(call.Argument(0).ToInteger())
+		r.vm.Interrupt(&jsExit{code: code})
+		return goja.Undefined()
+	})
+	_ = process.Set("exitCode", 0)
+	for name, value := range map[string]interface{}{
+		"console":       console,
+		"process":       process,
+		"setTimeout":    r.timerFunc(false),
+		"setInterval":   r.timerFunc(true),
+		"clearTimeout":  r.clearTimer,
+		"clearInterval": r.clearTimer,
+		"fetch":         r.fetch,
+		"require":       r.require,
+	} {
+		if err := vm.Set(name, value); err != nil {
+			return err
+		}
+	}
+	if err := vm.Set("globalThis", vm.GlobalObject()); err != nil {
+		return err
+	}
+	_, err := vm.RunString(`globalThis.queueMicrotask = (fn) => { Promise.resolve().then(fn) }`)
+	return err
+}
+
+// inspect 将值格式化为 console 输出
+func (r *jsRuntime) inspect(v goja.Value) string {
+	if v == nil || goja.IsUndefined(v) {
+		return "undefined"
+	}
+	if goja.IsNull(v) {
+		return "null"
+	}
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return v.String()
+	}
+	if stack := obj.Get("stack"); stack != nil && !goja.IsUndefined(stack) && obj.Get("message") != nil {
+		return stack.String()
+	}
+	if _, ok := goja.AssertFunction(v); ok {
+		return "[Function]"
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return v.String()
+	}
+	return string(data)
+}
+
+func (r *jsRuntime) timerFunc(repeat bool) func(call goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(r.vm.NewTypeError("callback must be a function"))
+		}
+		delay := time.Duration(call.Argument(1).ToInteger()) * time.Millisecond
+		if delay < 0 {
+			delay = 0
+		}
+		var args []goja.Value
+		if len(call.Arguments) > 2 {
+			args = call.Arguments[2:]
+		}
+		r.timerID++
+		id := r.timerID
+		t := &jsTimer{interval: delay, repeat: repeat}
+		var fire func()
+		fire = func() {
+			r.post(func() error {
+				if _, ok := r.timers[id]; !ok {
+					return nil
+				}
+				if t.repeat {
+					t.timer = time.AfterFunc(t.interval, fire)
+				} else {
+					delete(r.timers, id)
+					r.pending--
+				}
+				_, err := fn(goja.Undefined(), args...)
+				return err
+			})
+		}
+		t.timer = time.AfterFunc(delay, fire)
+		r.timers[id] = t
+		r.pending++
+		return r.vm.ToValue(id)
+	}
+}
+
+func (r *jsRuntime) clearTimer(call goja.FunctionCall) goja.Value {
+	id := call.Argument(0).ToInteger()
+	if t, ok := r.timers[id]; ok {
+		t.timer.Stop()
+		delete(r.timers, id)
+		r.pending--
+	}
+	return goja.Undefined()
+}
+
+// fetch 只返回桩文件中的响应，没有匹配的桩时拒绝，避免脚本访问网络
+func (r *jsRuntime) fetch(call goja.FunctionCall) goja.Value {
+	url := call.Argument(0).String()
+	method := "GET"
+	if init, ok := call.Argument(1).(*goja.Object); ok {
+		if m := init.Get("method"); m != nil && !goja.IsUndefined(m) {
+			method = strings.ToUpper(m.String())
+		}
+	}
+	p, resolve, reject := r.vm.NewPromise()
+	stub := r.matchStub(method, url)
+	var body []byte
+	var err error
+	if stub == nil {
+		err = fmt.Errorf("fetch: no stub for %s %s", method, url)
+	} else {
+		switch {
+		case stub.JSON != nil:
+			body = stub.JSON
+		case stub.File != "":
+			body, err = os.ReadFile(stub.File)
+		default:
+			body = []byte(stub.Body)
+		}
+	}
+	// 响应异步返回，与真实的 fetch 一致
+	r.pending++
+	go r.post(func() error {
+		r.pending--
+		if err != nil {
+			return reject(r.vm.NewTypeError(err.Error()))
+		}
+		return resolve(r.response(url, stub, body))
+	})
+	return r.vm.ToValue(p)
+}
+
+// matchStub 查找桩响应：先精确匹配 "METHOD URL" 与 URL，再按最长前缀匹配
+func (r *jsRuntime) matchStub(method, url string) *FetchStub {
+	if stub, ok := r.stubs[method+" "+url]; ok {
+		return stub
+	}
+	if stub, ok := r.stubs[url]; ok && method == "GET" {
+		return stub
+	}
+	keys := make([]string, 0, len(r.stubs))
+	for key := range r.stubs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "*") {
+			continue
+		}
+		pattern, m := key, "GET"
+		if i := strings.IndexByte(key, ' '); i > 0 {
+			m, pattern = key[:i], key[i+1:]
+		}
+		if m == method && strings.HasPrefix(url, strings.TrimSuffix(pattern, "*")) {
+			return r.stubs[key]
+		}
+	}
+	return nil
+}
+
+func (r *jsRuntime) response(url string, stub *FetchStub, body []byte) *goja.Object {
+	vm := r.vm
+	status := stub.Status
+	if status == 0 {
+		status = 200
+	}
+	headers := make(map[string]string)
+	if stub.JSON != nil {
+		headers["content-type"] = "application/json"
+	}
+	for k, v := range stub.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	h := vm.NewObject()
+	_ = h.Set("get", func(name string) goja.Value {
+		if v, ok := headers[strings.ToLower(name)]; ok {
+			return vm.ToValue(v)
+		}
+		return goja.Null()
+	})
+	_ = h.Set("has", func(name string) bool {
+		_, ok := headers[strings.ToLower(name)]
+		return ok
+	})
+	res := vm.NewObject()
+	_ = res.Set("url", url)
+	_ = res.Set("status", status)
+	_ = res.Set("ok", status >= 200 && status < 300)
+	_ = res.Set("headers", h)
+	_ = res.Set("text", func() *goja.Promise {
+		p, resolve, _ := vm.NewPromise()
+		_ = resolve(string(body))
+		return p
+	})
+	_ = res.Set("json", func() *goja.Promise {
+		p, resolve, reject := vm.NewPromise()
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			_ = reject(vm.NewGoError(err))
+		} else {
+			_ = resolve(v)
+		}
+		return p
+	})
+	return res
+}
+
+// require 只提供宿主模块，其余模块应在打包时内联
+func (r *jsRuntime) require(name string) goja.Value {
+	switch name {
+	case "fs", "node:fs":
+		return r.fsModule()
+	}
+	panic(r.vm.NewGoError(fmt.Errorf("cannot find module %q", name)))
+}
+
+// allowed 解析路径并检查是否位于允许的目录中
+func (r *jsRuntime) allowed(name string) string {
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(r.workDir, name)
+	}
+	abs := filepath.Clean(name)
+	resolved := abs
+	// 不存在的文件检查其已存在的父目录，避免通过符号链接越界
+	for dir, rest := abs, ""; ; {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			resolved = filepath.Join(real, rest)
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+	for _, dir := range r.allow {
+		if rel, err := filepath.Rel(dir, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved
+		}
+	}
+	panic(r.vm.NewGoError(fmt.Errorf("fs access denied: %s is outside the allowed directories", name)))
+}
+
+func (r *jsRuntime) throw(err error) {
+	if err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+}
+
+// fsModule 同步的 fs 子集，所有路径限制在允许的目录中
+func (r *jsRuntime) fsModule() *goja.Object {
+	vm := r.vm
+	fs := vm.NewObject()
+	_ = fs.Set("readFileSync", func(name string) string {
+		data, err := os.ReadFile(r.allowed(name))
+		r.throw(err)
+		return string(data)
+	})
+	_ = fs.Set("writeFileSync", func(name, data string) {
+		r.throw(os.WriteFile(r.allowed(name), []byte(data), 0644))
+	})
+	_ = fs.Set("appendFileSync", func(name, data string) {
+		f, err := os.OpenFile(r.allowed(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		r.throw(err)
+		defer f.Close()
+		_, err = f.WriteString(data)
+		r.throw(err)
+	})
+	_ = fs.Set("existsSync", func(name string) bool {
+		_, err := os.Stat(r.allowed(name))
+		return err == nil
+	})
+	_ = fs.Set("readdirSync", func(name string) []string {
+		entries, err := os.ReadDir(r.allowed(name))
+		r.throw(err)
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		return names
+	})
+	_ = fs.Set("mkdirSync", func(call goja.FunctionCall) goja.Value {
+		name := r.allowed(call.Argument(0).String())
+		recursive := false
+		if opts, ok := call.Argument(1).(*goja.Object); ok {
+			recursive = opts.Get("recursive") != nil && opts.Get("recursive").ToBoolean()
+		}
+		if recursive {
+			r.throw(os.MkdirAll(name, 0755))
+		} else {
+			r.throw(os.Mkdir(name, 0755))
+		}
+		return goja.Undefined()
+	})
+	_ = fs.Set("unlinkSync", func(name string) {
+		r.throw(os.Remove(r.allowed(name)))
+	})
+	_ = fs.Set("statSync", func(name string) *goja.Object {
+		info, err := os.Stat(r.allowed(name))
+		r.throw(err)
+		stat := vm.NewObject()
+		_ = stat.Set("size", info.Size())
+		_ = stat.Set("mtimeMs", info.ModTime().UnixMilli())
+		_ = stat.Set("isFile", func() bool { return info.Mode().IsRegular() })
+		_ = stat.Set("isDirectory", func() bool { return info.IsDir() })
+		return stat
+	})
+	return fs
+}
@@ -0,0 +1,415 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// LibConfig 定义库构建模式：同一组入口输出多种格式，并更新 package.json 的入口字段
+type LibConfig struct {
+	Formats  []string `json:"formats"`  // esm、cjs、umd、iife，默认 esm 与 cjs
+	Package  string   `json:"package"`  // 需要读取依赖并更新的 package.json，默认工作目录下的 package.json
+	Types    string   `json:"types"`    // 类型声明所在目录（相对输出目录），默认 types
+	NoUpdate bool     `json:"noUpdate"` // 只构建，不更新 package.json
+}
+
+// libFormat 库构建的一种输出格式
+type libFormat struct {
+	name   string
+	format api.Format
+	ext    string // js 输出的扩展名
+	bundle bool   // 是否打包依赖（浏览器直接使用的格式）
+}
+
+var libFormats = map[string]libFormat{
+	"esm":  {name: "esm", format: api.FormatESModule, ext: ".js"},
+	"cjs":  {name: "cjs", format: api.FormatCommonJS, ext: ".cjs"},
+	"umd":  {name: "umd", format: api.FormatIIFE, ext: ".js", bundle: true},
+	"iife": {name: "iife", format: api.FormatIIFE, ext: ".js", bundle: true},
+}
+
+// libPackage package.json 中库构建需要的字段
+type libPackage struct {
+	Name                 string            `json:"name"`
+	Dependencies         map[string]string `json:"dependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+}
+
+// externals 返回依赖及其子路径，库输出中保持外部引用；
+// 浏览器直接使用的格式只保留 peerDependencies 为外部引用，其余依赖打包进输出
+func (p *libPackage) externals(bundle bool) []string {
+	var names []string
+	groups := []map[string]string{p.PeerDependencies}
+	if !bundle {
+		groups = append(groups, p.Dependencies, p.OptionalDependencies)
+	}
+	for _, deps := range groups {
+		for name := range deps {
+			names = append(names, name, name+"/*")
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// globalName 由包名生成 iife/umd 的全局变量名，例如 @scope/my-lib -> MyLib
+func (p *libPackage) globalName() string {
+	name := p.Name
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if name = pascalName(name); name == "" {
+		return "Library"
+	}
+	return name
+}
+
+// pascalName 将名称转换为大驼峰形式的标识符，去掉其他字符，例如 sub/my-utils -> SubMyUtils
+func pascalName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' && b.Len() > 0:
+			if upper && r >= 'a' && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			upper = false
+			b.WriteRune(r)
+		default:
+			upper = true
+		}
+	}
+	return b.String()
+}
+
+// entryGlobalName iife/umd 中入口的全局变量名：主入口使用库的全局名，
+// 其他入口追加输出名，例如 utils -> MyLibUtils，避免多个入口的脚本互相覆盖
+func entryGlobalName(global, name string, main bool) string {
+	if main {
+		return global
+	}
+	return global + pascalName(name)
+}
+
+// libOutbase 入口的公共目录，与 esbuild 未指定 outbase 时的默认值相同，
+// 用于单独构建每个入口时保持输出路径不变
+func libOutbase(entries []string, workDir string) string {
+	var base string
+	for i, entry := range entries {
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(workDir, entry)
+		}
+		dir := filepath.Dir(entry)
+		if i == 0 {
+			base = dir
+			continue
+		}
+		for base != dir && !strings.HasPrefix(dir, base+string(filepath.Separator)) {
+			parent := filepath.Dir(base)
+			if parent == base {
+				break
+			}
+			base = parent
+		}
+	}
+	return base
+}
+
+// runLibBuild 按每种格式分别构建到 outdir/{format}，然后更新 package.json
+func runLibBuild(options api.BuildOptions, lib *LibConfig) error {
+	workDir := buildWorkDir(&options)
+	pkgFile := lib.Package
+	if pkgFile == "" {
+		pkgFile = "package.json"
+	}
+	if !filepath.IsAbs(pkgFile) {
+		pkgFile = filepath.Join(workDir, pkgFile)
+	}
+	pkgData, err := os.ReadFile(pkgFile)
+	if err != nil {
+		return fmt.Errorf("library build requires package.json: %v", err)
+	}
+	var pkg libPackage
+	if err := json.Unmarshal(pkgData, &pkg); err != nil {
+		return fmt.Errorf("invalid %s: %v", pkgFile, err)
+	}
+	names := lib.Formats
+	if len(names) == 0 {
+		names = []string{"esm", "cjs"}
+	}
+	outdir := options.Outdir
+	if outdir == "" {
+		outdir = "dist"
+	}
+	// metafile 中的路径相对工作目录
+	if filepath.IsAbs(outdir) {
+		if rel, err := filepath.Rel(workDir, outdir); err == nil {
+			outdir = rel
+		}
+	}
+	outbase := options.Outbase
+	if outbase == "" {
+		outbase = libOutbase(options.EntryPoints, workDir)
+	}
+	built := false
+	build := func(opts api.BuildOptions) (*metafile, error) {
+		if built {
+			// 类型声明与格式无关，只在第一次构建时生成
+			opts.Plugins = withoutPlugin(options.Plugins, "declarations")
+		}
+		built = true
+		result := api.Build(opts)
+		for _, warn := range result.Warnings {
+			log.Printf("Warning: %s", warn.Text)
+		}
+		if len(result.Errors) > 0 {
+			for _, err := range result.Errors {
+				log.Printf("Error: %s", err.Text)
+			}
+			return nil, fmt.Errorf("build failed with %d errors", len(result.Errors))
+		}
+		return parseMetafile(result.Metafile)
+	}
+	// 每种格式的入口输出，键为入口，值为相对工作目录的输出路径
+	outputs := make(map[string]map[string]string)
+	for _, name := range names {
+		f, ok := libFormats[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("unknown library format %q, expected esm, cjs, umd or iife", name)
+		}
+		opts := options
+		opts.Format = f.format
+		opts.Bundle = true
+		opts.Outfile = ""
+		opts.Outdir = filepath.Join(outdir, f.name)
+		opts.Metafile = true
+		opts.OutExtension = map[string]string{".js": f.ext}
+		opts.External = append(append([]string(nil), options.External...), pkg.externals(f.bundle)...)
+		var builds []api.BuildOptions
+		if f.bundle {
+			// 一次构建只有一个全局变量名，每个入口单独构建并使用各自的全局名
+			global := opts.GlobalName
+			if global == "" {
+				global = pkg.globalName()
+			}
+			opts.Splitting = false
+			opts.Outbase = outbase
+			entryNames := make([]string, len(options.EntryPoints))
+			main := -1
+			for i, entry := range options.EntryPoints {
+				if !filepath.IsAbs(entry) {
+					entry = filepath.Join(workDir, entry)
+				}
+				rel, _ := filepath.Rel(outbase, entry)
+				entryNames[i] = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+				if entryNames[i] == "index" {
+					main = i
+				}
+			}
+			if main < 0 && len(entryNames) == 1 {
+				main = 0
+			}
+			for i, entry := range options.EntryPoints {
+				entryOpts := opts
+				entryOpts.EntryPoints = []string{entry}
+				entryOpts.GlobalName = entryGlobalName(global, entryNames[i], i == main)
+				if f.name == "umd" {
+					// iife 顶层的 var 在 CommonJS 中为模块变量，补充导出后可以同时用于 script 与 require
+					entryOpts.Footer = map[string]string{"js": fmt.Sprintf(`if (typeof module === "object" && module.exports) module.exports = %s;`, entryOpts.GlobalName)}
+				}
+				builds = append(builds, entryOpts)
+			}
+		} else {
+			opts.Splitting = opts.Splitting && f.format == api.FormatESModule
+			builds = append(builds, opts)
+		}
+		outputs[f.name] = make(map[string]string)
+		for _, opts := range builds {
+			meta, err := build(opts)
+			if err != nil {
+				return fmt.Errorf("%s %v", f.name, err)
+			}
+			for out, o := range meta.Outputs {
+				if o.EntryPoint != "" && strings.HasSuffix(out, f.ext) {
+					outputs[f.name][o.EntryPoint] = out
+				}
+			}
+		}
+		log.Printf("Built %s library to %s", f.name, opts.Outdir)
+	}
+	if lib.NoUpdate {
+		return nil
+	}
+	typesDir := lib.Types
+	if typesDir == "" {
+		typesDir = "types"
+	}
+	fields := libPackageFields(outputs, filepath.ToSlash(outdir), typesDir, workDir)
+	if err := updatePackageJSON(pkgFile, pkgData, fields); err != nil {
+		return fmt.Errorf("failed to update %s: %v", pkgFile, err)
+	}
+	log.Printf("Updated %s", pkgFile)
+	return nil
+}
+
+// withoutPlugin 返回去掉指定插件后的插件列表
+func withoutPlugin(plugins []api.Plugin, name string) []api.Plugin {
+	var kept []api.Plugin
+	for _, p := range plugins {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// libPackageFields 根据各格式的输出生成 main、module、types 与 exports 字段，
+// 名为 index 的入口（或唯一入口）作为包的主入口 "."
+func libPackageFields(outputs map[string]map[string]string, outdir, typesDir, workDir string) map[string]interface{} {
+	// 以 esm 输出（或第一个可用格式）计算入口的子路径
+	var reference map[string]string
+	var refFormat string
+	for _, name := range []string{"esm", "cjs", "umd", "iife"} {
+		if outputs[name] != nil {
+			reference, refFormat = outputs[name], name
+			break
+		}
+	}
+	type subpathEntry struct {
+		subpath string
+		entry   string
+		name    string // 相对格式目录、不含扩展名的输出名
+	}
+	var entries []subpathEntry
+	for entry, out := range reference {
+		rel := strings.TrimPrefix(out, path.Join(outdir, refFormat)+"/")
+		name := strings.TrimSuffix(rel, path.Ext(rel))
+		entries = append(entries, subpathEntry{subpath: "./" + name, entry: entry, name: name})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].subpath < entries[j].subpath })
+	main := -1
+	for i, e := range entries {
+		if e.name == "index" {
+			main = i
+		}
+	}
+	if main < 0 && len(entries) == 1 {
+		main = 0
+	}
+	if main >= 0 {
+		entries[main].subpath = "."
+	}
+	file := func(out string) string {
+		return "./" + strings.TrimPrefix(out, "./")
+	}
+	fields := make(map[string]interface{})
+	exports := make([]orderedField, 0, len(entries)+1)
+	for i, e := range entries {
+		var conditions []orderedField
+		types := path.Join(outdir, typesDir, e.name+".d.ts")
+		if _, err := os.Stat(filepath.Join(workDir, filepath.FromSlash(types))); err == nil {
+			conditions = append(conditions, orderedField{"types", file(types)})
+			if i == main {
+				fields["types"] = file(types)
+			}
+		}
+		if out, ok := outputs["esm"][e.entry]; ok {
+			conditions = append(conditions, orderedField{"import", file(out)})
+			if i == main {
+				fields["module"] = file(out)
+			}
+		}
+		if out, ok := outputs["cjs"][e.entry]; ok {
+			conditions = append(conditions, orderedField{"require", file(out)})
+			if i == main {
+				fields["main"] = file(out)
+			}
+		}
+		for _, name := range []string{"umd", "iife"} {
+			if out, ok := outputs[name][e.entry]; ok {
+				conditions = append(conditions, orderedField{"default", file(out)})
+				if i == main && fields["main"] == nil {
+					fields["main"] = file(out)
+				}
+				break
+			}
+		}
+		exports = append(exports, orderedField{e.subpath, orderedFields(conditions)})
+	}
+	sort.SliceStable(exports, func(i, j int) bool { return exports[i].key == "." && exports[j].key != "." })
+	exports = append(exports, orderedField{"./package.json", "./package.json"})
+	fields["exports"] = orderedFields(exports)
+	return fields
+}
+
+// orderedField 按顺序输出的 JSON 字段
+type orderedField struct {
+	key   string
+	value interface{}
+}
+
+// orderedFields 按声明顺序序列化的 JSON 对象
+type orderedFields []orderedField
+
+func (o orderedFields) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// updatePackageJSON 替换或追加 package.json 字段，保留其他字段的顺序与内容
+func updatePackageJSON(file string, data []byte, fields map[string]interface{}) error {
+	keys, values := orderedObject(data)
+	if keys == nil {
+		return fmt.Errorf("not a JSON object")
+	}
+	var object orderedFields
+	for _, key := range keys {
+		if v, ok := fields[key]; ok {
+			object = append(object, orderedField{key, v})
+			delete(fields, key)
+			continue
+		}
+		object = append(object, orderedField{key, values[key]})
+	}
+	for _, key := range []string{"main", "module", "types", "exports"} {
+		if v, ok := fields[key]; ok {
+			object = append(object, orderedField{key, v})
+		}
+	}
+	compact, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+	return writeFileAtomic(file, out.Bytes(), 0644)
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestLibBuild(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"package.json":              "{\n  \"name\": \"@acme/my-lib\",\n  \"version\": \"1.0.0\",\n  \"main\": \"old.js\",\n  \"dependencies\": {\"dep\": \"^1.0.0\"},\n  \"peerDependencies\": {\"peer\": \"^2.0.0\"}\n}\n",
+		"src/index.js":              "import dep from 'dep';\nimport { x } from 'peer/sub';\nexport const value = dep + x;\n",
+		"src/utils.js":              "export const util = 1;\n",
+		"node_modules/dep/index.js": "module.exports = 1;",
+		"dist/types/index.d.ts":     "export declare const value: number;",
+	}
+	writeTree(t, dir, files)
+	err := runLibBuild(api.BuildOptions{
+		AbsWorkingDir: dir,
+		EntryPoints:   []string{"src/index.js", "src/utils.js"},
+		Outdir:        "dist",
+		Platform:      api.PlatformNeutral,
+		Write:         true,
+	}, &LibConfig{Formats: []string{"esm", "cjs", "umd"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"esm/index.js", "esm/utils.js", "cjs/index.cjs", "cjs/utils.cjs", "umd/index.js", "umd/utils.js"} {
+		if _, err := os.Stat(filepath.Join(dir, "dist", name)); err != nil {
+			t.Errorf("missing output %s", name)
+		}
+	}
+	esm, _ := os.ReadFile(filepath.Join(dir, "dist", "esm", "index.js"))
+	if !strings.Contains(string(esm), `from "dep"`) || !strings.Contains(string(esm), `from "peer/sub"`) {
+		t.Errorf("dependencies not external:\n%s", esm)
+	}
+	umd, _ := os.ReadFile(filepath.Join(dir, "dist", "umd", "index.js"))
+	if !strings.Contains(string(umd), "var MyLib") || !strings.Contains(string(umd), "module.exports = MyLib") {
+		t.Errorf("unexpected umd output:\n%s", umd)
+	}
+	umdUtils, _ := os.ReadFile(filepath.Join(dir, "dist", "umd", "utils.js"))
+	if !strings.Contains(string(umdUtils), "var MyLibUtils") || !strings.Contains(string(umdUtils), "module.exports = MyLibUtils") {
+		t.Errorf("unexpected umd utils output:\n%s", umdUtils)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, _ := orderedObject(data)
+	if got := strings.Join(keys, ","); got != "name,version,main,dependencies,peerDependencies,module,types,exports" {
+		t.Errorf("unexpected key order %s", got)
+	}
+	var pkg struct {
+		Main    string                     `json:"main"`
+		Module  string                     `json:"module"`
+		Types   string                     `json:"types"`
+		Exports map[string]json.RawMessage `json:"exports"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Main != "./dist/cjs/index.cjs" || pkg.Module != "./dist/esm/index.js" || pkg.Types != "./dist/types/index.d.ts" {
+		t.Errorf("unexpected entry fields %+v", pkg)
+	}
+	want := map[string]string{
+		".":              `{"types":"./dist/types/index.d.ts","import":"./dist/esm/index.js","require":"./dist/cjs/index.cjs","default":"./dist/umd/index.js"}`,
+		"./utils":        `{"import":"./dist/esm/utils.js","require":"./dist/cjs/utils.cjs","default":"./dist/umd/utils.js"}`,
+		"./package.json": `"./package.json"`,
+	}
+	if len(pkg.Exports) != len(want) {
+		t.Errorf("unexpected exports %s", data)
+	}
+	for key, value := range want {
+		var got bytes.Buffer
+		_ = json.Compact(&got, pkg.Exports[key])
+		if got.String() != value {
+			t.Errorf("exports[%q] = %s, want %s", key, got.String(), value)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package commands
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// manifestEntry 资源清单项，路径均相对于输出目录
+type manifestEntry struct {
+	File           string   `json:"file"`
+	Src            string   `json:"src,omitempty"`
+	IsEntry        bool     `json:"isEntry,omitempty"`
+	Imports        []string `json:"imports,omitempty"`
+	DynamicImports []string `json:"dynamicImports,omitempty"`
+	CSS            []string `json:"css,omitempty"`
+	Integrity      string   `json:"integrity,omitempty"`
+}
+
+// manifestPlugin 每次构建结束后根据 metafile 生成资源清单，file 为相对输出目录的清单路径
+func manifestPlugin(file string) api.Plugin {
+	return api.Plugin{
+		Name: "manifest",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				if len(result.Errors) > 0 || result.Metafile == "" {
+					return api.OnEndResult{}, nil
+				}
+				meta, err := parseMetafile(result.Metafile)
+				if err != nil {
+					return api.OnEndResult{}, err
+				}
+				workDir := options.AbsWorkingDir
+				if workDir == "" {
+					workDir, _ = os.Getwd()
+				}
+				outdir := options.Outdir
+				if outdir == "" {
+					outdir = filepath.Dir(options.Outfile)
+				}
+				if outdir, err = filepath.Abs(outdir); err != nil {
+					return api.OnEndResult{}, err
+				}
+				manifest := buildManifest(meta, result.OutputFiles, workDir, outdir, options.OutExtension)
+				data, err := json.MarshalIndent(manifest, "", "  ")
+				if err != nil {
+					return api.OnEndResult{}, err
+				}
+				target := file
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(outdir, target)
+				}
+				if !options.Write {
+					result.OutputFiles = append(result.OutputFiles, outputFile(target, data))
+					return api.OnEndResult{}, nil
+				}
+				if err := writeFileAtomic(target, data, 0644); err != nil {
+					return api.OnEndResult{}, fmt.Errorf("failed to write manifest: %v", err)
+				}
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
+
+// buildManifest 计算入口、代码块和资源到输出文件的映射，outExtension 为构建配置的输出扩展名
+func buildManifest(meta *metafile, files []api.OutputFile, workDir, outdir string, outExtension map[string]string) map[string]*manifestEntry {
+	// 代码块的扩展名，例如配置 .js 输出为 .mjs 时
+	jsExt, cssExt := ".js", ".css"
+	if ext := outExtension[".js"]; ext != "" {
+		jsExt = ext
+	}
+	if ext := outExtension[".css"]; ext != "" {
+		cssExt = ext
+	}
+	contents := make(map[string][]byte, len(files))
+	for _, f := range files {
+		contents[f.Path] = f.Contents
+	}
+	// rel 将 metafile 中相对工作目录的输出路径转换为相对输出目录的路径
+	rel := func(out string) string {
+		abs := filepath.Join(workDir, filepath.FromSlash(out))
+		r, err := filepath.Rel(outdir, abs)
+		if err != nil {
+			return out
+		}
+		return filepath.ToSlash(r)
+	}
+	integrity := func(out string) string {
+		data, ok := contents[filepath.Join(workDir, filepath.FromSlash(out))]
+		if !ok {
+			var err error
+			if data, err = os.ReadFile(filepath.Join(workDir, filepath.FromSlash(out))); err != nil {
+				return ""
+			}
+		}
+		sum := sha512.Sum384(data)
+		return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+	manifest := make(map[string]*manifestEntry)
+	for out, o := range meta.Outputs {
+		if strings.HasSuffix(out, ".map") {
+			continue
+		}
+		entry := &manifestEntry{File: rel(out), Integrity: integrity(out)}
+		key := "_" + entry.File
+		switch {
+		case o.EntryPoint != "":
+			key, entry.Src, entry.IsEntry = o.EntryPoint, o.EntryPoint, true
+			if strings.HasSuffix(out, cssExt) && !strings.HasSuffix(o.EntryPoint, ".css") {
+				// js 入口引入的样式由 cssBundle 记录，不单独作为入口
+				continue
+			}
+		case !strings.HasSuffix(out, jsExt) && !strings.HasSuffix(out, cssExt) && len(o.Inputs) == 1:
+			// file 或 copy 加载器生成的资源
+			for input := range o.Inputs {
+				key, entry.Src = input, input
+			}
+		}
+		for _, imp := range o.Imports {
+			if imp.External {
+				continue
+			}
+			switch imp.Kind {
+			case "import-statement":
+				entry.Imports = append(entry.Imports, rel(imp.Path))
+			case "dynamic-import":
+				entry.DynamicImports = append(entry.DynamicImports, rel(imp.Path))
+			}
+		}
+		if o.CssBundle != "" {
+			entry.CSS = append(entry.CSS, rel(o.CssBundle))
+		}
+		sort.Strings(entry.Imports)
+		sort.Strings(entry.DynamicImports)
+		manifest[key] = entry
+	}
+	return manifest
+}
+
+// writeFileAtomic 先写入同目录下的临时文件再重命名，避免读取到写了一半的文件
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
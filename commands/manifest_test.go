@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestManifestPlugin(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/main.js":   `import "./main.css"; import logo from "./logo.png"; import {shared} from "./shared.js"; console.log(logo, shared); import("./lazy.js");`,
+		"src/lazy.js":   `import {shared} from "./shared.js"; console.log(shared);`,
+		"src/shared.js": `export const shared = 1;`,
+		"src/main.css":  `body{color:red}`,
+		"src/logo.png":  "PNG",
+	}
+	writeTree(t, dir, files)
+	// 默认扩展名与 outExtension 配置的 .mjs 代码块
+	for _, outExtension := range []map[string]string{nil, {".js": ".mjs"}} {
+		testManifest(t, dir, outExtension)
+	}
+}
+
+func testManifest(t *testing.T, dir string, outExtension map[string]string) {
+	t.Helper()
+	result := api.Build(api.BuildOptions{
+		EntryPoints:   []string{"src/main.js"},
+		Bundle:        true,
+		Splitting:     true,
+		Format:        api.FormatESModule,
+		Metafile:      true,
+		Write:         true,
+		AbsWorkingDir: dir,
+		Outdir:        filepath.Join(dir, "dist"),
+		EntryNames:    "[name]-[hash]",
+		OutExtension:  outExtension,
+		AssetNames:    "assets/[name]-[hash]",
+		Loader:        map[string]api.Loader{".png": api.LoaderFile},
+		Plugins:       []api.Plugin{manifestPlugin("manifest.json")},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build failed: %v", result.Errors[0].Text)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "dist", "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest map[string]*manifestEntry
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	main := manifest["src/main.js"]
+	if main == nil || !main.IsEntry || !strings.HasPrefix(main.File, "main-") {
+		t.Fatalf("missing entry in manifest: %s", data)
+	}
+	if len(main.Imports) != 1 || len(main.DynamicImports) != 1 || len(main.CSS) != 1 || !strings.HasPrefix(main.Integrity, "sha384-") {
+		t.Errorf("unexpected entry: %+v", main)
+	}
+	if logo := manifest["src/logo.png"]; logo == nil || !strings.HasPrefix(logo.File, "assets/logo-") {
+		t.Errorf("missing asset in manifest: %s", data)
+	}
+	for _, chunk := range main.Imports {
+		if _, ok := manifest["_"+chunk]; !ok {
+			t.Errorf("missing chunk %s in manifest: %s", chunk, data)
+		}
+	}
+}
+
+func TestManifestWithoutMetafile(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"src/main.js": "console.log(1)"})
+	t.Chdir(dir)
+	// --manifest 开启了 Metafile，但未指定 --metafile 时不写入 meta.json
+	err := runEsbuildOnce(api.BuildOptions{
+		EntryPoints: []string{"src/main.js"},
+		Outfile:     "out.js",
+		Metafile:    true,
+		Write:       true,
+		Plugins:     []api.Plugin{manifestPlugin("manifest.json")},
+	}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "meta.json")); err == nil {
+		t.Error("meta.json written without --metafile")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err != nil {
+		t.Errorf("missing manifest: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// memoryFS 保存最近一次构建的输出文件，按 URL 前缀提供，不写入磁盘
+type memoryFS struct {
+	prefix string // URL 前缀，以 / 结尾
+	outdir string // 输出目录绝对路径，文件按相对输出目录的路径映射
+	mu     sync.RWMutex
+	files  map[string]*memoryFile
+}
+
+// memoryFile 内存中的输出文件
+type memoryFile struct {
+	data    []byte
+	etag    string
+	modTime time.Time
+}
+
+func newMemoryFS(prefix, outdir string) *memoryFS {
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &memoryFS{prefix: prefix, outdir: outdir, files: make(map[string]*memoryFile)}
+}
+
+// setOutdir 重新读取配置后更新输出目录
+func (m *memoryFS) setOutdir(outdir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.outdir = outdir
+}
+
+// update 替换为新的构建输出，内容不变的文件保留修改时间，返回发生变化的 URL 路径
+func (m *memoryFS) update(outputs []api.OutputFile) []string {
+	now := time.Now()
+	files := make(map[string]*memoryFile, len(outputs))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var changed []string
+	for _, f := range outputs {
+		rel, err := filepath.Rel(m.outdir, f.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		name := m.prefix + filepath.ToSlash(rel)
+		etag := `"` + f.Hash + `"`
+		if old, ok := m.files[name]; ok && old.etag == etag {
+			files[name] = old
+			continue
+		}
+		files[name] = &memoryFile{data: f.Contents, etag: etag, modTime: now}
+		changed = append(changed, name)
+	}
+	for name := range m.files {
+		if _, ok := files[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	m.files = files
+	sort.Strings(changed)
+	return changed
+}
+
+// lookup 查找 URL 对应的文件，目录返回其中的 index.html
+func (m *memoryFS) lookup(urlPath string) (string, *memoryFile) {
+	if !strings.HasPrefix(urlPath, m.prefix) && urlPath+"/" != m.prefix {
+		return "", nil
+	}
+	name := path.Clean("/" + urlPath)
+	if strings.HasSuffix(urlPath, "/") || urlPath+"/" == m.prefix {
+		name = path.Join(name, "index.html")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return name, m.files[name]
+}
+
+// serve 返回内存中的文件，不存在时返回 false 交给静态目录处理
+func (m *memoryFS) serve(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	name, f := m.lookup(r.URL.Path)
+	if f == nil {
+		return false
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", f.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, name, f.modTime, bytes.NewReader(f.data))
+	return true
+}
+
+// serveBuildConfig 按配置文件构建到内存，配置相关的文件（包括 html 页面）变化后重新读取配置并替换构建，
+// 配置错误时保留当前构建，返回停止监听与构建的函数
+func (h *hotReloadHandler) serveBuildConfig(file, prefix string) (func(), error) {
+	load := func() (api.BuildOptions, []string, error) {
+		config, err := loadEsbuildConfig(file)
+		if err != nil {
+			return api.BuildOptions{}, nil, err
+		}
+		inputs := configInputs(file, &config)
+		options, err := esbuildOptions(&config)
+		return options, inputs, err
+	}
+	options, inputs, err := load()
+	if err != nil {
+		return nil, err
+	}
+	buildCtx, err := h.serveBuild(options, prefix)
+	if err != nil {
+		return nil, err
+	}
+	watcher, err := newInputWatcher()
+	if err != nil {
+		buildCtx.Dispose()
+		return nil, err
+	}
+	watcher.set(inputs, defaultWatchDelay)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case name := <-watcher.changes:
+				log.Printf("%s changed, reloading build configuration", name)
+				next, nextInputs, err := load()
+				if err != nil {
+					log.Printf("Configuration error: %v", err)
+					log.Println("Keep watching with the previous configuration")
+					continue
+				}
+				// 先停止当前构建，避免两个构建同时更新内存中的文件
+				buildCtx.Cancel()
+				buildCtx.Dispose()
+				if buildCtx, err = h.serveBuild(next, prefix); err != nil {
+					log.Printf("Configuration error: %v", err)
+					log.Println("Restarting with the previous configuration")
+					if buildCtx, err = h.serveBuild(options, prefix); err != nil {
+						log.Printf("Failed to restart build: %v", err)
+						return
+					}
+					continue
+				}
+				options = next
+				watcher.set(nextInputs, defaultWatchDelay)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		watcher.close()
+		if buildCtx != nil {
+			buildCtx.Cancel()
+			buildCtx.Dispose()
+		}
+	}, nil
+}
+
+// serveBuild 在监听模式下构建到内存，每次构建成功后更新文件并按变化的文件通知客户端
+func (h *hotReloadHandler) serveBuild(options api.BuildOptions, prefix string) (api.BuildContext, error) {
+	options.Write = false
+	outdir := buildOutdir(&options)
+	if outdir == "" {
+		return nil, fmt.Errorf("build served from memory requires outdir or outfile")
+	}
+	// 重新读取配置后沿用原有的文件，新构建的第一次输出同样按变化通知
+	reloaded := h.memory != nil
+	if reloaded {
+		h.memory.setOutdir(outdir)
+	} else {
+		h.memory = newMemoryFS(prefix, outdir)
+	}
+	options.Plugins = append(options.Plugins, api.Plugin{
+		Name: "httpd-memory",
+		Setup: func(build api.PluginBuild) {
+			var (
+				start  time.Time
+				builds int
+			)
+			build.OnStart(func() (api.OnStartResult, error) {
+				start = time.Now()
+				return api.OnStartResult{}, nil
+			})
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				builds++
+				elapsed := time.Since(start).Round(time.Millisecond)
+				printMessages(result.Errors, api.ErrorMessage)
+				printMessages(result.Warnings, api.WarningMessage)
+				if len(result.Errors) > 0 {
+					log.Printf("Build #%d failed with %d errors in %v", builds, len(result.Errors), elapsed)
+					return api.OnEndResult{}, nil
+				}
+				changed := h.memory.update(result.OutputFiles)
+				log.Printf("Build #%d succeeded in %v, %d of %d outputs changed, served from memory under %s", builds, elapsed, len(changed), len(result.OutputFiles), h.memory.prefix)
+				if len(changed) > 0 && (builds > 1 || reloaded) {
+					h.notifyChanges(changed...)
+				}
+				return api.OnEndResult{}, nil
+			})
+		},
+	})
+	buildCtx, ctxErr := api.Context(options)
+	if ctxErr != nil {
+		return nil, fmt.Errorf("failed to create build context: %v", ctxErr)
+	}
+	if err := buildCtx.Watch(api.WatchOptions{Delay: int(defaultWatchDelay / time.Millisecond)}); err != nil {
+		buildCtx.Dispose()
+		return nil, fmt.Errorf("failed to start watch mode: %v", err)
+	}
+	return buildCtx, nil
+}
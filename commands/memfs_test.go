@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestMemoryFS(t *testing.T) {
+	static := t.TempDir()
+	if err := os.WriteFile(filepath.Join(static, "robots.txt"), []byte("static"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outdir := filepath.Join(static, "dist")
+	m := newMemoryFS("/app", outdir)
+	changed := m.update([]api.OutputFile{
+		{Path: filepath.Join(outdir, "index.html"), Contents: []byte("<html><body>app</body></html>"), Hash: "h1"},
+		{Path: filepath.Join(outdir, "main.js"), Contents: []byte("console.log(1)"), Hash: "j1"},
+		{Path: filepath.Join(static, "outside.js"), Contents: []byte("ignored"), Hash: "o1"},
+	})
+	if got := strings.Join(changed, ","); got != "/app/index.html,/app/main.js" {
+		t.Errorf("unexpected changed files %s", got)
+	}
+	h := &hotReloadHandler{fs: http.FileServer(http.Dir(static)), dir: static, memory: m, injectExts: []string{".html"}}
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/app/main.js", "")
+	if rec.Code != 200 || rec.Body.String() != "console.log(1)" || rec.Header().Get("ETag") != `"j1"` || rec.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+		t.Errorf("unexpected js response %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	if rec := get("/app/main.js", `"j1"`); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching etag, got %d", rec.Code)
+	}
+	rec = get("/app/", "")
+	if body := rec.Body.String(); rec.Code != 200 || !strings.Contains(body, "/_hotreload") || rec.Header().Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Errorf("expected injected index page, got %d %q", rec.Code, body)
+	}
+	if rec := get("/robots.txt", ""); rec.Body.String() != "static" {
+		t.Errorf("expected static fallback, got %q", rec.Body.String())
+	}
+
+	changed = m.update([]api.OutputFile{
+		{Path: filepath.Join(outdir, "index.html"), Contents: []byte("<html><body>app</body></html>"), Hash: "h1"},
+		{Path: filepath.Join(outdir, "main.js"), Contents: []byte("console.log(2)"), Hash: "j2"},
+	})
+	if got := strings.Join(changed, ","); got != "/app/main.js" {
+		t.Errorf("unexpected changed files after rebuild %s", got)
+	}
+}
+
+func TestServeBuildConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeTree(t, dir, map[string]string{
+		"build.json":     `{"entryPoints": ["src/index.html"], "outdir": "dist", "bundle": true}`,
+		"src/index.html": `<html><body><script type="module" src="./a.js"></script></body></html>`,
+		"src/a.js":       `console.log("a")`,
+		"src/b.js":       `console.log("b")`,
+	})
+	h := &hotReloadHandler{dir: dir}
+	stop, err := h.serveBuildConfig("build.json", "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	wait := func(name, want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, f := h.memory.lookup(name); f != nil && strings.Contains(string(f.data), want) {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("%s never contained %q", name, want)
+	}
+	wait("/index.html", "a.js")
+
+	// 页面中新增的脚本作为入口构建
+	writeTree(t, dir, map[string]string{
+		"src/index.html": `<html><body><script type="module" src="./b.js"></script></body></html>`,
+	})
+	wait("/index.html", "b.js")
+	wait("/b.js", `"b"`)
+}
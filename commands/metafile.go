@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// metafile esbuild 生成的 metafile 结构，路径均相对于工作目录
+type metafile struct {
+	Inputs  map[string]metafileInput  `json:"inputs"`
+	Outputs map[string]metafileOutput `json:"outputs"`
+}
+
+type metafileInput struct {
+	Bytes   int              `json:"bytes"`
+	Imports []metafileImport `json:"imports"`
+	Format  string           `json:"format,omitempty"`
+}
+
+type metafileImport struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	External bool   `json:"external,omitempty"`
+	Original string `json:"original,omitempty"`
+}
+
+type metafileOutput struct {
+	Bytes      int                            `json:"bytes"`
+	Inputs     map[string]metafileOutputInput `json:"inputs"`
+	Imports    []metafileImport               `json:"imports"`
+	Exports    []string                       `json:"exports"`
+	EntryPoint string                         `json:"entryPoint,omitempty"`
+	CssBundle  string                         `json:"cssBundle,omitempty"`
+}
+
+type metafileOutputInput struct {
+	BytesInOutput int `json:"bytesInOutput"`
+}
+
+// parseMetafile 解析 api.BuildResult 中的 metafile 内容
+func parseMetafile(data string) (*metafile, error) {
+	var m metafile
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("failed to parse metafile: %v", err)
+	}
+	return &m, nil
+}
+
+// readMetafile 读取 metafile 文件
+func readMetafile(file string) (*metafile, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metafile: %v", err)
+	}
+	return parseMetafile(string(data))
+}
+
+// entryOutput 查找入口文件对应的输出，ext 用于区分 js 与 css 输出
+func (m *metafile) entryOutput(entry, ext string) (string, *metafileOutput) {
+	for out, o := range m.Outputs {
+		if o.EntryPoint == entry && strings.HasSuffix(out, ext) {
+			o := o
+			return out, &o
+		}
+	}
+	return "", nil
+}
+
+// staticChunks 递归收集输出静态导入的代码块
+func (m *metafile) staticChunks(out string) []string {
+	var chunks []string
+	seen := map[string]bool{out: true}
+	var walk func(string)
+	walk = func(out string) {
+		for _, imp := range m.Outputs[out].Imports {
+			if imp.External || imp.Kind != "import-statement" || seen[imp.Path] {
+				continue
+			}
+			seen[imp.Path] = true
+			chunks = append(chunks, imp.Path)
+			walk(imp.Path)
+		}
+	}
+	walk(out)
+	return chunks
+}
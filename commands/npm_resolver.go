@@ -0,0 +1,982 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// NpmResolveConfig 定义从本地 npm 归档或 npm 仓库解析裸模块的配置
+type NpmResolveConfig struct {
+	Archives []string `json:"archives"` // 存放 .tgz 归档的目录（例如 units npm 的输出目录）
+	Registry string   `json:"registry"` // npm 仓库地址，为空时只使用本地归档
+	Cache    string   `json:"cache"`    // 仓库下载的缓存目录，默认为用户缓存目录下的 units/npm
+}
+
+// enabled 判断是否需要启用 npm 解析插件
+func (c *NpmResolveConfig) enabled() bool {
+	return c != nil && (len(c.Archives) > 0 || c.Registry != "")
+}
+
+const npmNamespace = "npm-archive"
+
+// npmManifest 归档内 package.json 中解析需要的字段
+type npmManifest struct {
+	Name                 string            `json:"name"`
+	Version              string            `json:"version"`
+	Main                 string            `json:"main"`
+	Module               string            `json:"module"`
+	Browser              json.RawMessage   `json:"browser"`
+	Exports              json.RawMessage   `json:"exports"`
+	SideEffects          json.RawMessage   `json:"sideEffects"`
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	PeerDependencies     map[string]string `json:"peerDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+}
+
+// dependencyRange 查询依赖声明的版本范围
+func (m *npmManifest) dependencyRange(name string) string {
+	for _, deps := range []map[string]string{m.Dependencies, m.PeerDependencies, m.OptionalDependencies, m.DevDependencies} {
+		if r, ok := deps[name]; ok {
+			return r
+		}
+	}
+	return ""
+}
+
+// npmPackage 已加载到内存中的 npm 归档
+type npmPackage struct {
+	name     string
+	version  string
+	files    map[string][]byte
+	manifest npmManifest
+}
+
+func (p *npmPackage) key() string {
+	return p.name + "@" + p.version
+}
+
+// npmArchive 本地归档文件索引项
+type npmArchive struct {
+	version semver
+	file    string
+}
+
+// npmResolver 在内存中从 npm 归档解析和加载模块
+type npmResolver struct {
+	config     NpmResolveConfig
+	platform   api.Platform
+	conditions []string
+	mainFields []string
+	external   []string
+	workDir    string
+	root       npmManifest
+	mu         sync.Mutex
+	indexed    bool
+	archives   map[string][]npmArchive // 以文件名中的包名为键
+	packages   map[string]*npmPackage  // 以 name@version 为键
+	fetching   map[string]*sync.Mutex  // 以包名为键，同一个包同时只下载一次
+	cache      *buildCache             // 解压后的归档内容，nil 时每次启动重新解压
+}
+
+// npmResolvePlugin 创建从 npm 归档解析裸模块的 esbuild 插件，cache 可为 nil
+func npmResolvePlugin(config NpmResolveConfig, cache *buildCache) api.Plugin {
+	return api.Plugin{
+		Name: "npm-archive",
+		Setup: func(build api.PluginBuild) {
+			r := newNpmResolver(config, build.InitialOptions)
+			r.cache = cache
+			build.OnResolve(api.OnResolveOptions{Filter: `^\.\.?(/|$)`, Namespace: npmNamespace}, r.resolveRelative)
+			build.OnResolve(api.OnResolveOptions{Filter: `^[^./]`}, r.resolveBare)
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: npmNamespace}, r.load)
+		},
+	}
+}
+
+func newNpmResolver(config NpmResolveConfig, options *api.BuildOptions) *npmResolver {
+	r := &npmResolver{
+		config:   config,
+		archives: make(map[string][]npmArchive),
+		packages: make(map[string]*npmPackage),
+		fetching: make(map[string]*sync.Mutex),
+	}
+	if r.config.Registry != "" && r.config.Cache == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			r.config.Cache = filepath.Join(dir, "units", "npm")
+		} else {
+			r.config.Cache = filepath.Join(os.TempDir(), "units-npm")
+		}
+	}
+	workDir := ""
+	if options != nil {
+		r.platform = options.Platform
+		r.conditions = options.Conditions
+		r.mainFields = options.MainFields
+		r.external = options.External
+		workDir = options.AbsWorkingDir
+	}
+	if workDir == "" {
+		workDir, _ = os.Getwd()
+	}
+	r.workDir = workDir
+	if data, err := os.ReadFile(filepath.Join(workDir, "package.json")); err == nil {
+		_ = json.Unmarshal(data, &r.root)
+	}
+	return r
+}
+
+// resolveBare 解析裸模块导入，例如 react 或 @scope/pkg/sub
+func (r *npmResolver) resolveBare(args api.OnResolveArgs) (api.OnResolveResult, error) {
+	if args.Kind == api.ResolveEntryPoint || filepath.IsAbs(args.Path) || strings.Contains(args.Path, ":") || r.isExternal(args.Path) {
+		return api.OnResolveResult{}, nil
+	}
+	name, subpath := splitPackagePath(args.Path)
+	if name == "" {
+		return api.OnResolveResult{}, nil
+	}
+	rng := r.root.dependencyRange(name)
+	if args.Namespace == npmNamespace {
+		key, _ := splitArchivePath(args.Importer)
+		if importer := r.loaded(key); importer != nil {
+			if importer.name == name {
+				// 包内通过自身名称引用
+				return r.resolveIn(importer, subpath, args.Kind)
+			}
+			rng = importer.manifest.dependencyRange(name)
+		}
+	}
+	pkg, err := r.pkg(name, rng)
+	if err != nil {
+		return api.OnResolveResult{}, err
+	}
+	if pkg == nil {
+		// 未找到归档时交由 esbuild 默认解析（例如 node_modules）
+		return api.OnResolveResult{}, nil
+	}
+	return r.resolveIn(pkg, subpath, args.Kind)
+}
+
+// resolveRelative 解析归档内文件之间的相对导入
+func (r *npmResolver) resolveRelative(args api.OnResolveArgs) (api.OnResolveResult, error) {
+	key, file := splitArchivePath(args.Importer)
+	pkg := r.loaded(key)
+	if pkg == nil {
+		return api.OnResolveResult{}, fmt.Errorf("unknown archive importer %s", args.Importer)
+	}
+	target := path.Clean(path.Join(path.Dir(file), args.Path))
+	resolved, ok := pkg.probe(target)
+	if !ok {
+		return api.OnResolveResult{}, fmt.Errorf("cannot find %s in %s", args.Path, pkg.key())
+	}
+	return r.result(pkg, resolved), nil
+}
+
+// load 从内存归档中读取文件内容
+func (r *npmResolver) load(args api.OnLoadArgs) (api.OnLoadResult, error) {
+	key, file := splitArchivePath(args.Path)
+	pkg := r.loaded(key)
+	if pkg == nil {
+		return api.OnLoadResult{}, fmt.Errorf("archive %s not loaded", key)
+	}
+	data, ok := pkg.files[file]
+	if !ok {
+		return api.OnLoadResult{}, fmt.Errorf("missing %s in %s", file, key)
+	}
+	contents := string(data)
+	return api.OnLoadResult{
+		Contents:   &contents,
+		ResolveDir: r.workDir,
+		Loader:     api.LoaderDefault,
+	}, nil
+}
+
+func (r *npmResolver) resolveIn(pkg *npmPackage, subpath string, kind api.ResolveKind) (api.OnResolveResult, error) {
+	file, err := pkg.entry(subpath, r.conditionsFor(kind), r.fields())
+	if err != nil {
+		return api.OnResolveResult{}, err
+	}
+	resolved, ok := pkg.probe(file)
+	if !ok {
+		return api.OnResolveResult{}, fmt.Errorf("cannot find %s in %s", file, pkg.key())
+	}
+	return r.result(pkg, resolved), nil
+}
+
+func (r *npmResolver) result(pkg *npmPackage, file string) api.OnResolveResult {
+	result := api.OnResolveResult{
+		Path:      pkg.key() + "/" + file,
+		Namespace: npmNamespace,
+	}
+	if strings.TrimSpace(string(pkg.manifest.SideEffects)) == "false" {
+		result.SideEffects = api.SideEffectsFalse
+	}
+	return result
+}
+
+// conditionsFor 计算 exports 解析时启用的条件
+func (r *npmResolver) conditionsFor(kind api.ResolveKind) []string {
+	conditions := []string{"default"}
+	if kind == api.ResolveJSRequireCall || kind == api.ResolveJSRequireResolve {
+		conditions = append(conditions, "require")
+	} else {
+		conditions = append(conditions, "import")
+	}
+	switch r.platform {
+	case api.PlatformNode:
+		conditions = append(conditions, "node")
+	case api.PlatformBrowser:
+		conditions = append(conditions, "browser")
+	}
+	if r.conditions == nil {
+		conditions = append(conditions, "module")
+	}
+	return append(conditions, r.conditions...)
+}
+
+// fields 计算没有 exports 时使用的入口字段
+func (r *npmResolver) fields() []string {
+	if len(r.mainFields) > 0 {
+		return r.mainFields
+	}
+	switch r.platform {
+	case api.PlatformNode:
+		return []string{"main", "module"}
+	case api.PlatformBrowser:
+		return []string{"browser", "module", "main"}
+	default:
+		return []string{"module", "main"}
+	}
+}
+
+func (r *npmResolver) isExternal(spec string) bool {
+	if strings.HasPrefix(spec, "node:") {
+		return true
+	}
+	for _, ext := range r.external {
+		if ext == spec {
+			return true
+		}
+		if i := strings.IndexByte(ext, '*'); i >= 0 {
+			if strings.HasPrefix(spec, ext[:i]) && strings.HasSuffix(spec, ext[i+1:]) {
+				return true
+			}
+		} else if strings.HasPrefix(spec, ext+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *npmResolver) loaded(key string) *npmPackage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.packages[key]
+}
+
+// pkg 按版本范围查找并加载包，优先使用本地归档，其次使用仓库；
+// 访问仓库时不持有 r.mu，只按包名互斥，其他包的解析不受影响
+func (r *npmResolver) pkg(name, rng string) (*npmPackage, error) {
+	r.mu.Lock()
+	if !r.indexed {
+		r.indexed = true
+		dirs := r.config.Archives
+		if r.config.Cache != "" {
+			dirs = append(dirs[:len(dirs):len(dirs)], r.config.Cache)
+		}
+		for _, dir := range dirs {
+			r.indexArchives(dir)
+		}
+	}
+	archive, ok := r.pickLocal(name, rng, r.config.Registry == "")
+	r.mu.Unlock()
+	if !ok && r.config.Registry != "" {
+		var err error
+		if archive, ok, err = r.fetch(name, rng); err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+	key := name + "@" + archive.version.String()
+	if pkg := r.loaded(key); pkg != nil {
+		return pkg, nil
+	}
+	pkg, err := r.readArchive(archive.file)
+	if err != nil {
+		return nil, err
+	}
+	pkg.name = name
+	pkg.version = archive.version.String()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// 同时读取同一个归档时使用先完成的结果
+	if found, ok := r.packages[key]; ok {
+		return found, nil
+	}
+	r.packages[key] = pkg
+	return pkg, nil
+}
+
+// fetch 从仓库下载满足版本范围的包到缓存目录并加入索引，同一个包的并发请求等待第一次下载完成
+func (r *npmResolver) fetch(name, rng string) (npmArchive, bool, error) {
+	r.mu.Lock()
+	lock, ok := r.fetching[name]
+	if !ok {
+		lock = new(sync.Mutex)
+		r.fetching[name] = lock
+	}
+	r.mu.Unlock()
+	lock.Lock()
+	defer lock.Unlock()
+	// 等待期间其他请求可能已经下载了满足范围的版本
+	r.mu.Lock()
+	archive, ok := r.pickLocal(name, rng, false)
+	r.mu.Unlock()
+	if ok {
+		return archive, true, nil
+	}
+	version, err := r.pickRemote(name, rng)
+	if err != nil || version == "" {
+		return npmArchive{}, false, err
+	}
+	if err = os.MkdirAll(r.config.Cache, 0755); err != nil {
+		return npmArchive{}, false, fmt.Errorf("create npm cache: %w", err)
+	}
+	if err = fetchNPM(r.config.Cache, strings.TrimSuffix(r.config.Registry, "/"), name, version); err != nil {
+		return npmArchive{}, false, err
+	}
+	v, _ := parseSemver(version)
+	archive = npmArchive{version: v, file: filepath.Join(r.config.Cache, npmArchiveName(name, version))}
+	r.mu.Lock()
+	r.archives[npmSafeName(name)] = append(r.archives[npmSafeName(name)], archive)
+	r.mu.Unlock()
+	return archive, true, nil
+}
+
+// indexArchives 扫描目录中的 .tgz 文件，文件名格式与 units npm 保持一致
+func (r *npmResolver) indexArchives(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".tgz") {
+			continue
+		}
+		base := strings.TrimSuffix(name, ".tgz")
+		for i := 0; i < len(base)-1; i++ {
+			if base[i] != '-' || base[i+1] < '0' || base[i+1] > '9' {
+				continue
+			}
+			if v, ok := parseSemver(base[i+1:]); ok {
+				r.archives[base[:i]] = append(r.archives[base[:i]], npmArchive{version: v, file: filepath.Join(dir, name)})
+				break
+			}
+		}
+	}
+}
+
+// pickLocal 选择满足版本范围的最高本地版本，loose 为 true 时范围不满足也回退到最高版本
+func (r *npmResolver) pickLocal(name, rng string, loose bool) (npmArchive, bool) {
+	var candidates []npmArchive
+	candidates = append(candidates, r.archives[npmSafeName(name)]...)
+	if strings.HasPrefix(name, "@") {
+		// npm pack 的命名方式：@scope/name -> scope-name
+		candidates = append(candidates, r.archives[strings.ReplaceAll(name[1:], "/", "-")]...)
+	}
+	if len(candidates) == 0 {
+		return npmArchive{}, false
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].version.compare(candidates[j].version) > 0 })
+	for _, c := range candidates {
+		if semverSatisfies(rng, c.version) {
+			return c, true
+		}
+	}
+	if loose {
+		log.Printf("npm: no archive of %s satisfies %q, using %s", name, rng, candidates[0].version)
+		return candidates[0], true
+	}
+	return npmArchive{}, false
+}
+
+// pickRemote 从仓库元数据中选择满足版本范围的最高版本
+func (r *npmResolver) pickRemote(name, rng string) (string, error) {
+	doc, err := r.packument(name, rng, false)
+	if err != nil {
+		return "", err
+	}
+	version := doc.pick(rng)
+	if version == "" {
+		// 缓存的元数据可能已过期，重新获取一次
+		if doc, err = r.packument(name, rng, true); err != nil {
+			return "", err
+		}
+		version = doc.pick(rng)
+	}
+	return version, nil
+}
+
+// npmPackument npm 仓库中包的简略元数据
+type npmPackument struct {
+	DistTags map[string]string          `json:"dist-tags"`
+	Versions map[string]json.RawMessage `json:"versions"`
+}
+
+func (d *npmPackument) pick(rng string) string {
+	if latest := d.DistTags["latest"]; latest != "" {
+		if v, ok := parseSemver(latest); ok && semverSatisfies(rng, v) {
+			return latest
+		}
+	}
+	var best semver
+	found := ""
+	for version := range d.Versions {
+		v, ok := parseSemver(version)
+		if !ok || !semverSatisfies(rng, v) {
+			continue
+		}
+		if found == "" || v.compare(best) > 0 {
+			best, found = v, version
+		}
+	}
+	return found
+}
+
+// packument 读取包元数据，优先使用缓存文件
+func (r *npmResolver) packument(name, rng string, refresh bool) (*npmPackument, error) {
+	cached := filepath.Join(r.config.Cache, npmSafeName(name)+".packument.json")
+	var doc npmPackument
+	// 禁用构建缓存时总是重新获取
+	if r.cache != nil && r.cache.disabled {
+		refresh = true
+	}
+	if !refresh {
+		if data, err := os.ReadFile(cached); err == nil && json.Unmarshal(data, &doc) == nil {
+			r.cache.count("packument", true)
+			return &doc, nil
+		}
+	}
+	r.cache.count("packument", false)
+	log.Printf("npm: fetch metadata of %s", name)
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s", strings.TrimSuffix(r.config.Registry, "/"), name), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch metadata of %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return &doc, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch metadata of %s: %s", name, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read metadata of %s: %w", name, err)
+	}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse metadata of %s: %w", name, err)
+	}
+	if err = os.MkdirAll(r.config.Cache, 0755); err == nil {
+		_ = os.WriteFile(cached, data, 0644)
+	}
+	return &doc, nil
+}
+
+// readArchive 读取归档，解压后的内容保存在构建缓存中，以路径、大小与修改时间为键
+func (r *npmResolver) readArchive(file string) (*npmPackage, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open: %w", err)
+	}
+	key := fmt.Sprintf("%s\x00%d\x00%d", file, info.Size(), info.ModTime().UnixNano())
+	var files map[string][]byte
+	if r.cache.get("npm-archive", key, &files) {
+		pkg := &npmPackage{files: files}
+		if json.Unmarshal(files["package.json"], &pkg.manifest) == nil {
+			r.cache.count("npm-archive", true)
+			return pkg, nil
+		}
+	}
+	r.cache.count("npm-archive", false)
+	pkg, err := readNpmArchive(file)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.put("npm-archive", key, pkg.files)
+	return pkg, nil
+}
+
+// readNpmArchive 将 .tgz 归档读入内存，去掉顶层目录（通常为 package/）
+func readNpmArchive(file string) (*npmPackage, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("fail to open: %w", err)
+	}
+	gzr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gzip error: %s: %w", file, err)
+	}
+	defer gzr.Close()
+	pkg := &npmPackage{files: make(map[string][]byte)}
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read archive fail: %s: %w", file, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read archive fail: %s: %w", file, err)
+		}
+		pkg.files[name] = content
+	}
+	manifest, ok := pkg.files["package.json"]
+	if !ok {
+		return nil, fmt.Errorf("missing package.json in %s", file)
+	}
+	if err := json.Unmarshal(manifest, &pkg.manifest); err != nil {
+		return nil, fmt.Errorf("parse package.json in %s: %w", file, err)
+	}
+	return pkg, nil
+}
+
+// entry 根据 exports、入口字段计算子路径对应的文件
+func (p *npmPackage) entry(subpath string, conditions []string, fields []string) (string, error) {
+	if exports := bytes.TrimSpace(p.manifest.Exports); len(exports) > 0 && string(exports) != "null" {
+		target, ok := resolveExports(exports, subpath, conditions)
+		if !ok {
+			return "", fmt.Errorf("%q is not exported by %s", subpath, p.key())
+		}
+		return path.Clean(target), nil
+	}
+	if subpath != "." {
+		return path.Clean(subpath), nil
+	}
+	for _, field := range fields {
+		var value string
+		switch field {
+		case "main":
+			value = p.manifest.Main
+		case "module":
+			value = p.manifest.Module
+		case "browser":
+			_ = json.Unmarshal(p.manifest.Browser, &value)
+		}
+		if value != "" {
+			return path.Clean(value), nil
+		}
+	}
+	return "index", nil
+}
+
+var npmProbeExtensions = []string{".js", ".mjs", ".cjs", ".json", ".jsx", ".ts", ".tsx", ".css"}
+
+// probe 按 node 的规则补全扩展名和目录下的 index 文件
+func (p *npmPackage) probe(file string) (string, bool) {
+	file = strings.TrimPrefix(file, "./")
+	if _, ok := p.files[file]; ok {
+		return file, true
+	}
+	for _, ext := range npmProbeExtensions {
+		if _, ok := p.files[file+ext]; ok {
+			return file + ext, true
+		}
+	}
+	for _, ext := range npmProbeExtensions {
+		index := path.Join(file, "index"+ext)
+		if _, ok := p.files[index]; ok {
+			return index, true
+		}
+	}
+	return "", false
+}
+
+// resolveExports 按 node 的 exports 规则解析子路径
+func resolveExports(exports json.RawMessage, subpath string, conditions []string) (string, bool) {
+	keys, values := orderedObject(exports)
+	if len(keys) == 0 || !strings.HasPrefix(keys[0], ".") {
+		// 字符串、数组或条件对象都等价于 "." 的映射
+		if subpath != "." {
+			return "", false
+		}
+		return resolveExportTarget(exports, "", conditions)
+	}
+	if target, ok := values[subpath]; ok && !strings.Contains(subpath, "*") {
+		return resolveExportTarget(target, "", conditions)
+	}
+	// 通配符匹配，按前缀长度优先
+	best, bestMatch := "", ""
+	for _, key := range keys {
+		star := strings.IndexByte(key, '*')
+		if star < 0 {
+			if strings.HasSuffix(key, "/") && strings.HasPrefix(subpath, key) && len(key) > len(best) {
+				best, bestMatch = key, subpath[len(key):]
+			}
+			continue
+		}
+		prefix, suffix := key[:star], key[star+1:]
+		if len(subpath) >= len(prefix)+len(suffix) && strings.HasPrefix(subpath, prefix) && strings.HasSuffix(subpath, suffix) && len(prefix) >= len(best) {
+			best, bestMatch = key, subpath[len(prefix):len(subpath)-len(suffix)]
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	target, ok := resolveExportTarget(values[best], bestMatch, conditions)
+	if ok && strings.HasSuffix(best, "/") {
+		target += bestMatch
+	}
+	return target, ok
+}
+
+func resolveExportTarget(target json.RawMessage, match string, conditions []string) (string, bool) {
+	target = bytes.TrimSpace(target)
+	if len(target) == 0 {
+		return "", false
+	}
+	switch target[0] {
+	case '"':
+		var s string
+		if json.Unmarshal(target, &s) != nil {
+			return "", false
+		}
+		return strings.ReplaceAll(s, "*", match), true
+	case '[':
+		var items []json.RawMessage
+		if json.Unmarshal(target, &items) != nil {
+			return "", false
+		}
+		for _, item := range items {
+			if s, ok := resolveExportTarget(item, match, conditions); ok {
+				return s, true
+			}
+		}
+	case '{':
+		keys, values := orderedObject(target)
+		for _, key := range keys {
+			for _, c := range conditions {
+				if key == c {
+					if s, ok := resolveExportTarget(values[key], match, conditions); ok {
+						return s, true
+					}
+					break
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// orderedObject 解析 JSON 对象并保留键的顺序（exports 的条件按声明顺序匹配）
+func orderedObject(raw json.RawMessage) ([]string, map[string]json.RawMessage) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil
+	}
+	keys := []string{}
+	values := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return nil, nil
+		}
+		keys = append(keys, key)
+		values[key] = value
+	}
+	return keys, values
+}
+
+// splitPackagePath 将裸模块拆分为包名和子路径，例如 @a/b/c -> @a/b, ./c
+func splitPackagePath(spec string) (name, subpath string) {
+	parts := strings.SplitN(spec, "/", 3)
+	n := 1
+	if strings.HasPrefix(spec, "@") {
+		if len(parts) < 2 || parts[1] == "" {
+			return "", ""
+		}
+		n = 2
+	}
+	if len(parts) <= n {
+		return spec, "."
+	}
+	name = strings.Join(parts[:n], "/")
+	return name, "./" + strings.TrimPrefix(spec, name+"/")
+}
+
+// splitArchivePath 拆分命名空间路径 name@version/file
+func splitArchivePath(p string) (key, file string) {
+	if len(p) < 2 {
+		return p, ""
+	}
+	at := strings.IndexByte(p[1:], '@') + 1
+	if at <= 0 {
+		return p, ""
+	}
+	slash := strings.IndexByte(p[at:], '/')
+	if slash < 0 {
+		return p, ""
+	}
+	return p[:at+slash], p[at+slash+1:]
+}
+
+func npmSafeName(name string) string {
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+func npmArchiveName(name, version string) string {
+	return fmt.Sprintf("%s-%s.tgz", npmSafeName(name), version)
+}
+
+// semver 语义化版本
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+var semverPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+func parseSemver(v string) (semver, bool) {
+	m := semverPattern.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return semver{}, false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	patch, _ := strconv.Atoi(m[3])
+	return semver{major: major, minor: minor, patch: patch, pre: m[4]}, true
+}
+
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if v.pre != "" {
+		s += "-" + v.pre
+	}
+	return s
+}
+
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d != 0 {
+			return d
+		}
+	}
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case o.pre == "":
+		return -1
+	}
+	return comparePrerelease(v.pre, o.pre)
+}
+
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		ai, aErr := strconv.Atoi(as[i])
+		bi, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if ai != bi {
+				return ai - bi
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// semverSatisfies 判断版本是否满足 npm 风格的版本范围（支持 ^ ~ x 比较符、连字符范围和 ||）
+func semverSatisfies(rng string, v semver) bool {
+	rng = strings.TrimSpace(rng)
+	if strings.HasPrefix(rng, "npm:") {
+		// 别名依赖 npm:name@range
+		if i := strings.LastIndexByte(rng, '@'); i > 4 {
+			rng = rng[i+1:]
+		}
+	}
+	for _, set := range strings.Split(rng, "||") {
+		if semverSetSatisfies(strings.TrimSpace(set), v) {
+			return true
+		}
+	}
+	return false
+}
+
+func semverSetSatisfies(set string, v semver) bool {
+	if v.pre != "" && !strings.Contains(set, "-") {
+		// 未显式声明预发布版本时不匹配预发布版本
+		return false
+	}
+	if set == "" || set == "*" || set == "latest" || set == "x" {
+		return true
+	}
+	fields := strings.Fields(set)
+	if len(fields) == 3 && fields[1] == "-" {
+		lo, _, _ := parsePartialSemver(fields[0])
+		hi, n, ok := parsePartialSemver(fields[2])
+		if !ok {
+			return true
+		}
+		return v.compare(lo) >= 0 && (n == 3 && v.compare(hi) <= 0 || n < 3 && v.compare(bumpSemver(hi, n)) < 0)
+	}
+	for i := 0; i < len(fields); i++ {
+		op := fields[i]
+		// 允许比较符与版本之间存在空格，例如 ">= 1.2.0"
+		if strings.Trim(op, "<>=^~") == "" && i+1 < len(fields) {
+			op += fields[i+1]
+			i++
+		}
+		if !semverComparatorSatisfies(op, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func semverComparatorSatisfies(c string, v semver) bool {
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(c, prefix) {
+			op, c = prefix, c[len(prefix):]
+			break
+		}
+	}
+	base, n, ok := parsePartialSemver(c)
+	if !ok {
+		// 无法识别的范围（git、file 等）视为任意版本
+		return true
+	}
+	if n == 0 {
+		return op != "<" && op != ">"
+	}
+	cmp := v.compare(base)
+	switch op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		if n < 3 {
+			return v.compare(bumpSemver(base, n)) >= 0
+		}
+		return cmp > 0
+	case "<=":
+		if n < 3 {
+			return v.compare(bumpSemver(base, n)) < 0
+		}
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	case "^":
+		switch {
+		case base.major > 0 || n == 1:
+			return cmp >= 0 && v.major == base.major
+		case base.minor > 0 || n == 2:
+			return cmp >= 0 && v.major == 0 && v.minor == base.minor
+		default:
+			return cmp >= 0 && v.compare(semver{patch: base.patch + 1}) < 0
+		}
+	case "~":
+		if n == 1 {
+			return cmp >= 0 && v.major == base.major
+		}
+		return cmp >= 0 && v.major == base.major && v.minor == base.minor
+	default:
+		if n == 3 {
+			return cmp == 0
+		}
+		return cmp >= 0 && v.compare(bumpSemver(base, n)) < 0
+	}
+}
+
+// parsePartialSemver 解析可能不完整的版本，例如 1、1.2、1.x，返回有效段数
+func parsePartialSemver(s string) (semver, int, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" || s == "*" || s == "x" || s == "X" {
+		return semver{}, 0, true
+	}
+	if v, ok := parseSemver(s); ok {
+		return v, 3, true
+	}
+	var nums []int
+	for _, part := range strings.SplitN(s, ".", 3) {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		num, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, 0, false
+		}
+		nums = append(nums, num)
+	}
+	v := semver{}
+	switch len(nums) {
+	case 3:
+		v.patch = nums[2]
+		fallthrough
+	case 2:
+		v.minor = nums[1]
+		fallthrough
+	case 1:
+		v.major = nums[0]
+	}
+	return v, len(nums), true
+}
+
+// bumpSemver 计算不完整版本的上界，例如 1.2 -> 1.3.0
+func bumpSemver(v semver, n int) semver {
+	switch n {
+	case 1:
+		return semver{major: v.major + 1}
+	case 2:
+		return semver{major: v.major, minor: v.minor + 1}
+	default:
+		return semver{major: v.major, minor: v.minor, patch: v.patch + 1}
+	}
+}
@@ -0,0 +1,206 @@
+package commands
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func writeTestArchive(t *testing.T, file string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: "package/" + name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSemverSatisfies(t *testing.T) {
+	cases := []struct {
+		rng     string
+		version string
+		want    bool
+	}{
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"1.x", "1.5.0", true},
+		{">=1.0.0 <2", "1.9.9", true},
+		{">=1.0.0 <2", "2.0.0", false},
+		{"1.0.0 - 1.2", "1.2.5", true},
+		{"^1.0.0 || ^2.0.0", "2.1.0", true},
+		{"^1.0.0", "1.1.0-beta.1", false},
+		{"", "3.0.0", true},
+		{"npm:other@^1.0.0", "1.0.1", true},
+	}
+	for _, c := range cases {
+		v, ok := parseSemver(c.version)
+		if !ok {
+			t.Fatalf("invalid version %s", c.version)
+		}
+		if got := semverSatisfies(c.rng, v); got != c.want {
+			t.Errorf("semverSatisfies(%q, %s) = %v, want %v", c.rng, c.version, got, c.want)
+		}
+	}
+}
+
+func TestResolveExports(t *testing.T) {
+	exports := []byte(`{
+		".": {"import": "./esm/index.js", "require": "./cjs/index.js"},
+		"./feature/*": {"browser": "./browser/*.js", "default": "./lib/*.js"},
+		"./package.json": "./package.json"
+	}`)
+	cases := map[string]string{
+		".":                "./esm/index.js",
+		"./feature/a/b":    "./browser/a/b.js",
+		"./package.json":   "./package.json",
+		"./not-exported/x": "",
+	}
+	for subpath, want := range cases {
+		got, _ := resolveExports(exports, subpath, []string{"default", "import", "browser"})
+		if got != want {
+			t.Errorf("resolveExports(%q) = %q, want %q", subpath, got, want)
+		}
+	}
+}
+
+func TestNpmResolvePlugin(t *testing.T) {
+	dir := t.TempDir()
+	archives := filepath.Join(dir, "archives")
+	if err := os.MkdirAll(archives, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestArchive(t, filepath.Join(archives, "dep-1.0.0.tgz"), map[string]string{
+		"package.json":  `{"name":"dep","version":"1.0.0","exports":{".":{"import":"./esm/index.js","require":"./cjs/index.js"}}}`,
+		"esm/index.js":  `import {helper} from "./helper"; export const dep = "dep-esm-" + helper;`,
+		"esm/helper.js": `export const helper = "v1";`,
+		"cjs/index.js":  `exports.dep = "dep-cjs";`,
+	})
+	writeTestArchive(t, filepath.Join(archives, "dep-2.0.0.tgz"), map[string]string{
+		"package.json": `{"name":"dep","version":"2.0.0","main":"index.js"}`,
+		"index.js":     `export const dep = "dep-v2";`,
+	})
+	writeTestArchive(t, filepath.Join(archives, "@scope_util-0.1.0.tgz"), map[string]string{
+		"package.json":  `{"name":"@scope/util","version":"0.1.0","module":"lib/index.mjs","dependencies":{"dep":"^1.0.0"}}`,
+		"lib/index.mjs": `import {dep} from "dep"; export const util = "util:" + dep;`,
+	})
+	entry := filepath.Join(dir, "main.js")
+	if err := os.WriteFile(entry, []byte(`import {util} from "@scope/util"; import {dep} from "dep"; console.log(util, dep);`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"dependencies":{"dep":"^2.0.0"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := api.Build(api.BuildOptions{
+		EntryPoints:   []string{entry},
+		Bundle:        true,
+		Format:        api.FormatESModule,
+		AbsWorkingDir: dir,
+		Outfile:       filepath.Join(dir, "out.js"),
+		Plugins:       []api.Plugin{npmResolvePlugin(NpmResolveConfig{Archives: []string{archives}}, nil)},
+	})
+	if len(result.Errors) > 0 {
+		t.Fatalf("build failed: %v", result.Errors[0].Text)
+	}
+	out := string(result.OutputFiles[0].Contents)
+	for _, want := range []string{"dep-esm-", "v1", "util:", "dep-v2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestNpmResolverFetch(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"slow", "fast"} {
+		writeTestArchive(t, filepath.Join(dir, name+".tgz"), map[string]string{
+			"package.json": `{"name":"` + name + `","version":"1.0.0","main":"index.js"}`,
+			"index.js":     `export default 1;`,
+		})
+	}
+	release := make(chan struct{})
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, tarball, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		if tarball == "" {
+			fmt.Fprintf(w, `{"dist-tags":{"latest":"1.0.0"},"versions":{"1.0.0":{}}}`)
+			return
+		}
+		if name == "slow" {
+			downloads.Add(1)
+			<-release
+		}
+		http.ServeFile(w, r, filepath.Join(dir, name+".tgz"))
+	}))
+	defer server.Close()
+	r := newNpmResolver(NpmResolveConfig{Registry: server.URL, Cache: filepath.Join(dir, "cache")}, nil)
+
+	// 下载中的包不阻塞其他包，同一个包的并发请求只下载一次
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pkg, err := r.pkg("slow", "^1.0.0")
+			if err == nil && pkg == nil {
+				err = fmt.Errorf("slow not found")
+			}
+			errs <- err
+		}()
+	}
+	done := make(chan error)
+	go func() {
+		_, err := r.pkg("fast", "^1.0.0")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resolving fast was blocked by the download of slow")
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("slow downloaded %d times", n)
+	}
+}
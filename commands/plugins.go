@@ -0,0 +1,743 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// PluginConfig 配置文件中按名称声明的内置插件
+type PluginConfig struct {
+	Name    string          `json:"name"`
+	Options json.RawMessage `json:"options"`
+}
+
+// builtinPlugins 可在配置中启用的内置插件
+var builtinPlugins = map[string]func(options json.RawMessage) (api.Plugin, error){
+	"copy":          copyPlugin,
+	"virtual":       virtualPlugin,
+	"raw":           rawPlugin,
+	"svg-component": svgComponentPlugin,
+	"clean":         cleanPlugin,
+}
+
+// builtinTransforms 可在配置中启用的源码转换，esbuild 对同一文件只使用第一个返回内容的 OnLoad，
+// 因此所有转换按配置顺序串联在同一个插件中
+var builtinTransforms = map[string]func(options json.RawMessage) (*sourceTransform, error){
+	"replace":     replaceTransform,
+	"glob-import": globImportTransform,
+}
+
+// sourceTransform 加载文件时对源码的转换，返回新的源码与需要监听的目录
+type sourceTransform struct {
+	filter *regexp.Regexp
+	apply  func(contents, file, workDir string) (string, []string, error)
+}
+
+// configPlugins 按配置顺序创建内置插件，源码转换合并为一个插件放在第一个转换的位置
+func configPlugins(configs []PluginConfig) ([]api.Plugin, error) {
+	plugins := make([]api.Plugin, 0, len(configs))
+	var transforms []*sourceTransform
+	for _, c := range configs {
+		if create, ok := builtinTransforms[c.Name]; ok {
+			transform, err := create(c.Options)
+			if err != nil {
+				return nil, fmt.Errorf("plugin %s: %v", c.Name, err)
+			}
+			if transforms == nil {
+				plugins = append(plugins, api.Plugin{})
+			}
+			transforms = append(transforms, transform)
+			continue
+		}
+		create, ok := builtinPlugins[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown plugin %q", c.Name)
+		}
+		plugin, err := create(c.Options)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %v", c.Name, err)
+		}
+		plugins = append(plugins, plugin)
+	}
+	for i := range plugins {
+		if plugins[i].Name == "" {
+			plugins[i] = sourceTransformPlugin(transforms)
+		}
+	}
+	return plugins, nil
+}
+
+// sourceTransformPlugin 依次应用源码转换，没有转换生效时交给后续插件或默认加载
+func sourceTransformPlugin(transforms []*sourceTransform) api.Plugin {
+	filters := make([]string, len(transforms))
+	for i, t := range transforms {
+		filters[i] = "(?:" + t.filter.String() + ")"
+	}
+	return api.Plugin{
+		Name: "transform",
+		Setup: func(build api.PluginBuild) {
+			workDir := buildWorkDir(build.InitialOptions)
+			build.OnLoad(api.OnLoadOptions{Filter: strings.Join(filters, "|"), Namespace: "file"}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				data, err := os.ReadFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+				contents := string(data)
+				var watchDirs []string
+				for _, t := range transforms {
+					if !t.filter.MatchString(args.Path) {
+						continue
+					}
+					var dirs []string
+					if contents, dirs, err = t.apply(contents, args.Path, workDir); err != nil {
+						return api.OnLoadResult{}, err
+					}
+					watchDirs = append(watchDirs, dirs...)
+				}
+				if contents == string(data) && len(watchDirs) == 0 {
+					return api.OnLoadResult{}, nil
+				}
+				return api.OnLoadResult{Contents: &contents, Loader: api.LoaderDefault, WatchDirs: watchDirs}, nil
+			})
+		},
+	}
+}
+
+// decodePluginOptions 解析插件选项，拒绝未知字段以便发现拼写错误
+func decodePluginOptions(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid options: %v", err)
+	}
+	return nil
+}
+
+// buildWorkDir 返回构建的工作目录
+func buildWorkDir(options *api.BuildOptions) string {
+	if options.AbsWorkingDir != "" {
+		return options.AbsWorkingDir
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// buildOutdir 返回构建输出目录的绝对路径
+func buildOutdir(options *api.BuildOptions) string {
+	outdir := options.Outdir
+	if outdir == "" && options.Outfile != "" {
+		outdir = filepath.Dir(options.Outfile)
+	}
+	if outdir == "" {
+		return ""
+	}
+	if !filepath.IsAbs(outdir) {
+		outdir = filepath.Join(buildWorkDir(options), outdir)
+	}
+	return filepath.Clean(outdir)
+}
+
+// copyPlugin 构建成功后将静态资源复制到输出目录，保留模式中非通配部分以下的目录结构
+//
+//	{"assets": [{"from": "public/**/*", "to": "."}]}
+func copyPlugin(raw json.RawMessage) (api.Plugin, error) {
+	var opts struct {
+		Assets []struct {
+			From string `json:"from"` // 相对工作目录的文件、目录或 glob 模式
+			To   string `json:"to"`   // 相对输出目录的目标目录
+		} `json:"assets"`
+	}
+	if err := decodePluginOptions(raw, &opts); err != nil {
+		return api.Plugin{}, err
+	}
+	return api.Plugin{
+		Name: "copy",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				outdir := buildOutdir(options)
+				if len(result.Errors) > 0 || !options.Write || outdir == "" {
+					return api.OnEndResult{}, nil
+				}
+				workDir := buildWorkDir(options)
+				copied := 0
+				for _, asset := range opts.Assets {
+					pattern := asset.From
+					if !filepath.IsAbs(pattern) {
+						pattern = filepath.Join(workDir, pattern)
+					}
+					n, err := copyGlob(pattern, filepath.Join(outdir, asset.To))
+					copied += n
+					if err != nil {
+						return api.OnEndResult{}, err
+					}
+				}
+				if copied > 0 {
+					log.Printf("Copied %d static files", copied)
+				}
+				return api.OnEndResult{}, nil
+			})
+		},
+	}, nil
+}
+
+// copyGlob 复制匹配的文件到 dest 目录，保留模式中非通配部分以下的目录结构，pattern 为目录时复制整个目录
+func copyGlob(pattern, dest string) (int, error) {
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		pattern = filepath.Join(pattern, "**", "*")
+	}
+	base := globBase(pattern)
+	files, err := expandGlob(pattern)
+	if err != nil {
+		return 0, fmt.Errorf("copy %s: %v", pattern, err)
+	}
+	copied := 0
+	for _, file := range files {
+		rel, err := filepath.Rel(base, file)
+		if err != nil {
+			rel = filepath.Base(file)
+		}
+		ok, err := copyIfNewer(file, filepath.Join(dest, rel))
+		if err != nil {
+			return copied, fmt.Errorf("copy %s: %v", file, err)
+		}
+		if ok {
+			copied++
+		}
+	}
+	return copied, nil
+}
+
+// copyIfNewer 目标不存在或早于源文件时复制，返回是否复制
+func copyIfNewer(src, dest string) (bool, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return false, err
+	}
+	if target, err := os.Stat(dest); err == nil && !target.ModTime().Before(info.ModTime()) && target.Size() == info.Size() {
+		return false, nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return false, err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return false, err
+	}
+	if err := out.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// defaultSourceFilter 未指定过滤条件时处理的脚本文件
+const defaultSourceFilter = `\.[cm]?[jt]sx?$`
+
+// replaceTransform 加载文件时按规则替换字符串或正则匹配的内容
+//
+//	{"rules": [{"filter": "\\.ts$", "search": "__VERSION__", "replace": "1.0.0"}]}
+func replaceTransform(raw json.RawMessage) (*sourceTransform, error) {
+	var opts struct {
+		Rules []struct {
+			Filter  string `json:"filter"`  // 文件路径正则，默认匹配脚本文件
+			Search  string `json:"search"`  // 查找的字符串或正则
+			Replace string `json:"replace"` // 替换内容，正则模式下可以使用 $1 引用分组
+			Regex   bool   `json:"regex"`   // search 是否为正则
+		} `json:"rules"`
+	}
+	if err := decodePluginOptions(raw, &opts); err != nil {
+		return nil, err
+	}
+	type rule struct {
+		filter  *regexp.Regexp
+		search  *regexp.Regexp
+		literal string
+		replace string
+	}
+	rules := make([]rule, 0, len(opts.Rules))
+	var filters []string
+	for _, r := range opts.Rules {
+		if r.Search == "" {
+			return nil, fmt.Errorf("rule without search")
+		}
+		if r.Filter == "" {
+			r.Filter = defaultSourceFilter
+		}
+		filter, err := regexp.Compile(r.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %v", r.Filter, err)
+		}
+		compiled := rule{filter: filter, literal: r.Search, replace: r.Replace}
+		if r.Regex {
+			if compiled.search, err = regexp.Compile(r.Search); err != nil {
+				return nil, fmt.Errorf("invalid search %q: %v", r.Search, err)
+			}
+		}
+		rules = append(rules, compiled)
+		filters = append(filters, "(?:"+r.Filter+")")
+	}
+	if len(filters) == 0 {
+		// 没有规则时不匹配任何文件
+		filters = append(filters, `^\b$`)
+	}
+	return &sourceTransform{
+		filter: regexp.MustCompile(strings.Join(filters, "|")),
+		apply: func(contents, file, _ string) (string, []string, error) {
+			for _, r := range rules {
+				if !r.filter.MatchString(file) {
+					continue
+				}
+				if r.search != nil {
+					contents = r.search.ReplaceAllString(contents, r.replace)
+				} else {
+					contents = strings.ReplaceAll(contents, r.literal, r.replace)
+				}
+			}
+			return contents, nil, nil
+		},
+	}, nil
+}
+
+const virtualNamespace = "virtual"
+
+// virtualModule 虚拟模块，配置中可以直接写内容字符串
+type virtualModule struct {
+	Contents   string `json:"contents"`
+	Loader     string `json:"loader"`     // 默认 js
+	ResolveDir string `json:"resolveDir"` // 解析模块内导入的目录，默认工作目录
+}
+
+func (m *virtualModule) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.Contents); err == nil {
+		return nil
+	}
+	type plain virtualModule
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+// virtualPlugin 将指定的导入路径解析为配置中的内联模块
+//
+//	{"modules": {"virtual:config": "export default { debug: false }"}}
+func virtualPlugin(raw json.RawMessage) (api.Plugin, error) {
+	var opts struct {
+		Modules map[string]*virtualModule `json:"modules"`
+	}
+	if err := decodePluginOptions(raw, &opts); err != nil {
+		return api.Plugin{}, err
+	}
+	names := make([]string, 0, len(opts.Modules))
+	for name := range opts.Modules {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Strings(names)
+	return api.Plugin{
+		Name: "virtual",
+		Setup: func(build api.PluginBuild) {
+			if len(names) == 0 {
+				return
+			}
+			workDir := buildWorkDir(build.InitialOptions)
+			build.OnResolve(api.OnResolveOptions{Filter: "^(?:" + strings.Join(names, "|") + ")$"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				return api.OnResolveResult{Path: args.Path, Namespace: virtualNamespace}, nil
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: virtualNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				m := opts.Modules[args.Path]
+				loader := api.LoaderJS
+				if m.Loader != "" {
+					if loader = parseLoader(m.Loader); loader == api.LoaderNone {
+						return api.OnLoadResult{}, fmt.Errorf("unknown loader %q", m.Loader)
+					}
+				}
+				dir := workDir
+				if m.ResolveDir != "" {
+					dir = filepath.Join(workDir, m.ResolveDir)
+				}
+				contents := m.Contents
+				return api.OnLoadResult{Contents: &contents, Loader: loader, ResolveDir: dir}, nil
+			})
+		},
+	}, nil
+}
+
+// resolveQuery 解析去掉查询后缀的导入路径，用于 ?raw、?component 等导入
+func resolveQuery(build api.PluginBuild, args api.OnResolveArgs, suffix, namespace string) (api.OnResolveResult, error) {
+	result := build.Resolve(strings.TrimSuffix(args.Path, suffix), api.ResolveOptions{
+		ResolveDir: args.ResolveDir,
+		Importer:   args.Importer,
+		Kind:       args.Kind,
+	})
+	if len(result.Errors) > 0 {
+		return api.OnResolveResult{Errors: result.Errors}, nil
+	}
+	return api.OnResolveResult{Path: result.Path, Namespace: namespace}, nil
+}
+
+const rawNamespace = "raw"
+
+// rawPlugin 将 ?raw 导入加载为文件内容字符串
+func rawPlugin(raw json.RawMessage) (api.Plugin, error) {
+	if err := decodePluginOptions(raw, &struct{}{}); err != nil {
+		return api.Plugin{}, err
+	}
+	return api.Plugin{
+		Name: "raw",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: `\?raw$`}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				return resolveQuery(build, args, "?raw", rawNamespace)
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: rawNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				data, err := os.ReadFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+				contents := string(data)
+				return api.OnLoadResult{Contents: &contents, Loader: api.LoaderText, WatchFiles: []string{args.Path}}, nil
+			})
+		},
+	}, nil
+}
+
+var (
+	globCallPattern    = regexp.MustCompile("import\\.meta\\.glob(?:<[^>]*>)?\\(\\s*(\\[[^\\]]*\\]|'[^']*'|\"[^\"]*\"|`[^`]*`)\\s*(?:,\\s*(\\{[^}]*\\}))?\\s*,?\\s*\\)")
+	globStringPattern  = regexp.MustCompile("'([^']*)'|\"([^\"]*)\"|`([^`]*)`")
+	globEagerPattern   = regexp.MustCompile(`\beager\s*:\s*true\b`)
+	globImportPattern  = regexp.MustCompile(`\bimport\s*:\s*['"]([\w$]+)['"]`)
+	globImportFilePath = regexp.MustCompile(defaultSourceFilter)
+)
+
+// globImportTransform 将 import.meta.glob('./pages/*.ts') 展开为路径到动态导入的对象，
+// 支持 { eager: true } 静态导入与 { import: 'default' } 只取指定导出，模式以 ! 开头时排除
+func globImportTransform(raw json.RawMessage) (*sourceTransform, error) {
+	if err := decodePluginOptions(raw, &struct{}{}); err != nil {
+		return nil, err
+	}
+	return &sourceTransform{
+		filter: globImportFilePath,
+		apply: func(contents, file, workDir string) (string, []string, error) {
+			if !strings.Contains(contents, "import.meta.glob") {
+				return contents, nil, nil
+			}
+			return expandGlobImports(contents, file, workDir)
+		},
+	}, nil
+}
+
+// expandGlobImports 替换源码中的 import.meta.glob 调用，返回新源码与需要监听的目录
+func expandGlobImports(source, file, workDir string) (string, []string, error) {
+	dir := filepath.Dir(file)
+	var (
+		imports   []string
+		watchDirs []string
+		failure   error
+		call      int
+	)
+	expanded := globCallPattern.ReplaceAllStringFunc(source, func(s string) string {
+		m := globCallPattern.FindStringSubmatch(s)
+		eager := globEagerPattern.MatchString(m[2])
+		var name string
+		if im := globImportPattern.FindStringSubmatch(m[2]); im != nil {
+			name = im[1]
+		}
+		matched := make(map[string]string) // key -> 相对当前文件的导入路径
+		var excluded []string
+		for _, q := range globStringPattern.FindAllStringSubmatch(m[1], -1) {
+			pattern := q[1] + q[2] + q[3]
+			negate := strings.HasPrefix(pattern, "!")
+			pattern = strings.TrimPrefix(pattern, "!")
+			rooted := strings.HasPrefix(pattern, "/")
+			abs := filepath.Join(dir, filepath.FromSlash(pattern))
+			if rooted {
+				abs = filepath.Join(workDir, filepath.FromSlash(pattern))
+			}
+			if negate {
+				excluded = append(excluded, filepath.ToSlash(abs))
+				continue
+			}
+			if !strings.HasPrefix(pattern, "./") && !strings.HasPrefix(pattern, "../") && !rooted {
+				failure = fmt.Errorf("%s: import.meta.glob pattern %q must start with ./, ../ or /", file, pattern)
+				return s
+			}
+			watchDirs = append(watchDirs, globBase(abs))
+			files, err := expandGlob(abs)
+			if err != nil {
+				failure = fmt.Errorf("%s: import.meta.glob %q: %v", file, pattern, err)
+				return s
+			}
+			for _, f := range files {
+				if f == file || !globImportFilePath.MatchString(f) && !strings.HasSuffix(f, ".json") && !strings.HasSuffix(f, ".css") {
+					continue
+				}
+				rel, _ := filepath.Rel(dir, f)
+				rel = filepath.ToSlash(rel)
+				if !strings.HasPrefix(rel, "../") {
+					rel = "./" + rel
+				}
+				key := rel
+				if rooted {
+					r, _ := filepath.Rel(workDir, f)
+					key = "/" + filepath.ToSlash(r)
+				}
+				matched[key] = rel
+			}
+		}
+		keys := make([]string, 0, len(matched))
+		for key, rel := range matched {
+			abs := filepath.ToSlash(filepath.Join(dir, filepath.FromSlash(rel)))
+			skip := false
+			for _, ex := range excluded {
+				if matchGlob(ex, abs) {
+					skip = true
+					break
+				}
+			}
+			if !skip {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString("{")
+		for i, key := range keys {
+			spec, _ := json.Marshal(matched[key])
+			k, _ := json.Marshal(key)
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n  ")
+			b.Write(k)
+			b.WriteString(": ")
+			switch {
+			case eager:
+				id := fmt.Sprintf("__glob_%d_%d", call, i)
+				if name != "" {
+					imports = append(imports, fmt.Sprintf("import { %s as %s } from %s;", name, id, spec))
+				} else {
+					imports = append(imports, fmt.Sprintf("import * as %s from %s;", id, spec))
+				}
+				b.WriteString(id)
+			case name != "":
+				fmt.Fprintf(&b, "() => import(%s).then((m) => m[%q])", spec, name)
+			default:
+				fmt.Fprintf(&b, "() => import(%s)", spec)
+			}
+		}
+		if len(keys) > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("}")
+		call++
+		return b.String()
+	})
+	if failure != nil {
+		return "", nil, failure
+	}
+	if len(imports) > 0 {
+		expanded = strings.Join(imports, "\n") + "\n" + expanded
+	}
+	return expanded, watchDirs, nil
+}
+
+const svgComponentNamespace = "svg-component"
+
+var (
+	svgRootPattern = regexp.MustCompile(`(?is)<svg\b([^>]*)>(.*)</svg\s*>`)
+	svgAttrPattern = regexp.MustCompile(`([A-Za-z_:][-A-Za-z0-9_:.]*)\s*=\s*("[^"]*"|'[^']*')`)
+)
+
+// svgComponentPlugin 将 foo.svg?component 导入转换为 JSX 组件，组件属性会覆盖 svg 根元素的属性
+//
+//	{"query": "component"}
+func svgComponentPlugin(raw json.RawMessage) (api.Plugin, error) {
+	opts := struct {
+		Query string `json:"query"` // 触发转换的查询参数，默认 component
+	}{Query: "component"}
+	if err := decodePluginOptions(raw, &opts); err != nil {
+		return api.Plugin{}, err
+	}
+	suffix := "?" + opts.Query
+	return api.Plugin{
+		Name: "svg-component",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: `\.svg` + regexp.QuoteMeta(suffix) + `$`}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				return resolveQuery(build, args, suffix, svgComponentNamespace)
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: `.*`, Namespace: svgComponentNamespace}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				data, err := os.ReadFile(args.Path)
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+				contents, err := svgComponent(string(data))
+				if err != nil {
+					return api.OnLoadResult{}, fmt.Errorf("%s: %v", args.Path, err)
+				}
+				return api.OnLoadResult{
+					Contents:   &contents,
+					Loader:     api.LoaderJSX,
+					ResolveDir: filepath.Dir(args.Path),
+					WatchFiles: []string{args.Path},
+				}, nil
+			})
+		},
+	}, nil
+}
+
+// svgComponent 生成渲染 svg 的 JSX 组件源码，子元素通过 dangerouslySetInnerHTML 原样输出
+func svgComponent(svg string) (string, error) {
+	m := svgRootPattern.FindStringSubmatch(svg)
+	if m == nil {
+		return "", fmt.Errorf("no <svg> root element")
+	}
+	attrs := make(map[string]interface{})
+	for _, a := range svgAttrPattern.FindAllStringSubmatch(m[1], -1) {
+		name, value := jsxAttrName(a[1]), a[2][1:len(a[2])-1]
+		if name == "style" {
+			style := make(map[string]string)
+			for _, decl := range strings.Split(value, ";") {
+				if k, v, ok := strings.Cut(decl, ":"); ok {
+					style[jsxAttrName(strings.TrimSpace(k))] = strings.TrimSpace(v)
+				}
+			}
+			attrs[name] = style
+			continue
+		}
+		attrs[name] = value
+	}
+	attrData, err := json.Marshal(attrs)
+	if err != nil {
+		return "", err
+	}
+	inner, _ := json.Marshal(strings.TrimSpace(m[2]))
+	return fmt.Sprintf(`const attrs = %s;
+const inner = %s;
+export default function SvgComponent(props) {
+  return <svg {...attrs} {...props} dangerouslySetInnerHTML={{ __html: inner }} />;
+}
+`, attrData, inner), nil
+}
+
+// jsxAttrName 将 svg 属性名转换为 JSX 属性名：class 为 className，
+// 连字符与命名空间形式转换为驼峰，data- 与 aria- 保持不变
+func jsxAttrName(name string) string {
+	if name == "class" {
+		return "className"
+	}
+	if strings.HasPrefix(name, "data-") || strings.HasPrefix(name, "aria-") {
+		return name
+	}
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '-' || r == ':' {
+			upper = true
+			continue
+		}
+		if upper && r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cleanPlugin 构建开始前清空输出目录，默认只在第一次构建时清空
+//
+//	{"keep": [".gitkeep", "static/**"], "always": false}
+func cleanPlugin(raw json.RawMessage) (api.Plugin, error) {
+	var opts struct {
+		Keep   []string `json:"keep"`   // 保留的文件，相对输出目录的 glob 模式
+		Always bool     `json:"always"` // 监听模式下每次重新构建前都清空
+	}
+	if err := decodePluginOptions(raw, &opts); err != nil {
+		return api.Plugin{}, err
+	}
+	return api.Plugin{
+		Name: "clean",
+		Setup: func(build api.PluginBuild) {
+			options := build.InitialOptions
+			cleaned := false
+			build.OnStart(func() (api.OnStartResult, error) {
+				if cleaned && !opts.Always || !options.Write {
+					return api.OnStartResult{}, nil
+				}
+				cleaned = true
+				outdir := buildOutdir(options)
+				if err := cleanDir(outdir, buildWorkDir(options), opts.Keep); err != nil {
+					return api.OnStartResult{}, err
+				}
+				return api.OnStartResult{}, nil
+			})
+		},
+	}, nil
+}
+
+// cleanDir 删除目录中除 keep 匹配之外的文件，拒绝清空工作目录或其上级目录
+func cleanDir(dir, workDir string, keep []string) error {
+	if dir == "" {
+		return nil
+	}
+	if rel, err := filepath.Rel(dir, workDir); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("refusing to clean %s, it contains the working directory", dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(keep) == 0 {
+		for _, e := range entries {
+			if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var dirs []string
+	err = filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		if d.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		for _, pattern := range keep {
+			if matchGlob(pattern, filepath.ToSlash(rel)) {
+				return nil
+			}
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return err
+	}
+	// 由深到浅删除空目录
+	for i := len(dirs) - 1; i >= 0; i-- {
+		_ = os.Remove(dirs[i])
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestConfigPlugins(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/main.js":       "import config from 'virtual:config';\nimport text from './note.txt?raw';\nimport Icon from './icon.svg?component';\nconst pages = import.meta.glob('./pages/*.js', { eager: true, import: 'default' });\nconst lazy = import.meta.glob(['./pages/*.js', '!./pages/b.js']);\nconsole.log(config, text, Icon, pages, lazy, __VERSION__);\n",
+		"src/note.txt":      "hello raw",
+		"src/icon.svg":      `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24" class="icon" stroke-width="2"><path d="M0 0h24v24H0z"/></svg>`,
+		"src/pages/a.js":    "export default 'page a'",
+		"src/pages/b.js":    "export default 'page b'",
+		"public/robots.txt": "User-agent: *",
+		"public/img/x.png":  "png",
+		"dist/stale.js":     "old",
+		"dist/.gitkeep":     "",
+	}
+	writeTree(t, dir, files)
+	var configs []PluginConfig
+	err := json.Unmarshal([]byte(`[
+		{"name": "clean", "options": {"keep": [".gitkeep"]}},
+		{"name": "virtual", "options": {"modules": {"virtual:config": "export default { debug: false }"}}},
+		{"name": "raw"},
+		{"name": "svg-component"},
+		{"name": "replace", "options": {"rules": [{"filter": "main\\.js$", "search": "__VERSION__", "replace": "\"1.2.3\""}]}},
+		{"name": "glob-import"},
+		{"name": "copy", "options": {"assets": [{"from": "public", "to": "static"}]}}
+	]`), &configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugins, err := configPlugins(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := api.Build(api.BuildOptions{
+		AbsWorkingDir: dir,
+		EntryPoints:   []string{"src/main.js"},
+		Outdir:        "dist",
+		Bundle:        true,
+		Format:        api.FormatESModule,
+		Splitting:     true,
+		JSX:           api.JSXTransform,
+		Write:         true,
+		Plugins:       plugins,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "dist", "main.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	for _, want := range []string{"debug: false", "hello raw", `"1.2.3"`, "page b", "viewBox", "className", "strokeWidth", "./pages/a.js"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "import.meta.glob") {
+		t.Errorf("import.meta.glob not expanded:\n%s", out)
+	}
+	for _, name := range []string{"static/robots.txt", "static/img/x.png", ".gitkeep"} {
+		if _, err := os.Stat(filepath.Join(dir, "dist", name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dist", "stale.js")); !os.IsNotExist(err) {
+		t.Errorf("stale output not cleaned: %v", err)
+	}
+
+	if _, err := configPlugins([]PluginConfig{{Name: "nope"}}); err == nil {
+		t.Errorf("expected error for unknown plugin")
+	}
+	if err := cleanDir(dir, filepath.Join(dir, "src"), nil); err == nil {
+		t.Errorf("expected refusal to clean a parent of the working directory")
+	}
+}
@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ProxyRule 反向代理规则，前缀下的请求（包括 WebSocket）转发到后端
+type ProxyRule struct {
+	Prefix       string            `json:"prefix"`       // URL 前缀，例如 /api
+	Target       string            `json:"target"`       // 后端地址，带路径时前缀被替换为该路径，例如 http://localhost:8080/v1
+	Rewrite      map[string]string `json:"rewrite"`      // 路径重写，正则 -> 替换，在前缀替换之后应用
+	Host         string            `json:"host"`         // Host 头：空保留请求的 Host，target 使用后端地址，其他为指定的值
+	Timeout      int               `json:"timeout"`      // 等待后端响应头的毫秒数，默认 30000
+	CookieDomain *string           `json:"cookieDomain"` // 改写 Set-Cookie 的 Domain，默认移除使 cookie 属于本地服务
+}
+
+// defaultProxyTimeout 等待后端响应头的默认时间
+const defaultProxyTimeout = 30 * time.Second
+
+// proxyRoute 已解析的代理规则
+type proxyRoute struct {
+	prefix   string
+	target   *url.URL
+	host     string
+	cookie   *string
+	rewrites []proxyRewrite
+	proxy    *httputil.ReverseProxy
+}
+
+type proxyRewrite struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+// parseProxyFlags 解析 /prefix=target 形式的规则
+func parseProxyFlags(values []string, host string, timeout time.Duration) ([]ProxyRule, error) {
+	var rules []ProxyRule
+	for _, v := range values {
+		prefix, target, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid proxy %q, expected /prefix=http://host:port", v)
+		}
+		rules = append(rules, ProxyRule{Prefix: prefix, Target: target, Host: host, Timeout: int(timeout / time.Millisecond)})
+	}
+	return rules, nil
+}
+
+// loadProxyRules 读取 JSON 数组形式的代理规则文件
+func loadProxyRules(file string) ([]ProxyRule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy config: %v", err)
+	}
+	var rules []ProxyRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse proxy config %s: %v", file, err)
+	}
+	return rules, nil
+}
+
+// newProxyRoutes 创建代理，前缀更长的规则优先
+func newProxyRoutes(rules []ProxyRule) ([]*proxyRoute, error) {
+	var routes []*proxyRoute
+	for _, rule := range rules {
+		route, err := newProxyRoute(rule)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	sort.SliceStable(routes, func(i, j int) bool { return len(routes[i].prefix) > len(routes[j].prefix) })
+	return routes, nil
+}
+
+func newProxyRoute(rule ProxyRule) (*proxyRoute, error) {
+	if !strings.HasPrefix(rule.Prefix, "/") {
+		return nil, fmt.Errorf("invalid proxy prefix %q, must start with /", rule.Prefix)
+	}
+	target, err := url.Parse(rule.Target)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("invalid proxy target %q, expected http(s)://host[:port][/path]", rule.Target)
+	}
+	route := &proxyRoute{
+		prefix: strings.TrimSuffix(rule.Prefix, "/"),
+		target: target,
+		host:   rule.Host,
+		cookie: rule.CookieDomain,
+	}
+	for pattern, replace := range rule.Rewrite {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy rewrite %q: %v", pattern, err)
+		}
+		route.rewrites = append(route.rewrites, proxyRewrite{re: re, replace: replace})
+	}
+	// 按模式排序，保证多条重写的应用顺序稳定
+	sort.Slice(route.rewrites, func(i, j int) bool { return route.rewrites[i].re.String() < route.rewrites[j].re.String() })
+	timeout := time.Duration(rule.Timeout) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultProxyTimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.ResponseHeaderTimeout = timeout
+	route.proxy = &httputil.ReverseProxy{
+		Rewrite:        route.rewrite,
+		Transport:      transport,
+		FlushInterval:  -1, // 立即转发，支持 SSE 等流式响应
+		ModifyResponse: route.modifyResponse,
+		ErrorHandler:   route.errorHandler,
+	}
+	return route, nil
+}
+
+// match 判断 URL 路径是否在前缀下，/api 匹配 /api 与 /api/...，不匹配 /apis
+func (p *proxyRoute) match(urlPath string) bool {
+	return p.prefix == "" || urlPath == p.prefix || strings.HasPrefix(urlPath, p.prefix+"/")
+}
+
+// mapPath 将请求路径映射为后端路径：目标带路径时替换前缀，然后应用重写
+func (p *proxyRoute) mapPath(urlPath string) string {
+	if p.target.Path != "" {
+		urlPath = singleJoiningSlash(p.target.Path, strings.TrimPrefix(urlPath, p.prefix))
+	}
+	for _, rw := range p.rewrites {
+		urlPath = rw.re.ReplaceAllString(urlPath, rw.replace)
+	}
+	if !strings.HasPrefix(urlPath, "/") {
+		urlPath = "/" + urlPath
+	}
+	return urlPath
+}
+
+// unmapPath 将后端路径映射回请求路径，只还原前缀替换
+func (p *proxyRoute) unmapPath(backendPath string) string {
+	if p.target.Path == "" {
+		return backendPath
+	}
+	base := strings.TrimSuffix(p.target.Path, "/")
+	if backendPath == base || strings.HasPrefix(backendPath, base+"/") {
+		return p.prefix + strings.TrimPrefix(backendPath, base)
+	}
+	return backendPath
+}
+
+func singleJoiningSlash(a, b string) string {
+	switch {
+	case b == "":
+		return a
+	case strings.HasSuffix(a, "/") && strings.HasPrefix(b, "/"):
+		return a + b[1:]
+	case !strings.HasSuffix(a, "/") && !strings.HasPrefix(b, "/"):
+		return a + "/" + b
+	}
+	return a + b
+}
+
+func (p *proxyRoute) rewrite(pr *httputil.ProxyRequest) {
+	pr.SetXForwarded()
+	out := pr.Out
+	out.URL.Scheme = p.target.Scheme
+	out.URL.Host = p.target.Host
+	out.URL.Path = p.mapPath(pr.In.URL.Path)
+	out.URL.RawPath = ""
+	if p.target.RawQuery != "" && out.URL.RawQuery != "" {
+		out.URL.RawQuery = p.target.RawQuery + "&" + out.URL.RawQuery
+	} else if p.target.RawQuery != "" {
+		out.URL.RawQuery = p.target.RawQuery
+	}
+	switch p.host {
+	case "":
+		out.Host = pr.In.Host
+	case "target":
+		out.Host = p.target.Host
+	default:
+		out.Host = p.host
+	}
+}
+
+// modifyResponse 改写指向后端的 Location 与 Set-Cookie 的 Domain、Path
+func (p *proxyRoute) modifyResponse(resp *http.Response) error {
+	if location := resp.Header.Get("Location"); location != "" {
+		resp.Header.Set("Location", p.rewriteLocation(location, resp.Request))
+	}
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) > 0 {
+		resp.Header.Del("Set-Cookie")
+		for _, cookie := range cookies {
+			resp.Header.Add("Set-Cookie", p.rewriteCookie(cookie))
+		}
+	}
+	return nil
+}
+
+// rewriteLocation 后端地址的重定向改为本地服务地址，路径按前缀还原
+func (p *proxyRoute) rewriteLocation(location string, out *http.Request) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	if u.IsAbs() {
+		if u.Host != p.target.Host && (out == nil || u.Host != out.Host) {
+			return location
+		}
+		// 转发时记录的原始请求地址
+		u.Scheme, u.Host = "http", ""
+		if out != nil {
+			if proto := out.Header.Get("X-Forwarded-Proto"); proto != "" {
+				u.Scheme = proto
+			}
+			u.Host = out.Header.Get("X-Forwarded-Host")
+		}
+		if u.Host == "" {
+			u.Scheme = ""
+		}
+	} else if !strings.HasPrefix(u.Path, "/") {
+		return location
+	}
+	u.Path = p.unmapPath(u.Path)
+	u.RawPath = ""
+	return u.String()
+}
+
+// rewriteCookie 按配置改写或移除 Domain，Path 按前缀还原
+func (p *proxyRoute) rewriteCookie(cookie string) string {
+	parts := strings.Split(cookie, ";")
+	kept := parts[:1]
+	for _, part := range parts[1:] {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(name) {
+		case "domain":
+			if p.cookie == nil || *p.cookie == "" {
+				continue
+			}
+			part = " Domain=" + *p.cookie
+		case "path":
+			part = " Path=" + p.unmapPath(value)
+		}
+		kept = append(kept, part)
+	}
+	return strings.Join(kept, ";")
+}
+
+// errorHandler 后端超时返回 504，其他错误返回 502，客户端断开时不记录
+func (p *proxyRoute) errorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+	status := http.StatusBadGateway
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		status = http.StatusGatewayTimeout
+	}
+	log.Printf("Proxy error: %s %s -> %s: %v", r.Method, r.URL.Path, p.target.Host, err)
+	http.Error(w, fmt.Sprintf("proxy error: %v", err), status)
+}
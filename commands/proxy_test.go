@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "1", Domain: "backend.test", Path: "/v1"})
+			http.Redirect(w, r, "http://"+r.Host+"/v1/home", http.StatusFound)
+		case "/v1/slow":
+			time.Sleep(300 * time.Millisecond)
+		case "/ws":
+			if r.Header.Get("Upgrade") != "websocket" {
+				http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+				return
+			}
+			conn, rw, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+			rw.Flush()
+			line, _ := rw.ReadString('\n')
+			rw.WriteString("echo " + line)
+			rw.Flush()
+		default:
+			fmt.Fprintf(w, "%s %s %s", r.URL.RequestURI(), r.Host, r.Header.Get("X-Forwarded-Host"))
+		}
+	}))
+	defer backend.Close()
+	backendHost := strings.TrimPrefix(backend.URL, "http://")
+
+	rules, err := parseProxyFlags([]string{"/api=" + backend.URL + "/v1", "/ws=" + backend.URL}, "", 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules = append(rules, ProxyRule{Prefix: "/legacy", Target: backend.URL, Rewrite: map[string]string{"^/legacy": "/old"}, Host: "target"})
+	routes, err := newProxyRoutes(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	front := httptest.NewServer(&hotReloadHandler{fs: http.NotFoundHandler(), proxies: routes})
+	defer front.Close()
+	frontHost := strings.TrimPrefix(front.URL, "http://")
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	get := func(path string) (*http.Response, string) {
+		resp, err := client.Get(front.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	if _, body := get("/api/users?id=1"); body != "/v1/users?id=1 "+frontHost+" "+frontHost {
+		t.Errorf("unexpected proxied request %q", body)
+	}
+	if _, body := get("/legacy/list"); body != "/old/list "+backendHost+" "+frontHost {
+		t.Errorf("unexpected rewritten request %q", body)
+	}
+	if resp, _ := get("/apis"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("/apis should not match /api, got %d", resp.StatusCode)
+	}
+	resp, _ := get("/api/login")
+	if loc := resp.Header.Get("Location"); loc != "http://"+frontHost+"/api/home" {
+		t.Errorf("unexpected location %q", loc)
+	}
+	if cookie := resp.Header.Get("Set-Cookie"); cookie != "sid=1; Path=/api" {
+		t.Errorf("unexpected cookie %q", cookie)
+	}
+	if resp, _ := get("/api/slow"); resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("expected gateway timeout, got %d", resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", frontHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n", frontHost)
+	r := bufio.NewReader(conn)
+	status, _ := r.ReadString('\n')
+	if !strings.Contains(status, "101") {
+		t.Fatalf("expected switching protocols, got %q", status)
+	}
+	for line, _ := r.ReadString('\n'); line != "\r\n" && line != ""; line, _ = r.ReadString('\n') {
+	}
+	fmt.Fprint(conn, "hello\n")
+	if line, _ := r.ReadString('\n'); line != "echo hello\n" {
+		t.Errorf("unexpected upgraded echo %q", line)
+	}
+}
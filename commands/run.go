@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/urfave/cli/v3"
+)
+
+// runSettings units run 的运行参数
+type runSettings struct {
+	workDir string
+	allow   []string
+	stubs   map[string]*FetchStub
+	argv    []string
+}
+
+func runScript() *cli.Command {
+	return &cli.Command{
+		Name:  "run",
+		Usage: "bundle a script in memory and execute it in an embedded JavaScript engine (no Node.js required)",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "watch",
+				Aliases: []string{"w"},
+				Usage:   "rebuild on changes and re-run the script, a running script is interrupted",
+			},
+			&cli.StringSliceFlag{
+				Name:  "allow-fs",
+				Usage: "directories the script may read and write through require('fs'), fs access is denied by default",
+			},
+			&cli.StringFlag{
+				Name:  "fetch-stub",
+				Usage: `json file of stubbed fetch responses keyed by "URL" or "METHOD URL" (trailing * matches a prefix), other requests are rejected`,
+			},
+			&cli.StringSliceFlag{
+				Name:  "define",
+				Usage: "define global constants",
+			},
+			&cli.StringFlag{
+				Name:  "tsconfig",
+				Usage: "path to tsconfig.json",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArgs{
+				Name:      "entry",
+				UsageText: "entry script followed by its arguments, pass -- before arguments starting with -",
+				Min:       1,
+				Max:       -1,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			args := cmd.StringArgs("entry")
+			workDir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			entry := args[0]
+			settings := runSettings{
+				workDir: workDir,
+				allow:   cmd.StringSlice("allow-fs"),
+				argv:    append([]string{"units", filepath.Join(workDir, entry)}, args[1:]...),
+			}
+			if stub := cmd.String("fetch-stub"); stub != "" {
+				if settings.stubs, err = loadFetchStubs(stub); err != nil {
+					return err
+				}
+			}
+			options := runBuildOptions(workDir, entry)
+			options.Define = parseDefines(cmd.StringSlice("define"))
+			options.Tsconfig = cmd.String("tsconfig")
+			if cmd.Bool("watch") {
+				return runScriptWatch(ctx, options, settings)
+			}
+			result := api.Build(options)
+			printMessages(result.Warnings, api.WarningMessage)
+			if len(result.Errors) > 0 {
+				printMessages(result.Errors, api.ErrorMessage)
+				return fmt.Errorf("build failed with %d errors", len(result.Errors))
+			}
+			code, err := executeBundle(ctx, result.OutputFiles, settings)
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				return fmt.Errorf("script exited with code %d", code)
+			}
+			return nil
+		},
+	}
+}
+
+// runBuildOptions 在内存中将脚本打包为运行时可以执行的 iife，fs 由运行时提供
+func runBuildOptions(workDir, entry string) api.BuildOptions {
+	return api.BuildOptions{
+		AbsWorkingDir: workDir,
+		EntryPoints:   []string{entry},
+		// 输出不会写入，只用于确定 source map 中源文件的相对路径
+		Outfile:    filepath.Join(workDir, "units-run.js"),
+		Bundle:     true,
+		Write:      false,
+		Format:     api.FormatIIFE,
+		Platform:   api.PlatformNeutral,
+		MainFields: []string{"module", "main"},
+		Target:     api.ES2017,
+		Sourcemap:  api.SourceMapInline,
+		External:   []string{"fs", "node:fs"},
+		LogLevel:   api.LogLevelSilent,
+	}
+}
+
+// executeBundle 在新的运行时中执行打包结果
+func executeBundle(ctx context.Context, files []api.OutputFile, s runSettings) (int, error) {
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path, ".js") {
+			continue
+		}
+		r, err := newJSRuntime(s.workDir, s.allow, s.stubs, s.argv)
+		if err != nil {
+			return 0, err
+		}
+		return r.run(ctx, f.Path, string(f.Contents))
+	}
+	return 0, fmt.Errorf("build produced no script")
+}
+
+// runScriptWatch 监听变化，每次构建成功后中断正在运行的脚本并重新执行
+func runScriptWatch(ctx context.Context, options api.BuildOptions, s runSettings) error {
+	bundles := make(chan []api.OutputFile, 1)
+	options.Plugins = append(options.Plugins, api.Plugin{
+		Name: "run",
+		Setup: func(build api.PluginBuild) {
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				printMessages(result.Warnings, api.WarningMessage)
+				if len(result.Errors) > 0 {
+					printMessages(result.Errors, api.ErrorMessage)
+					log.Printf("Build failed with %d errors, waiting for changes", len(result.Errors))
+					return api.OnEndResult{}, nil
+				}
+				// 只保留最新的构建结果
+				select {
+				case <-bundles:
+				default:
+				}
+				bundles <- result.OutputFiles
+				return api.OnEndResult{}, nil
+			})
+		},
+	})
+	buildCtx, ctxErr := api.Context(options)
+	if ctxErr != nil {
+		return fmt.Errorf("failed to create build context: %v", ctxErr)
+	}
+	defer func() {
+		buildCtx.Cancel()
+		buildCtx.Dispose()
+	}()
+	if err := buildCtx.Watch(api.WatchOptions{Delay: int(defaultWatchDelay.Milliseconds())}); err != nil {
+		return fmt.Errorf("failed to start watch mode: %v", err)
+	}
+	log.Println("Watching for changes... (press Ctrl+C to stop)")
+
+	var (
+		cancel  context.CancelFunc
+		running chan struct{}
+	)
+	stop := func() {
+		if cancel != nil {
+			cancel()
+			<-running
+			cancel = nil
+		}
+	}
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping watch mode...")
+			return nil
+		case files := <-bundles:
+			stop()
+			runCtx, runCancel := context.WithCancel(ctx)
+			cancel = runCancel
+			running = make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				code, err := executeBundle(runCtx, files, s)
+				switch {
+				case runCtx.Err() != nil:
+				case err != nil:
+					log.Printf("Script failed: %v", err)
+				default:
+					log.Printf("Script exited with code %d", code)
+				}
+			}(running)
+		}
+	}
+}
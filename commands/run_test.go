@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestRunScript(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"src/index.ts": `import fs from 'fs';
+import { greet } from './greet';
+async function main(): Promise<void> {
+  const res = await fetch('https://api.test/users/1');
+  const user: { name: string } = await res.json();
+  console.log(greet(user.name), res.status);
+  fs.writeFileSync('data/out.txt', process.argv.slice(2).join(','));
+  console.log(fs.readFileSync('data/out.txt'));
+  await new Promise((resolve) => setTimeout(resolve, 5));
+  try { fs.readFileSync('src/greet.ts') } catch (e) { console.log('denied') }
+  try { await fetch('https://api.test/other') } catch (e) { console.log('rejected') }
+  let ticks = 0;
+  const id = setInterval(() => { if (++ticks === 2) { clearInterval(id); console.log('ticks', ticks); process.exitCode = 2 } }, 1);
+}
+main();
+`,
+		"src/greet.ts": "export const greet = (name: string): string => `hi ${name}`;\n",
+		"src/fail.ts":  "function fail(): never {\n  throw new Error('boom');\n}\nsetTimeout(fail, 1);\n",
+		"data/.keep":   "",
+	}
+	writeTree(t, dir, files)
+	stubs := map[string]*FetchStub{"https://api.test/users/*": {JSON: json.RawMessage(`{"name":"bob"}`)}}
+	run := func(entry string) (string, int, error) {
+		result := api.Build(runBuildOptions(dir, entry))
+		if len(result.Errors) > 0 {
+			t.Fatal(result.Errors)
+		}
+		r, err := newJSRuntime(dir, []string{"data"}, stubs, []string{"units", entry, "a", "b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		r.stdout = &out
+		code, err := r.run(context.Background(), result.OutputFiles[0].Path, string(result.OutputFiles[0].Contents))
+		return out.String(), code, err
+	}
+
+	out, code, err := run("src/index.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "hi bob 200\na,b\ndenied\nrejected\nticks 2\n"; out != want || code != 2 {
+		t.Errorf("got %q (code %d), want %q (code 2)", out, code, want)
+	}
+
+	_, code, err = run("src/fail.ts")
+	if err == nil || code != 1 || !strings.Contains(err.Error(), "boom") || !strings.Contains(err.Error(), "fail.ts:2") {
+		t.Errorf("expected source mapped uncaught error, got %v (code %d)", err, code)
+	}
+}
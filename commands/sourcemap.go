@@ -0,0 +1,285 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-sourcemap/sourcemap"
+	"github.com/urfave/cli/v3"
+)
+
+func sourcemapCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "sourcemap",
+		Usage: "source map utilities",
+		Commands: []*cli.Command{
+			{
+				Name:  "resolve",
+				Usage: "map a minified stack trace back to original file:line:column with code frames",
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    "map",
+						Aliases: []string{"m"},
+						Usage:   "emitted .map files or folders searched recursively for them (default dist)",
+					},
+					&cli.IntFlag{
+						Name:    "context",
+						Aliases: []string{"C"},
+						Usage:   "lines of source shown around each frame, 0 to disable code frames",
+						Value:   2,
+					},
+				},
+				Arguments: []cli.Argument{
+					&cli.StringArg{
+						Name:      "trace",
+						UsageText: "file containing the stack trace, reads stdin when omitted or -",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					maps := cmd.StringSlice("map")
+					if len(maps) == 0 {
+						maps = []string{"dist"}
+					}
+					resolver, err := loadSourceMaps(maps)
+					if err != nil {
+						return err
+					}
+					var in io.Reader = os.Stdin
+					if name := cmd.StringArg("trace"); name != "" && name != "-" {
+						f, err := os.Open(name)
+						if err != nil {
+							return err
+						}
+						defer f.Close()
+						in = f
+					}
+					return resolver.resolveTrace(in, os.Stdout, int(cmd.Int("context")))
+				},
+			},
+		},
+	}
+}
+
+// sourceMapFile 已加载的 source map，dir 为 .map 文件所在目录，用于定位相对路径的源文件
+type sourceMapFile struct {
+	dir      string
+	consumer *sourcemap.Consumer
+}
+
+// sourceMapResolver 按生成文件名查找 source map
+type sourceMapResolver struct {
+	maps map[string]*sourceMapFile // 生成文件名（不含目录）-> source map
+}
+
+// loadSourceMaps 加载给定的 .map 文件与目录中的所有 .map 文件
+func loadSourceMaps(paths []string) (*sourceMapResolver, error) {
+	r := &sourceMapResolver{maps: make(map[string]*sourceMapFile)}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if err := r.load(p); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		err = filepath.WalkDir(p, func(file string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(file, ".map") {
+				return err
+			}
+			return r.load(file)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(r.maps) == 0 {
+		return nil, fmt.Errorf("no source maps found in %s", strings.Join(paths, ", "))
+	}
+	return r, nil
+}
+
+func (r *sourceMapResolver) load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	consumer, err := sourcemap.Parse("", data)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", file, err)
+	}
+	m := &sourceMapFile{dir: filepath.Dir(file), consumer: consumer}
+	r.maps[strings.TrimSuffix(filepath.Base(file), ".map")] = m
+	if name := consumer.File(); name != "" {
+		r.maps[path.Base(name)] = m
+	}
+	return nil
+}
+
+// traceLocation 匹配栈帧中的位置：路径或 URL 后跟 :行:列
+var traceLocation = regexp.MustCompile(`([^\s()@]+):(\d+):(\d+)`)
+
+// sourceFrame 还原后的位置
+type sourceFrame struct {
+	file   string // 显示用的路径
+	name   string // 原始标识符
+	line   int    // 从 1 开始
+	column int    // 从 1 开始
+	source string // 源码内容，无法获取时为空
+}
+
+// lookup 还原生成文件中的位置，line 与 column 均从 1 开始
+func (r *sourceMapResolver) lookup(location string, line, column int) (*sourceFrame, bool) {
+	name := location
+	if u, err := url.Parse(location); err == nil && u.Path != "" {
+		name = u.Path
+	}
+	m, ok := r.maps[path.Base(filepath.ToSlash(name))]
+	if !ok {
+		return nil, false
+	}
+	source, ident, srcLine, srcColumn, ok := m.consumer.Source(line, column-1)
+	if !ok || source == "" {
+		return nil, false
+	}
+	frame := &sourceFrame{file: source, name: ident, line: srcLine, column: srcColumn + 1, source: m.consumer.SourceContent(source)}
+	// 相对路径的源文件相对 .map 所在目录，本地存在时显示为相对当前目录的路径，否则保持 source map 中的路径
+	if u, err := url.Parse(source); err == nil && u.IsAbs() {
+		return frame, true
+	}
+	file := source
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(m.dir, filepath.FromSlash(source))
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return frame, true
+	}
+	if frame.source == "" {
+		frame.source = string(data)
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, file); err == nil {
+			file = rel
+		}
+	}
+	frame.file = filepath.ToSlash(file)
+	return frame, true
+}
+
+// traceLine 栈中的一行及其中还原的栈帧
+type traceLine struct {
+	text   string
+	frames []*sourceFrame
+}
+
+// resolveTrace 逐行替换栈中的位置，每个还原的栈帧之后输出代码片段
+func (r *sourceMapResolver) resolveTrace(in io.Reader, out io.Writer, around int) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var lines []traceLine
+	for scanner.Scan() {
+		var frames []*sourceFrame
+		text := replaceAllSubmatch(traceLocation, scanner.Text(), func(m []string) string {
+			line, _ := strconv.Atoi(m[2])
+			column, _ := strconv.Atoi(m[3])
+			frame, ok := r.lookup(m[1], line, column)
+			if !ok {
+				return m[0]
+			}
+			frames = append(frames, frame)
+			return fmt.Sprintf("%s:%d:%d", frame.file, frame.line, frame.column)
+		})
+		lines = append(lines, traceLine{text: text, frames: frames})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	// 还原被压缩的函数名：栈帧位置上的名称是该处调用的函数，即上一帧所在的函数，
+	// 因此第 N 帧的函数名取自第 N+1 帧的调用位置，最外层的帧保持原样
+	for i := 0; i+1 < len(lines); i++ {
+		caller := lines[i+1]
+		if len(lines[i].frames) != 1 || len(caller.frames) != 1 || caller.frames[0].name == "" {
+			continue
+		}
+		if traceFunction.MatchString(lines[i].text) && strings.HasPrefix(strings.TrimSpace(caller.text), "at ") {
+			lines[i].text = traceFunction.ReplaceAllString(lines[i].text, "${1}"+caller.frames[0].name+" (")
+		}
+	}
+	w := bufio.NewWriter(out)
+	for _, line := range lines {
+		fmt.Fprintln(w, line.text)
+		if around > 0 {
+			for _, frame := range line.frames {
+				w.WriteString(codeFrame(frame.source, frame.line, frame.column, around))
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// traceFunction 匹配 V8 栈帧中的函数名
+var traceFunction = regexp.MustCompile(`^(\s*at\s+(?:async\s+)?)[\w$.<>]+\s+\(`)
+
+// replaceAllSubmatch 与 ReplaceAllStringFunc 相同，但回调接收子匹配
+func replaceAllSubmatch(re *regexp.Regexp, s string, fn func([]string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, idx := range re.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(s[last:idx[0]])
+		m := make([]string, len(idx)/2)
+		for i := range m {
+			if idx[2*i] >= 0 {
+				m[i] = s[idx[2*i]:idx[2*i+1]]
+			}
+		}
+		b.WriteString(fn(m))
+		last = idx[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// codeFrame 输出源码中 line 前后 around 行，并在列下方标出位置
+func codeFrame(source string, line, column, around int) string {
+	if source == "" {
+		return ""
+	}
+	lines := strings.Split(source, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	start, end := max(line-around, 1), min(line+around, len(lines))
+	width := len(strconv.Itoa(end))
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		marker := "  "
+		if i == line {
+			marker = "> "
+		}
+		fmt.Fprintf(&b, "    %s%*d | %s\n", marker, width, i, strings.TrimRight(lines[i-1], "\r"))
+		if i == line {
+			// 制表符保持原样以对齐
+			pad := []rune(lines[i-1])
+			pad = pad[:min(max(column-1, 0), len(pad))]
+			for j, c := range pad {
+				if c != '\t' {
+					pad[j] = ' '
+				}
+			}
+			fmt.Fprintf(&b, "      %*s | %s^\n", width, "", string(pad))
+		}
+	}
+	return b.String()
+}
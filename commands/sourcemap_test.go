@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestResolveTrace(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.MkdirAll("src", 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := "export function explode(value: number): never {\n  throw new Error('boom ' + value);\n}\nexplode(1);\n"
+	if err := os.WriteFile("src/app.ts", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := api.Build(api.BuildOptions{
+		AbsWorkingDir:     dir,
+		EntryPoints:       []string{"src/app.ts"},
+		Outfile:           "dist/assets/main.js",
+		Bundle:            true,
+		MinifyWhitespace:  true,
+		MinifyIdentifiers: true,
+		Sourcemap:         parseSourceMap("true"),
+		SourcesContent:    api.SourcesContentExclude,
+		Write:             true,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	data, err := os.ReadFile(filepath.Join("dist", "assets", "main.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "//# sourceMappingURL=main.js.map") {
+		t.Fatalf("expected linked source map:\n%s", data)
+	}
+	lines := strings.Split(string(data), "\n")
+	line, column := 0, 0
+	for i, l := range lines {
+		if c := strings.Index(l, "new Error"); c >= 0 {
+			line, column = i+1, c+1
+		}
+	}
+	resolver, err := loadSourceMaps([]string{"dist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := fmt.Sprintf("Error: boom 1\n    at x (https://cdn.test/assets/main.js?v=1:%d:%d)\n    at vendor.js:1:1\n", line, column)
+	var out strings.Builder
+	if err := resolver.resolveTrace(strings.NewReader(trace), &out, 1); err != nil {
+		t.Fatal(err)
+	}
+	// 源码不在 source map 中时从磁盘读取
+	for _, want := range []string{"(src/app.ts:2:9)", "at vendor.js:1:1", "    > 2 |   throw new Error", "      |         ^", "      1 | export function"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestResolveTraceNames(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	source := "function helper(v: number): never {\n  throw new Error('boom ' + v);\n}\nfunction outer(v: number): never {\n  return helper(v);\n}\nouter(1);\n"
+	if err := os.WriteFile("app.ts", []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := api.Build(api.BuildOptions{
+		AbsWorkingDir:     dir,
+		EntryPoints:       []string{"app.ts"},
+		Outfile:           "dist/app.js",
+		Bundle:            true,
+		MinifyWhitespace:  true,
+		MinifyIdentifiers: true,
+		Format:            api.FormatIIFE,
+		Sourcemap:         parseSourceMap("true"),
+		Write:             true,
+	})
+	if len(result.Errors) > 0 {
+		t.Fatal(result.Errors)
+	}
+	data, err := os.ReadFile(filepath.Join("dist", "app.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := strings.Split(string(data), "\n")[0]
+	m := regexp.MustCompile(`function (\w+)\(\w+\)\{throw new Error.*function (\w+)\(\w+\)\{return (\w+)\(`).FindStringSubmatch(code)
+	if m == nil || m[1] != m[3] {
+		t.Fatalf("unexpected output:\n%s", code)
+	}
+	helper, outer := m[1], m[2]
+	column := func(s string) int {
+		c := strings.Index(code, s)
+		if c < 0 {
+			t.Fatalf("%q not found in:\n%s", s, code)
+		}
+		return c + 1
+	}
+	// V8 报告 throw 处的 new 与调用处的被调用函数
+	trace := fmt.Sprintf("Error: boom 1\n    at %s (dist/app.js:1:%d)\n    at %s (dist/app.js:1:%d)\n    at dist/app.js:1:%d\n",
+		helper, column("new Error"), outer, column("return "+helper+"(")+len("return "), column(outer+"(1)"))
+	resolver, err := loadSourceMaps([]string{"dist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := resolver.resolveTrace(strings.NewReader(trace), &out, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := "Error: boom 1\n    at helper (app.ts:2:9)\n    at outer (app.ts:5:10)\n    at app.ts:7:1\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
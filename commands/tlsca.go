@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/urfave/cli/v3"
+)
+
+// maxLeafCerts 按需签发的证书数量上限
+const maxLeafCerts = 32
+
+// localCA 本地开发用的根证书，首次使用时生成并保存，用于签发 httpd --tls 的服务器证书
+type localCA struct {
+	cert    *x509.Certificate
+	key     crypto.Signer
+	certPEM []byte
+	file    string // 根证书文件
+
+	mu     sync.Mutex
+	leaves map[string]*tls.Certificate // 为 *.localhost 按需签发的证书，以 SNI 主机名为键
+}
+
+func httpdCA() *Command {
+	return &Command{
+		Name:  "ca",
+		Usage: "local certificate authority used by httpd --tls",
+		Commands: []*Command{
+			{
+				Name:  "export",
+				Usage: "print the root certificate (PEM) so it can be added to the trust store of browsers and teammates' machines",
+				Action: func(ctx context.Context, cmd *Command) error {
+					dir, err := localCADir()
+					if err != nil {
+						return err
+					}
+					ca, err := loadLocalCA(dir)
+					if err != nil {
+						return err
+					}
+					log.Printf("Root certificate %s, fingerprint SHA-256 %s", ca.file, certFingerprint(ca.cert))
+					_, err = os.Stdout.Write(ca.certPEM)
+					return err
+				},
+			},
+		},
+	}
+}
+
+// localCADir 根证书保存在用户配置目录下的 units/ca
+func localCADir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config dir: %v", err)
+	}
+	return filepath.Join(dir, "units", "ca"), nil
+}
+
+// loadLocalCA 读取根证书与私钥，不存在时生成
+func loadLocalCA(dir string) (*localCA, error) {
+	certFile, keyFile := filepath.Join(dir, "rootCA.pem"), filepath.Join(dir, "rootCA-key.pem")
+	certPEM, err := os.ReadFile(certFile)
+	if os.IsNotExist(err) {
+		return createLocalCA(certFile, keyFile)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", certFile, err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", keyFile, err)
+	}
+	ca := &localCA{certPEM: certPEM, file: certFile, leaves: make(map[string]*tls.Certificate)}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("invalid certificate %s", certFile)
+	}
+	if ca.cert, err = x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, fmt.Errorf("invalid certificate %s: %v", certFile, err)
+	}
+	block, _ = pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("invalid private key %s", keyFile)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key %s: %v", keyFile, err)
+	}
+	var ok bool
+	if ca.key, ok = key.(crypto.Signer); !ok {
+		return nil, fmt.Errorf("unsupported private key %s", keyFile)
+	}
+	// 已被信任的根证书不自动替换，过期后需要手动删除目录重新生成
+	if time.Now().After(ca.cert.NotAfter) {
+		return nil, fmt.Errorf("local CA %s expired at %s, remove %s to create a new one", certFile, ca.cert.NotAfter.Format(time.DateOnly), dir)
+	}
+	return ca, nil
+}
+
+// createLocalCA 生成有效期十年的根证书，私钥只对当前用户可读
+func createLocalCA(certFile, keyFile string) (*localCA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	name := "units local development CA"
+	if host, err := os.Hostname(); err == nil {
+		name += " " + host
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"units development CA"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(certFile), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Created local CA %s, trust it in browsers (see units httpd ca export)", certFile)
+	return &localCA{cert: cert, key: key, certPEM: certPEM, file: certFile, leaves: make(map[string]*tls.Certificate)}, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// certFingerprint 证书的 SHA-256 指纹
+func certFingerprint(cert *x509.Certificate) string {
+	hex := fmt.Sprintf("%X", sha256.Sum256(cert.Raw))
+	parts := make([]string, 0, len(hex)/2)
+	for i := 0; i < len(hex); i += 2 {
+		parts = append(parts, hex[i:i+2])
+	}
+	return strings.Join(parts, ":")
+}
+
+// issue 签发覆盖给定主机名与 IP 的服务器证书
+func (ca *localCA) issue(hosts []string) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"units development certificate"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// 浏览器不接受有效期超过 398 天的服务器证书
+		NotAfter:    time.Now().AddDate(0, 0, 397),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Certificate{Certificate: [][]byte{der, ca.cert.Raw}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// tlsConfig 服务器证书覆盖 hosts，*.localhost 的子域名按需签发，其他名称使用默认证书
+func (ca *localCA) tlsConfig(hosts []string) (*tls.Config, error) {
+	cert, err := ca.issue(hosts)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+			// 只为 *.localhost 按需签发，其他名称使用默认证书，避免局域网中的任意客户端让服务签发证书
+			if name == "" || cert.Leaf.VerifyHostname(name) == nil || !strings.HasSuffix(name, ".localhost") {
+				return cert, nil
+			}
+			ca.mu.Lock()
+			defer ca.mu.Unlock()
+			if leaf, ok := ca.leaves[name]; ok {
+				return leaf, nil
+			}
+			if len(ca.leaves) >= maxLeafCerts {
+				return cert, nil
+			}
+			leaf, err := ca.issue([]string{name})
+			if err != nil {
+				return nil, err
+			}
+			ca.leaves[name] = leaf
+			return leaf, nil
+		},
+	}, nil
+}
+
+// localHosts 本机的主机名：localhost、回环地址、主机名、局域网 IP 以及额外指定的名称
+func localHosts(extra []string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, strings.ToLower(name))
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if n, ok := addr.(*net.IPNet); ok && !n.IP.IsLoopback() && !n.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, n.IP.String())
+			}
+		}
+	}
+	hosts = append(hosts, extra...)
+	seen := make(map[string]bool, len(hosts))
+	unique := hosts[:0]
+	for _, h := range hosts {
+		if !seen[h] {
+			seen[h] = true
+			unique = append(unique, h)
+		}
+	}
+	return unique
+}
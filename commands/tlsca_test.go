@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestLocalCA(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := loadLocalCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := loadLocalCA(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.cert.Equal(ca.cert) {
+		t.Fatal("expected the stored CA to be reused")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(again.certPEM) {
+		t.Fatal("invalid CA PEM")
+	}
+	config, err := again.tlsConfig(localHosts([]string{"dev.test"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"localhost", "dev.test", "app.localhost"} {
+		cert, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: pool}); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	// 未配置的名称使用默认证书，不按需签发
+	if cert, _ := config.GetCertificate(&tls.ClientHelloInfo{ServerName: "evil.test"}); cert.Leaf.VerifyHostname("evil.test") == nil {
+		t.Error("issued a certificate for an unconfigured host")
+	}
+	for i := range maxLeafCerts + 8 {
+		if _, err := config.GetCertificate(&tls.ClientHelloInfo{ServerName: fmt.Sprintf("app%d.localhost", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(again.leaves) > maxLeafCerts {
+		t.Errorf("certificate cache grew to %d", len(again.leaves))
+	}
+	cert, _ := config.GetCertificate(&tls.ClientHelloInfo{})
+	if _, err := cert.Leaf.Verify(x509.VerifyOptions{DNSName: "127.0.0.1", Roots: pool}); err != nil {
+		t.Errorf("127.0.0.1: %v", err)
+	}
+
+	// 与 httpd 相同，使用 ListenAndServeTLS("", "") 的方式只依赖 TLSConfig
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{TLSConfig: config, Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})}
+	go server.ServeTLS(ln, "", "")
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool},
+		ForceAttemptHTTP2: true,
+	}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "HTTP/2.0" {
+		t.Errorf("expected HTTP/2, got %q", body)
+	}
+}
@@ -0,0 +1,388 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/urfave/cli/v3"
+)
+
+func transform() *cli.Command {
+	return &cli.Command{
+		Name:  "transform",
+		Usage: "transform single files with esbuild Transform API (no bundling)",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "loader",
+				Usage: "loader of the input (js, jsx, ts, tsx, css, json ...), detected from file extension by default",
+			},
+			&cli.StringFlag{
+				Name:  "target",
+				Usage: "comma separated targets (e.g. es2020,chrome90,node18) or browserslist query",
+			},
+			&cli.StringFlag{
+				Name:  "format",
+				Usage: "output format (iife, cjs, esm)",
+			},
+			&cli.StringFlag{
+				Name:  "platform",
+				Usage: "platform target (browser, node, neutral)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "define",
+				Usage: "define global constants",
+			},
+			&cli.StringFlag{
+				Name:  "jsx",
+				Usage: "jsx mode (transform, preserve, automatic)",
+			},
+			&cli.StringFlag{
+				Name:  "jsx-factory",
+				Usage: "jsx factory function",
+			},
+			&cli.StringFlag{
+				Name:  "jsx-fragment",
+				Usage: "jsx fragment function",
+			},
+			&cli.StringFlag{
+				Name:  "jsx-import-source",
+				Usage: "jsx import source",
+			},
+			&cli.BoolFlag{
+				Name:  "jsx-dev",
+				Usage: "enable jsx dev mode",
+			},
+			&cli.BoolFlag{
+				Name:    "minify",
+				Aliases: []string{"m"},
+				Usage:   "minify output (sets all minify options)",
+			},
+			&cli.BoolFlag{
+				Name:  "minify-whitespace",
+				Usage: "minify whitespace",
+			},
+			&cli.BoolFlag{
+				Name:  "minify-identifiers",
+				Usage: "minify identifiers",
+			},
+			&cli.BoolFlag{
+				Name:  "minify-syntax",
+				Usage: "minify syntax",
+			},
+			&cli.BoolFlag{
+				Name:  "keep-names",
+				Usage: "keep function and class names",
+			},
+			&cli.StringFlag{
+				Name:  "sourcemap",
+				Usage: "generate source maps (true, linked, inline, external, both), true is linked",
+			},
+			&cli.StringFlag{
+				Name:    "glob",
+				Aliases: []string{"g"},
+				Usage:   "transform every file matching the pattern (e.g. src/**/*.{ts,tsx}) in parallel",
+			},
+			&cli.StringFlag{
+				Name:    "outdir",
+				Aliases: []string{"d"},
+				Usage:   "output directory, the directory structure below the glob base is preserved",
+			},
+			&cli.BoolFlag{
+				Name:    "write",
+				Aliases: []string{"w"},
+				Usage:   "write output alongside each input instead of stdout",
+			},
+			&cli.StringFlag{
+				Name:  "out-ext",
+				Usage: "extension of written outputs (default .js, .css for css)",
+			},
+			&cli.IntFlag{
+				Name:  "jobs",
+				Usage: "number of parallel transforms",
+				Value: runtime.NumCPU(),
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArgs{
+				Name:      "file",
+				UsageText: "input files, stdin when omitted",
+				Min:       0,
+				Max:       -1,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			options := api.TransformOptions{
+				Format:          parseFormat(cmd.String("format")),
+				JSX:             parseJSX(cmd.String("jsx")),
+				JSXFactory:      cmd.String("jsx-factory"),
+				JSXFragment:     cmd.String("jsx-fragment"),
+				JSXImportSource: cmd.String("jsx-import-source"),
+				JSXDev:          cmd.Bool("jsx-dev"),
+				Define:          parseDefines(cmd.StringSlice("define")),
+				KeepNames:       cmd.Bool("keep-names"),
+				Sourcemap:       parseSourceMap(cmd.String("sourcemap")),
+			}
+			if !cmd.IsSet("format") {
+				options.Format = api.FormatDefault
+			}
+			// 转换接口没有 linked，按 external 生成，写入 .map 文件时由 runJob 追加引用注释
+			linked := options.Sourcemap == api.SourceMapLinked
+			if linked {
+				options.Sourcemap = api.SourceMapExternal
+			}
+			if cmd.IsSet("platform") {
+				options.Platform = parsePlatform(cmd.String("platform"))
+			}
+			if cmd.IsSet("target") {
+				var err error
+				if options.Target, options.Engines, err = parseTargets(cmd.String("target"), "."); err != nil {
+					return err
+				}
+			}
+			minify := cmd.Bool("minify")
+			options.MinifyWhitespace = minify || cmd.Bool("minify-whitespace")
+			options.MinifyIdentifiers = minify || cmd.Bool("minify-identifiers")
+			options.MinifySyntax = minify || cmd.Bool("minify-syntax")
+			t := &transformer{
+				options: options,
+				loader:  cmd.String("loader"),
+				outExt:  cmd.String("out-ext"),
+				linked:  linked,
+			}
+
+			files := cmd.StringArgs("file")
+			pattern := cmd.String("glob")
+			outdir := cmd.String("outdir")
+			toStdout := outdir == "" && !cmd.Bool("write")
+			if toStdout && t.separateMap() {
+				return fmt.Errorf("--sourcemap %s writes a .map file, use --outdir or --write, or --sourcemap inline", cmd.String("sourcemap"))
+			}
+			if len(files) == 0 && pattern == "" {
+				// 从标准输入读取，输出到标准输出
+				input, err := io.ReadAll(os.Stdin)
+				if err != nil {
+					return fmt.Errorf("failed to read stdin: %v", err)
+				}
+				code, _, err := t.transform(string(input), "<stdin>")
+				if err != nil {
+					return err
+				}
+				_, err = cmd.Root().Writer.Write([]byte(code))
+				return err
+			}
+
+			var jobs []transformJob
+			for _, file := range files {
+				jobs = append(jobs, transformJob{input: file, base: filepath.Dir(file)})
+			}
+			if pattern != "" {
+				matched, err := expandGlob(pattern)
+				if err != nil {
+					return fmt.Errorf("failed to expand %s: %v", pattern, err)
+				}
+				base := globBase(pattern)
+				for _, file := range matched {
+					jobs = append(jobs, transformJob{input: file, base: base})
+				}
+			}
+			if toStdout {
+				if pattern != "" {
+					return fmt.Errorf("--glob requires --outdir or --write")
+				}
+				// 逐个输出到标准输出
+				for _, job := range jobs {
+					data, err := os.ReadFile(job.input)
+					if err != nil {
+						return fmt.Errorf("failed to read %s: %v", job.input, err)
+					}
+					code, _, err := t.transform(string(data), job.input)
+					if err != nil {
+						return err
+					}
+					if _, err = cmd.Root().Writer.Write([]byte(code)); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			for i := range jobs {
+				jobs[i].output = t.outputPath(jobs[i].input, jobs[i].base, outdir)
+			}
+			return t.run(ctx, jobs, cmd.Int("jobs"))
+		},
+	}
+}
+
+// transformJob 单个文件的转换任务
+type transformJob struct {
+	input  string
+	base   string // 计算输出相对路径的基准目录
+	output string
+}
+
+// transformer 使用相同选项转换多个文件
+type transformer struct {
+	options api.TransformOptions
+	loader  string
+	outExt  string
+	linked  bool // 在输出中追加 .map 文件的引用注释
+}
+
+// separateMap 是否生成单独的 .map 文件
+func (t *transformer) separateMap() bool {
+	return t.options.Sourcemap == api.SourceMapExternal || t.options.Sourcemap == api.SourceMapInlineAndExternal
+}
+
+// loaderFor 确定文件的加载器，未指定时根据扩展名推断
+func (t *transformer) loaderFor(file string) api.Loader {
+	if t.loader != "" {
+		return parseLoader(t.loader)
+	}
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".mjs", ".cjs":
+		return api.LoaderJS
+	case ".mts", ".cts":
+		return api.LoaderTS
+	case "":
+		return api.LoaderJS
+	default:
+		if loader := parseLoader(ext[1:]); loader != api.LoaderNone {
+			return loader
+		}
+		return api.LoaderJS
+	}
+}
+
+// outputPath 计算输出文件路径，outdir 为空时写在输入文件旁边
+func (t *transformer) outputPath(input, base, outdir string) string {
+	ext := t.outExt
+	if ext == "" {
+		ext = ".js"
+		if loader := t.loaderFor(input); loader == api.LoaderCSS || loader == api.LoaderLocalCSS || loader == api.LoaderGlobalCSS {
+			ext = ".css"
+		}
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	name := strings.TrimSuffix(input, filepath.Ext(input)) + ext
+	if outdir == "" {
+		return name
+	}
+	rel, err := filepath.Rel(base, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(name)
+	}
+	return filepath.Join(outdir, rel)
+}
+
+// transform 转换一段代码，返回代码与外部 source map
+func (t *transformer) transform(code, file string) (string, string, error) {
+	options := t.options
+	options.Loader = t.loaderFor(file)
+	options.Sourcefile = file
+	result := api.Transform(code, options)
+	for _, warn := range result.Warnings {
+		log.Printf("Warning: %s", formatMessage(warn))
+	}
+	if len(result.Errors) > 0 {
+		for _, err := range result.Errors {
+			log.Printf("Error: %s", formatMessage(err))
+		}
+		return "", "", fmt.Errorf("transform %s failed with %d errors", file, len(result.Errors))
+	}
+	return string(result.Code), string(result.Map), nil
+}
+
+// run 并行执行转换任务
+func (t *transformer) run(ctx context.Context, jobs []transformJob, workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	queue := make(chan transformJob)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				if err := t.runJob(job); err != nil {
+					log.Printf("Error: %v", err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, job := range jobs {
+		select {
+		case queue <- job:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to transform", failed, len(jobs))
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	log.Printf("Transformed %d files", len(jobs))
+	return nil
+}
+
+func (t *transformer) runJob(job transformJob) error {
+	if filepath.Clean(job.output) == filepath.Clean(job.input) {
+		return fmt.Errorf("refusing to overwrite %s, use --out-ext or --outdir", job.input)
+	}
+	data, err := os.ReadFile(job.input)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", job.input, err)
+	}
+	code, sourcemap, err := t.transform(string(data), job.input)
+	if err != nil {
+		return err
+	}
+	if sourcemap != "" && t.linked {
+		url := filepath.Base(job.output) + ".map"
+		if strings.HasSuffix(job.output, ".css") {
+			code += "/*# sourceMappingURL=" + url + " */\n"
+		} else {
+			code += "//# sourceMappingURL=" + url + "\n"
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(job.output), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	if err := os.WriteFile(job.output, []byte(code), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", job.output, err)
+	}
+	if sourcemap != "" {
+		if err := os.WriteFile(job.output+".map", []byte(sourcemap), 0644); err != nil {
+			return fmt.Errorf("failed to write %s.map: %v", job.output, err)
+		}
+	}
+	return nil
+}
+
+// formatMessage 格式化 esbuild 消息，带上文件位置
+func formatMessage(msg api.Message) string {
+	if msg.Location == nil {
+		return msg.Text
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", msg.Location.File, msg.Location.Line, msg.Location.Column, msg.Text)
+}
@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestTransformer(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.ts"), []byte(`const a: number = 1; export default a;`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "b.css"), []byte(`.a { color: red }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tr := &transformer{options: api.TransformOptions{MinifyWhitespace: true}}
+	files, err := expandGlob(filepath.Join(src, "**", "*.{ts,css}"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expandGlob: %v %v", files, err)
+	}
+	out := filepath.Join(dir, "out")
+	var jobs []transformJob
+	for _, file := range files {
+		jobs = append(jobs, transformJob{input: file, output: tr.outputPath(file, src, out)})
+	}
+	if err := tr.run(t.Context(), jobs, 2); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "sub", "a.js"))
+	if err != nil || strings.Contains(string(data), "number") {
+		t.Errorf("unexpected ts output %q: %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "b.css")); err != nil {
+		t.Errorf("missing css output: %v", err)
+	}
+	if err := tr.runJob(transformJob{input: files[0], output: files[0]}); err == nil {
+		t.Errorf("expected refusal to overwrite input")
+	}
+}
+
+func TestTransformerSourcemap(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "a.ts")
+	writeTree(t, dir, map[string]string{"a.ts": "export const a: number = 1;"})
+	for _, c := range []struct {
+		name    string
+		linked  bool
+		comment bool
+	}{{"external", false, false}, {"linked", true, true}} {
+		tr := &transformer{options: api.TransformOptions{Sourcemap: api.SourceMapExternal}, linked: c.linked}
+		output := filepath.Join(dir, c.name, "a.js")
+		if err := tr.runJob(transformJob{input: input, output: output}); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "sourceMappingURL=a.js.map"); got != c.comment {
+			t.Errorf("%s: sourceMappingURL comment %v, want %v:\n%s", c.name, got, c.comment, data)
+		}
+		if _, err := os.Stat(output + ".map"); err != nil {
+			t.Errorf("%s: missing map: %v", c.name, err)
+		}
+	}
+}
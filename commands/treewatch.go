@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// treeWatcher 递归监听目录，新建的目录自动加入，删除与移走的目录自动移除，
+// 创建、修改、删除与重命名都视为变化，一段时间内的变化合并后发送
+type treeWatcher struct {
+	watcher *fsnotify.Watcher
+	root    string
+	ignore  *ignoreMatcher
+	match   func(name string) bool // 需要通知的文件
+	delay   time.Duration
+	dirs    map[string]bool
+	changes chan []string // 合并后发生变化的文件，按路径排序
+}
+
+// newTreeWatcher 监听 root 下未被忽略的目录，patterns 为 .gitignore 形式的忽略规则
+func newTreeWatcher(root string, patterns []string, delay time.Duration, match func(name string) bool) (*treeWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %v", err)
+	}
+	if delay <= 0 {
+		delay = defaultWatchDelay
+	}
+	w := &treeWatcher{
+		watcher: watcher,
+		root:    root,
+		ignore:  newIgnoreMatcher(root, patterns),
+		match:   match,
+		delay:   delay,
+		dirs:    make(map[string]bool),
+		changes: make(chan []string, 1),
+	}
+	if err := w.addTree(root, nil); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch directory: %v", err)
+	}
+	go w.loop()
+	return w, nil
+}
+
+// addTree 加入目录及其子目录，pending 不为空时记录其中已有的文件，
+// 用于发现目录加入监听之前写入的文件
+func (w *treeWatcher) addTree(root string, pending map[string]bool) error {
+	return filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			// 遍历期间被删除的文件
+			if os.IsNotExist(err) && p != root {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			if pending != nil && !w.ignore.ignored(p, false) && w.match(p) {
+				pending[p] = true
+			}
+			return nil
+		}
+		if w.ignore.ignored(p, true) {
+			return filepath.SkipDir
+		}
+		w.ignore.load(p)
+		if w.dirs[p] {
+			return nil
+		}
+		if err := w.watcher.Add(p); err != nil {
+			return err
+		}
+		w.dirs[p] = true
+		return nil
+	})
+}
+
+// removeTree 移除目录及其子目录的监听
+func (w *treeWatcher) removeTree(root string) {
+	prefix := root + string(filepath.Separator)
+	for dir := range w.dirs {
+		if dir == root || strings.HasPrefix(dir, prefix) {
+			// 已删除的目录由系统移除，忽略错误
+			_ = w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+}
+
+// reloadIgnore .gitignore 变化后重新加载规则，移除新忽略的目录并加入不再忽略的目录
+func (w *treeWatcher) reloadIgnore(dir string) {
+	w.ignore.load(dir)
+	for d := range w.dirs {
+		if d != dir && strings.HasPrefix(d, dir+string(filepath.Separator)) && w.ignore.ignored(d, true) {
+			w.removeTree(d)
+		}
+	}
+	if err := w.addTree(dir, nil); err != nil {
+		log.Printf("Watcher error: %v", err)
+	}
+}
+
+// handle 处理一个事件，需要通知的文件记录到 pending，返回是否有变化
+func (w *treeWatcher) handle(event fsnotify.Event, pending map[string]bool) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	name := event.Name
+	if filepath.Base(name) == ".gitignore" {
+		w.reloadIgnore(filepath.Dir(name))
+		return false
+	}
+	if w.dirs[name] && event.Has(fsnotify.Remove|fsnotify.Rename) {
+		// 目录被删除或移走，其中的页面与资源随之失效
+		w.removeTree(name)
+		pending[name] = true
+		return true
+	}
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Lstat(name); err == nil && info.IsDir() {
+			if w.ignore.ignored(name, true) {
+				return false
+			}
+			before := len(pending)
+			if err := w.addTree(name, pending); err != nil {
+				log.Printf("Watcher error: %v", err)
+			}
+			return len(pending) > before
+		}
+	}
+	if w.ignore.ignored(name, false) || !w.match(name) {
+		return false
+	}
+	pending[name] = true
+	return true
+}
+
+func (w *treeWatcher) loop() {
+	defer close(w.changes)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	pending := make(map[string]bool)
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			// 每次变化重新计时，编辑器的连续写入与替换保存合并为一次通知
+			if w.handle(event, pending) {
+				timer.Reset(w.delay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watcher error: %v", err)
+		case <-timer.C:
+			files := make([]string, 0, len(pending))
+			for name := range pending {
+				files = append(files, name)
+			}
+			sort.Strings(files)
+			select {
+			case w.changes <- files:
+				clear(pending)
+			default:
+				// 上一批尚未处理，稍后与新的变化一起发送
+				timer.Reset(w.delay)
+			}
+		}
+	}
+}
+
+func (w *treeWatcher) close() {
+	_ = w.watcher.Close()
+}
@@ -0,0 +1,131 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":      "# build output\n/dist\n*.log\ntmp/\n!keep.log\n",
+		"docs/.gitignore": "draft-*.html\n",
+	})
+	m := newIgnoreMatcher(root, []string{"cache/**"})
+	m.load(root)
+	m.load(filepath.Join(root, "docs"))
+	cases := []struct {
+		name    string
+		dir     bool
+		ignored bool
+	}{
+		{"dist", true, true},
+		{"src/dist", true, false},
+		{"logs/app.log", false, true},
+		{"keep.log", false, false},
+		{"tmp", true, true},
+		{"tmp", false, false},
+		{"a/node_modules", true, true},
+		{".git", true, true},
+		{".well-known", true, false},
+		{"cache/x.html", false, true},
+		{"docs/draft-1.html", false, true},
+		{"draft-1.html", false, false},
+		{".", true, false},
+	}
+	for _, c := range cases {
+		if got := m.ignored(filepath.Join(root, filepath.FromSlash(c.name)), c.dir); got != c.ignored {
+			t.Errorf("%s (dir %v): ignored %v, want %v", c.name, c.dir, got, c.ignored)
+		}
+	}
+}
+
+func TestTreeWatcher(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("build/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newTreeWatcher(root, nil, 50*time.Millisecond, func(name string) bool {
+		return strings.HasSuffix(name, ".html") || strings.HasSuffix(name, ".css")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.close()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	next := func() string {
+		t.Helper()
+		select {
+		case files := <-w.changes:
+			rel := make([]string, len(files))
+			for i, f := range files {
+				r, _ := filepath.Rel(root, f)
+				rel[i] = filepath.ToSlash(r)
+			}
+			return strings.Join(rel, ",")
+		case <-time.After(2 * time.Second):
+			t.Fatal("no changes reported")
+		}
+		return ""
+	}
+
+	// 连续的修改合并为一次通知，忽略的目录与不匹配的文件不通知
+	write("index.html", "1")
+	write("index.html", "2")
+	write("build/out.html", "x")
+	write("notes.txt", "x")
+	write("app.css", "x")
+	if got := next(); got != "app.css,index.html" {
+		t.Errorf("unexpected changes %s", got)
+	}
+
+	// 新建的目录被监听，其中已有的文件同样通知
+	if err := os.MkdirAll(filepath.Join(root, "pages", "blog"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write("pages/blog/post.html", "x")
+	if got := next(); got != "pages/blog/post.html" {
+		t.Errorf("unexpected changes %s", got)
+	}
+	write("pages/blog/post.html", "y")
+	if got := next(); got != "pages/blog/post.html" {
+		t.Errorf("unexpected changes %s", got)
+	}
+
+	// 编辑器写入临时文件后替换
+	write("pages/tmp~", "z")
+	if err := os.Rename(filepath.Join(root, "pages", "tmp~"), filepath.Join(root, "pages", "about.html")); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); got != "pages/about.html" {
+		t.Errorf("unexpected changes %s", got)
+	}
+
+	// 删除目录后移除监听
+	if err := os.RemoveAll(filepath.Join(root, "pages")); err != nil {
+		t.Fatal(err)
+	}
+	if got := next(); !strings.Contains(got, "pages") {
+		t.Errorf("unexpected changes %s", got)
+	}
+	// 等待监听循环退出后检查
+	w.close()
+	for range w.changes {
+	}
+	for dir := range w.dirs {
+		if strings.HasPrefix(dir, filepath.Join(root, "pages")) {
+			t.Errorf("removed directory %s still watched", dir)
+		}
+	}
+}
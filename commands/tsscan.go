@@ -0,0 +1,370 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// tsTokenKind TypeScript 记号类型
+type tsTokenKind uint8
+
+const (
+	tsEOF tsTokenKind = iota
+	tsIdent
+	tsNumber
+	tsString // 字符串，tsx 中的 JSX 元素也作为字符串处理
+	tsTemplate
+	tsRegexp
+	tsPunct
+)
+
+// tsToken TypeScript 记号，只用于生成声明，不区分多字符运算符（=> 与 ... 除外）
+type tsToken struct {
+	kind tsTokenKind
+	text string
+	pos  int
+	end  int
+	nl   bool   // 与上一个记号之间有换行
+	doc  string // 紧邻的 /** */ 注释
+}
+
+// tsError 源码中某个位置的错误
+type tsError struct {
+	pos  int
+	text string
+}
+
+func (e *tsError) Error() string {
+	return e.text
+}
+
+// tsRegexpKeywords 其后的 / 为正则表达式的关键字
+var tsRegexpKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true, "new": true, "delete": true,
+	"void": true, "throw": true, "case": true, "do": true, "else": true, "yield": true, "await": true,
+}
+
+// tsScanner TypeScript 记号扫描器
+type tsScanner struct {
+	src  string
+	pos  int
+	jsx  bool
+	last tsToken
+}
+
+// scanTS 将源码拆分为记号，最后一个记号为 tsEOF
+func scanTS(src string, jsx bool) ([]tsToken, error) {
+	s := &tsScanner{src: src, jsx: jsx}
+	s.pos = len(src) - len(strings.TrimPrefix(src, "\ufeff"))
+	if strings.HasPrefix(src[s.pos:], "#!") {
+		if i := strings.IndexByte(src[s.pos:], '\n'); i >= 0 {
+			s.pos += i
+		} else {
+			s.pos = len(src)
+		}
+	}
+	var toks []tsToken
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		toks = append(toks, tok)
+		if tok.kind == tsEOF {
+			return toks, nil
+		}
+	}
+}
+
+func (s *tsScanner) errorf(pos int, format string, args ...interface{}) error {
+	return &tsError{pos: pos, text: fmt.Sprintf(format, args...)}
+}
+
+func isTSIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (s *tsScanner) next() (tsToken, error) {
+	var tok tsToken
+skip:
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\n':
+			tok.nl = true
+			s.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			s.pos++
+		case strings.HasPrefix(s.src[s.pos:], "\u00a0"), strings.HasPrefix(s.src[s.pos:], "\u2028"), strings.HasPrefix(s.src[s.pos:], "\u2029"):
+			_, size := utf8.DecodeRuneInString(s.src[s.pos:])
+			s.pos += size
+		case strings.HasPrefix(s.src[s.pos:], "//"):
+			if i := strings.IndexByte(s.src[s.pos:], '\n'); i >= 0 {
+				s.pos += i
+			} else {
+				s.pos = len(s.src)
+			}
+		case strings.HasPrefix(s.src[s.pos:], "/*"):
+			i := strings.Index(s.src[s.pos+2:], "*/")
+			if i < 0 {
+				return tok, s.errorf(s.pos, "unterminated comment")
+			}
+			comment := s.src[s.pos : s.pos+i+4]
+			if strings.HasPrefix(comment, "/**") && comment != "/**/" {
+				tok.doc = comment
+			}
+			if strings.Contains(comment, "\n") {
+				tok.nl = true
+			}
+			s.pos += i + 4
+		default:
+			break skip
+		}
+	}
+	tok.pos = s.pos
+	if s.pos >= len(s.src) {
+		tok.kind, tok.end = tsEOF, s.pos
+		return tok, nil
+	}
+	c := s.src[s.pos]
+	switch {
+	case isTSIdentChar(c) && !isDigit(c) || c == '#' && s.pos+1 < len(s.src) && isTSIdentChar(s.src[s.pos+1]):
+		s.pos++
+		for s.pos < len(s.src) && isTSIdentChar(s.src[s.pos]) {
+			s.pos++
+		}
+		tok.kind = tsIdent
+	case isDigit(c) || c == '.' && s.pos+1 < len(s.src) && isDigit(s.src[s.pos+1]):
+		hex := len(s.src) > s.pos+1 && c == '0' && (s.src[s.pos+1] == 'x' || s.src[s.pos+1] == 'X')
+		s.pos++
+		for s.pos < len(s.src) {
+			d := s.src[s.pos]
+			if isTSIdentChar(d) || d == '.' {
+				s.pos++
+			} else if (d == '+' || d == '-') && !hex && (s.src[s.pos-1] == 'e' || s.src[s.pos-1] == 'E') {
+				s.pos++
+			} else {
+				break
+			}
+		}
+		tok.kind = tsNumber
+	case c == '"' || c == '\'':
+		if err := s.scanString(c); err != nil {
+			return tok, err
+		}
+		tok.kind = tsString
+	case c == '`':
+		if err := s.scanTemplate(); err != nil {
+			return tok, err
+		}
+		tok.kind = tsTemplate
+	case c == '/' && s.regexpAllowed() && s.scanRegexp():
+		tok.kind = tsRegexp
+	case c == '<' && s.jsx && s.regexpAllowed() && s.looksLikeJSX():
+		if err := s.scanJSXElement(); err != nil {
+			return tok, err
+		}
+		tok.kind = tsString
+	default:
+		tok.kind = tsPunct
+		switch {
+		case strings.HasPrefix(s.src[s.pos:], "=>"):
+			s.pos += 2
+		case strings.HasPrefix(s.src[s.pos:], "..."):
+			s.pos += 3
+		default:
+			s.pos++
+		}
+	}
+	tok.end = s.pos
+	tok.text = s.src[tok.pos:tok.end]
+	s.last = tok
+	return tok, nil
+}
+
+// regexpAllowed 根据上一个记号判断 / 或 < 是否位于表达式开始处
+func (s *tsScanner) regexpAllowed() bool {
+	switch s.last.kind {
+	case tsEOF:
+		return true
+	case tsIdent:
+		return tsRegexpKeywords[s.last.text]
+	case tsPunct:
+		return s.last.text != ")" && s.last.text != "]" && s.last.text != "}"
+	}
+	return false
+}
+
+func (s *tsScanner) scanString(quote byte) error {
+	start := s.pos
+	s.pos++
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '\\':
+			s.pos += 2
+		case quote:
+			s.pos++
+			return nil
+		case '\n':
+			return s.errorf(start, "unterminated string literal")
+		default:
+			s.pos++
+		}
+	}
+	return s.errorf(start, "unterminated string literal")
+}
+
+// scanTemplate 扫描模板字符串，${} 中的表达式递归扫描
+func (s *tsScanner) scanTemplate() error {
+	start := s.pos
+	s.pos++
+	for s.pos < len(s.src) {
+		switch {
+		case s.src[s.pos] == '\\':
+			s.pos += 2
+		case s.src[s.pos] == '`':
+			s.pos++
+			return nil
+		case strings.HasPrefix(s.src[s.pos:], "${"):
+			s.pos += 2
+			if err := s.scanExpression(); err != nil {
+				return err
+			}
+		default:
+			s.pos++
+		}
+	}
+	return s.errorf(start, "unterminated template literal")
+}
+
+// scanExpression 扫描 { 之后的表达式直到对应的 }
+func (s *tsScanner) scanExpression() error {
+	start := s.pos
+	s.last = tsToken{kind: tsPunct, text: "{"}
+	depth := 0
+	for {
+		tok, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case tok.kind == tsEOF:
+			return s.errorf(start, "unterminated expression")
+		case tok.kind == tsPunct && tok.text == "{":
+			depth++
+		case tok.kind == tsPunct && tok.text == "}":
+			if depth == 0 {
+				return nil
+			}
+			depth--
+		}
+	}
+}
+
+func (s *tsScanner) scanRegexp() bool {
+	i := s.pos + 1
+	class := false
+	for i < len(s.src) {
+		switch s.src[i] {
+		case '\\':
+			i++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '\n':
+			return false
+		case '/':
+			if !class {
+				i++
+				for i < len(s.src) && isTSIdentChar(s.src[i]) {
+					i++
+				}
+				s.pos = i
+				return true
+			}
+		}
+		i++
+	}
+	return false
+}
+
+// looksLikeJSX 区分 JSX 元素与泛型箭头函数 <T,>() => {}、<T extends U>() => {}
+func (s *tsScanner) looksLikeJSX() bool {
+	rest := strings.TrimLeft(s.src[s.pos+1:], " \t\r\n")
+	if strings.HasPrefix(rest, ">") {
+		return true
+	}
+	i := 0
+	for i < len(rest) && (isTSIdentChar(rest[i]) || rest[i] == '.' || rest[i] == '-' || rest[i] == ':') {
+		i++
+	}
+	if i == 0 {
+		return false
+	}
+	after := strings.TrimLeft(rest[i:], " \t\r\n")
+	return !strings.HasPrefix(after, ",") && !strings.HasPrefix(after, "extends ") && !strings.HasPrefix(after, ">(")
+}
+
+// scanJSXElement 扫描 JSX 元素（含子元素），属性与子节点中的表达式递归扫描
+func (s *tsScanner) scanJSXElement() error {
+	start := s.pos
+	s.pos++
+	for s.pos < len(s.src) {
+		switch {
+		case strings.HasPrefix(s.src[s.pos:], "/>"):
+			s.pos += 2
+			return nil
+		case s.src[s.pos] == '>':
+			s.pos++
+			return s.scanJSXChildren(start)
+		case s.src[s.pos] == '{':
+			s.pos++
+			if err := s.scanExpression(); err != nil {
+				return err
+			}
+		case s.src[s.pos] == '"' || s.src[s.pos] == '\'':
+			quote := s.src[s.pos]
+			i := strings.IndexByte(s.src[s.pos+1:], quote)
+			if i < 0 {
+				return s.errorf(s.pos, "unterminated string literal")
+			}
+			s.pos += i + 2
+		default:
+			s.pos++
+		}
+	}
+	return s.errorf(start, "unterminated JSX element")
+}
+
+func (s *tsScanner) scanJSXChildren(start int) error {
+	for s.pos < len(s.src) {
+		switch s.src[s.pos] {
+		case '{':
+			s.pos++
+			if err := s.scanExpression(); err != nil {
+				return err
+			}
+		case '<':
+			if strings.HasPrefix(strings.TrimLeft(s.src[s.pos+1:], " \t\r\n"), "/") {
+				i := strings.IndexByte(s.src[s.pos:], '>')
+				if i < 0 {
+					return s.errorf(start, "unterminated JSX element")
+				}
+				s.pos += i + 1
+				return nil
+			}
+			if err := s.scanJSXElement(); err != nil {
+				return err
+			}
+		default:
+			s.pos++
+		}
+	}
+	return s.errorf(start, "unterminated JSX element")
+}
@@ -0,0 +1,268 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultWatchDelay 检测到变化后等待的时间，合并连续的修改
+const defaultWatchDelay = 100 * time.Millisecond
+
+// watchSettings 监听模式的参数
+type watchSettings struct {
+	delay  time.Duration
+	mode   string   // poll 使用 esbuild 内置的轮询监听，notify 使用文件系统通知
+	dir    string   // notify 模式下监听的目录
+	ignore []string // notify 模式下忽略的文件或目录，例如输出目录
+}
+
+// watchReportPlugin 在每次构建结束时输出状态、耗时、变化的输出文件以及错误和警告
+func watchReportPlugin() api.Plugin {
+	return api.Plugin{
+		Name: "watch-report",
+		Setup: func(build api.PluginBuild) {
+			var (
+				start  time.Time
+				builds int
+				hashes = make(map[string][sha256.Size]byte)
+			)
+			build.OnStart(func() (api.OnStartResult, error) {
+				start = time.Now()
+				return api.OnStartResult{}, nil
+			})
+			build.OnEnd(func(result *api.BuildResult) (api.OnEndResult, error) {
+				builds++
+				elapsed := time.Since(start).Round(time.Millisecond)
+				printMessages(result.Errors, api.ErrorMessage)
+				printMessages(result.Warnings, api.WarningMessage)
+				if len(result.Errors) > 0 {
+					log.Printf("Build #%d failed with %d errors in %v", builds, len(result.Errors), elapsed)
+					return api.OnEndResult{}, nil
+				}
+				changed := changedOutputs(result.OutputFiles, hashes)
+				log.Printf("Build #%d succeeded in %v, %d warnings, %d of %d outputs changed", builds, elapsed, len(result.Warnings), len(changed), len(result.OutputFiles))
+				if len(changed) > 0 && builds > 1 {
+					const limit = 10
+					more := ""
+					if len(changed) > limit {
+						more = fmt.Sprintf(" and %d more", len(changed)-limit)
+						changed = changed[:limit]
+					}
+					log.Printf("  changed: %s%s", strings.Join(changed, ", "), more)
+				}
+				return api.OnEndResult{}, nil
+			})
+		},
+	}
+}
+
+// changedOutputs 比较输出内容的哈希，返回发生变化的输出文件（相对当前目录），并更新哈希记录
+func changedOutputs(files []api.OutputFile, hashes map[string][sha256.Size]byte) []string {
+	var changed []string
+	wd, _ := os.Getwd()
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		seen[f.Path] = true
+		sum := sha256.Sum256(f.Contents)
+		if previous, ok := hashes[f.Path]; ok && previous == sum {
+			continue
+		}
+		hashes[f.Path] = sum
+		name := f.Path
+		if rel, err := filepath.Rel(wd, f.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		changed = append(changed, name)
+	}
+	for p := range hashes {
+		if !seen[p] {
+			delete(hashes, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// printMessages 使用 esbuild 的格式输出消息，标准错误为终端时带颜色
+func printMessages(msgs []api.Message, kind api.MessageKind) {
+	if len(msgs) == 0 {
+		return
+	}
+	color := false
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == "" {
+		color = true
+	}
+	formatted := api.FormatMessages(msgs, api.FormatMessagesOptions{Kind: kind, Color: color})
+	fmt.Fprint(os.Stderr, strings.Join(formatted, ""))
+}
+
+// watchNotify 使用文件系统通知监听目录，变化后延迟触发重新构建
+func watchNotify(ctx context.Context, buildCtx api.BuildContext, s watchSettings) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+	ignored := func(name string) bool {
+		base := filepath.Base(name)
+		if name != s.dir && (strings.HasPrefix(base, ".") || base == "node_modules") {
+			return true
+		}
+		for _, p := range s.ignore {
+			if name == p || strings.HasPrefix(name, p+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+	addTree := func(root string) error {
+		return filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if ignored(p) {
+				return filepath.SkipDir
+			}
+			return watcher.Add(p)
+		})
+	}
+	if err := addTree(s.dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %v", s.dir, err)
+	}
+	timer := time.NewTimer(s.delay)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ignored(event.Name) || event.Op == fsnotify.Chmod {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := addTree(event.Name); err != nil {
+						log.Printf("Watcher error: %v", err)
+					}
+				}
+			}
+			timer.Reset(s.delay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Watcher error: %v", err)
+		case <-timer.C:
+			buildCtx.Rebuild()
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// inputWatcher 通过所在目录监听一组文件，编辑器以替换方式保存时同样能检测到，
+// 变化经过延迟合并后发送文件路径
+type inputWatcher struct {
+	watcher *fsnotify.Watcher
+	mu      sync.Mutex
+	files   map[string]bool
+	dirs    map[string]bool
+	delay   time.Duration
+	changes chan string
+}
+
+func newInputWatcher() (*inputWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %v", err)
+	}
+	w := &inputWatcher{
+		watcher: watcher,
+		files:   make(map[string]bool),
+		dirs:    make(map[string]bool),
+		delay:   defaultWatchDelay,
+		changes: make(chan string, 1),
+	}
+	go w.loop()
+	return w, nil
+}
+
+// set 替换监听的文件，文件可以尚不存在，但所在目录需要存在
+func (w *inputWatcher) set(files []string, delay time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if delay > 0 {
+		w.delay = delay
+	}
+	w.files = make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		w.files[file] = true
+		dirs[filepath.Dir(file)] = true
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			_ = w.watcher.Remove(dir)
+		}
+	}
+	for dir := range dirs {
+		if !w.dirs[dir] {
+			if err := w.watcher.Add(dir); err != nil {
+				log.Printf("Watcher error: %v", err)
+				delete(dirs, dir)
+			}
+		}
+	}
+	w.dirs = dirs
+}
+
+func (w *inputWatcher) loop() {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	var pending string
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.mu.Lock()
+			matched, delay := w.files[event.Name] && event.Op != fsnotify.Chmod, w.delay
+			w.mu.Unlock()
+			if matched {
+				pending = event.Name
+				timer.Reset(delay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Watcher error: %v", err)
+		case <-timer.C:
+			select {
+			case w.changes <- pending:
+			default:
+			}
+		}
+	}
+}
+
+func (w *inputWatcher) close() {
+	_ = w.watcher.Close()
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestChangedOutputs(t *testing.T) {
+	wd, _ := os.Getwd()
+	a, b := filepath.Join(wd, "dist", "a.js"), filepath.Join(wd, "dist", "b.js")
+	hashes := make(map[string][sha256.Size]byte)
+	first := changedOutputs([]api.OutputFile{{Path: a, Contents: []byte("a")}, {Path: b, Contents: []byte("b")}}, hashes)
+	if want := []string{filepath.Join("dist", "a.js"), filepath.Join("dist", "b.js")}; !reflect.DeepEqual(first, want) {
+		t.Errorf("first build changed %v, want %v", first, want)
+	}
+	second := changedOutputs([]api.OutputFile{{Path: a, Contents: []byte("a")}, {Path: b, Contents: []byte("b2")}}, hashes)
+	if want := []string{filepath.Join("dist", "b.js")}; !reflect.DeepEqual(second, want) {
+		t.Errorf("second build changed %v, want %v", second, want)
+	}
+	changedOutputs([]api.OutputFile{{Path: a, Contents: []byte("a")}}, hashes)
+	if _, ok := hashes[b]; ok {
+		t.Errorf("removed output still tracked")
+	}
+}
+
+func TestWatchReloadConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configFile := filepath.Join(dir, "build.json")
+	config := func(version string) string {
+		return `{"entryPoints": ["main.js"], "outdir": "dist", "watch": true, "watchDelay": 20, "define": {"VERSION": "\"` + version + `\""}}`
+	}
+	write("main.js", "console.log(VERSION)\n")
+	write("build.json", config("one"))
+	load := func() (*watchBuild, error) {
+		config, err := loadEsbuildConfig(configFile)
+		if err != nil {
+			return nil, err
+		}
+		return newWatchBuild(context.Background(), configFile, config)
+	}
+	build, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if build.inputs[0] != configFile || build.inputs[1] != filepath.Join(dir, "tsconfig.json") {
+		t.Errorf("unexpected inputs %v", build.inputs)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runEsbuildWatch(ctx, build, load) }()
+	defer func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}()
+	waitOutput := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if data, err := os.ReadFile(filepath.Join(dir, "dist", "main.js")); err == nil && strings.Contains(string(data), want) {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("output never contained %q", want)
+	}
+	waitOutput(`"one"`)
+	// 配置错误时保留原来的构建，修复后使用新的配置
+	write("build.json", "{broken")
+	time.Sleep(200 * time.Millisecond)
+	write("build.json", config("two"))
+	waitOutput(`"two"`)
+}
@@ -4,13 +4,19 @@ go 1.24
 
 require (
 	github.com/ZenLiuCN/fn v0.1.34
+	github.com/andybalholm/brotli v1.2.0
+	github.com/dop251/goja v0.0.0-20260311135729-065cd970411c
 	github.com/evanw/esbuild v0.25.9
 	github.com/fsnotify/fsnotify v1.9.0
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible
 	github.com/urfave/cli/v3 v3.4.1
 )
 
 require (
+	github.com/dlclark/regexp2 v1.11.4 // indirect
 	github.com/go-akka/configuration v0.0.0-20200606091224-a002c0330665 // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
 	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56 // indirect
 	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/text v0.3.8 // indirect
 )